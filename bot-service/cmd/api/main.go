@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Lucas-Onofre/financial-chat/bot-service/internal/broker"
@@ -15,36 +18,35 @@ import (
 	"github.com/Lucas-Onofre/financial-chat/bot-service/internal/shared"
 )
 
+// shutdownTimeout bounds how long in-flight commands are waited for on SIGTERM.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	rabbitmqURL := fmt.Sprintf("amqp://%s:%s@%s:%s/",
-		os.Getenv("RABBITMQ_USER"),
-		os.Getenv("RABBITMQ_PASSWORD"),
-		os.Getenv("RABBITMQ_HOST"),
-		os.Getenv("RABBITMQ_PORT"))
-
-	// Retry logic for RabbitMQ connection
-	var rb *broker.RabbitMQBroker
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Retry logic for message broker connection
+	var rb broker.Broker
 	var err error
 	maxRetries := 10
 	for i := 0; i < maxRetries; i++ {
-		rb, err = broker.NewRabbitMQBroker(rabbitmqURL)
+		rb, err = connectBroker(broker.WithMessageTimeout(commandTimeout()))
 		if err == nil {
 			break
 		}
-		log.Printf("Failed to connect to RabbitMQ (attempt %d/%d): %v", i+1, maxRetries, err)
+		log.Printf("Failed to connect to message broker (attempt %d/%d): %v", i+1, maxRetries, err)
 		time.Sleep(5 * time.Second)
 	}
 	if err != nil {
-		log.Fatal("Failed to connect to RabbitMQ after all retries:", err)
+		log.Fatal("Failed to connect to message broker after all retries:", err)
 	}
-	defer rb.Close()
 
 	stooqClient := marketdataprovider.New()
 	service := service.New(stooqClient, rb)
 	handler := handler.New(service)
 
-	if err := rb.Subscribe(shared.BrokerChatCommandsQueueName, func(message string) error {
-		if err := handler.Handle(context.Background(), message); err != nil {
+	if err := rb.Subscribe(shared.BrokerChatCommandsQueueName, func(ctx context.Context, message string) error {
+		if err := handler.Handle(ctx, message); err != nil {
 			log.Printf("failed to handle message: %v", err)
 			return err
 		}
@@ -59,7 +61,71 @@ func main() {
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatal(err)
+	server := &http.Server{Addr: ":8080"}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down bot service")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Stop taking new commands and let the ones being processed publish their replies;
+	// anything not yet handled stays in the queue for the next instance.
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to shut down http server: %v", err)
+	}
+	if err := rb.Drain(shutdownCtx); err != nil {
+		log.Printf("failed to drain message broker: %v", err)
+	}
+	if err := rb.Close(); err != nil {
+		log.Printf("failed to close message broker: %v", err)
+	}
+}
+
+// connectBroker opens a connection to the backend selected by BROKER_DRIVER: "rabbitmq" (default) or "nats".
+func connectBroker(opts ...broker.Option) (broker.Broker, error) {
+	switch driver := os.Getenv("BROKER_DRIVER"); driver {
+	case "", "rabbitmq":
+		rabbitmqURL := fmt.Sprintf("amqp://%s:%s@%s:%s/",
+			os.Getenv("RABBITMQ_USER"),
+			os.Getenv("RABBITMQ_PASSWORD"),
+			os.Getenv("RABBITMQ_HOST"),
+			os.Getenv("RABBITMQ_PORT"))
+
+		rb, err := broker.NewRabbitMQBroker(rabbitmqURL, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return rb, nil
+	case "nats":
+		nb, err := broker.NewNATSBroker(os.Getenv("NATS_URL"), opts...)
+		if err != nil {
+			return nil, err
+		}
+		return nb, nil
+	default:
+		return nil, fmt.Errorf("unsupported BROKER_DRIVER %q", driver)
+	}
+}
+
+// commandTimeout reads COMMAND_TIMEOUT as a Go duration (e.g. "10s"), bounding a single command
+// including the Stooq request. It falls back to the broker's DefaultMessageTimeout.
+func commandTimeout() time.Duration {
+	raw := os.Getenv("COMMAND_TIMEOUT")
+	if raw == "" {
+		return broker.DefaultMessageTimeout
+	}
+
+	timeout, err := time.ParseDuration(raw)
+	if err != nil || timeout <= 0 {
+		log.Printf("invalid COMMAND_TIMEOUT %q, using %s", raw, broker.DefaultMessageTimeout)
+		return broker.DefaultMessageTimeout
 	}
+	return timeout
 }
@@ -0,0 +1,149 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBroker_PublishSubscribe(t *testing.T) {
+	tests := []struct {
+		name             string
+		publishBeforeSub bool
+		messages         []string
+	}{
+		{
+			name:             "Given a subscriber, When messages are published, Then they are delivered in order",
+			publishBeforeSub: false,
+			messages:         []string{"first", "second"},
+		},
+		{
+			name:             "Given messages published before subscribing, When Subscribe is called, Then buffered messages are delivered",
+			publishBeforeSub: true,
+			messages:         []string{"first", "second"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb := NewMemoryBroker()
+			defer mb.Close()
+
+			received := make(chan string, len(tt.messages))
+			subscribe := func() {
+				err := mb.Subscribe("queue", func(_ context.Context, message string) error {
+					received <- message
+					return nil
+				})
+				assert.NoError(t, err)
+			}
+
+			if !tt.publishBeforeSub {
+				subscribe()
+			}
+			for _, msg := range tt.messages {
+				assert.NoError(t, mb.Publish("queue", msg))
+			}
+			if tt.publishBeforeSub {
+				subscribe()
+			}
+
+			for _, want := range tt.messages {
+				select {
+				case got := <-received:
+					assert.Equal(t, want, got)
+				case <-time.After(time.Second):
+					t.Fatalf("timed out waiting for %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryBroker_Close(t *testing.T) {
+	mb := NewMemoryBroker()
+	assert.NoError(t, mb.Subscribe("queue", func(context.Context, string) error { return nil }))
+	assert.NoError(t, mb.Close())
+
+	assert.Equal(t, ErrBrokerClosed, mb.Publish("queue", "message"))
+	assert.Equal(t, ErrBrokerClosed, mb.Subscribe("queue", func(context.Context, string) error { return nil }))
+	assert.NoError(t, mb.Close())
+}
+
+func TestMemoryBroker_Drain(t *testing.T) {
+	mb := NewMemoryBroker()
+	defer mb.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handled := make(chan string, 2)
+	assert.NoError(t, mb.Subscribe("queue", func(_ context.Context, message string) error {
+		close(started)
+		<-release
+		handled <- message
+		return nil
+	}))
+	assert.NoError(t, mb.Publish("queue", "in-flight"))
+	<-started
+
+	drained := make(chan error)
+	go func() { drained <- mb.Drain(context.Background()) }()
+
+	select {
+	case <-drained:
+		t.Fatal("Drain returned before the in-flight handler finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.NoError(t, <-drained)
+	assert.Equal(t, "in-flight", <-handled)
+
+	assert.NoError(t, mb.Publish("queue", "after-drain"))
+	select {
+	case msg := <-handled:
+		t.Fatalf("message %q was handled after Drain", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMemoryBroker_DrainTimeout(t *testing.T) {
+	mb := NewMemoryBroker()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	assert.NoError(t, mb.Subscribe("queue", func(context.Context, string) error {
+		close(started)
+		<-release
+		return nil
+	}))
+	assert.NoError(t, mb.Publish("queue", "slow"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, mb.Drain(ctx))
+}
+
+func TestMemoryBroker_HandlerContext(t *testing.T) {
+	mb := NewMemoryBroker(WithMessageTimeout(time.Minute))
+
+	started := make(chan context.Context)
+	assert.NoError(t, mb.Subscribe("queue", func(ctx context.Context, _ string) error {
+		started <- ctx
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	assert.NoError(t, mb.Publish("queue", "message"))
+
+	ctx := <-started
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+
+	assert.NoError(t, mb.Close())
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
@@ -1,6 +1,12 @@
 package mocks
 
-import "github.com/stretchr/testify/mock"
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Lucas-Onofre/financial-chat/bot-service/internal/broker"
+)
 
 type MockBroker struct {
 	mock.Mock
@@ -11,7 +17,7 @@ func (m *MockBroker) Publish(queue string, message string) error {
 	return args.Error(0)
 }
 
-func (m *MockBroker) Subscribe(queue string, handler func(message string) error) error {
+func (m *MockBroker) Subscribe(queue string, handler broker.Handler) error {
 	args := m.Called(queue, handler)
 	return args.Error(0)
 }
@@ -20,3 +26,8 @@ func (m *MockBroker) Close() error {
 	args := m.Called()
 	return args.Error(0)
 }
+
+func (m *MockBroker) Drain(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
@@ -0,0 +1,170 @@
+package broker
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	natsOperationTimeout = 5 * time.Second
+	// natsAckMargin is how much longer than a handler's deadline JetStream waits for its ack.
+	natsAckMargin = 30 * time.Second
+)
+
+// NATSBroker implements Producer and Consumer on top of NATS JetStream.
+// Each queue maps to a file-backed work-queue stream with a durable consumer of the same name,
+// so messages survive restarts and every message is handled by exactly one subscriber,
+// matching the durable queues declared by RabbitMQBroker.
+type NATSBroker struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	opts   options
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	consumers []jetstream.ConsumeContext
+	streams   map[string]bool
+	inFlight  inFlight
+}
+
+func NewNATSBroker(url string, opts ...Option) (*NATSBroker, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &NATSBroker{
+		conn:    conn,
+		js:      js,
+		opts:    newOptions(opts),
+		ctx:     ctx,
+		cancel:  cancel,
+		streams: make(map[string]bool),
+	}, nil
+}
+
+func (n *NATSBroker) Publish(queue string, message string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), natsOperationTimeout)
+	defer cancel()
+
+	if err := n.declareStream(ctx, queue); err != nil {
+		return err
+	}
+
+	_, err := n.js.Publish(ctx, queue, []byte(message))
+	return err
+}
+
+func (n *NATSBroker) Subscribe(queue string, handler Handler) error {
+	ctx, cancel := context.WithTimeout(context.Background(), natsOperationTimeout)
+	defer cancel()
+
+	if err := n.declareStream(ctx, queue); err != nil {
+		return err
+	}
+
+	// The handler's deadline must pass before JetStream gives up on the ack, or a handler running
+	// close to it would see its message redelivered while still working on it.
+	consumer, err := n.js.CreateOrUpdateConsumer(ctx, queue, jetstream.ConsumerConfig{
+		Durable:   queue,
+		AckPolicy: jetstream.AckExplicitPolicy,
+		AckWait:   n.opts.messageTimeout + natsAckMargin,
+	})
+	if err != nil {
+		return err
+	}
+
+	consumeCtx, err := consumer.Consume(func(msg jetstream.Msg) {
+		if !n.inFlight.start() {
+			msg.Nak()
+			return
+		}
+		defer n.inFlight.done()
+
+		ctx, cancel := context.WithTimeout(n.ctx, n.opts.messageTimeout)
+		defer cancel()
+
+		if err := handler(ctx, string(msg.Data())); err != nil {
+			log.Printf("error handling message: %v", err)
+		}
+
+		if err := msg.Ack(); err != nil {
+			log.Printf("error acknowledging message: %v", err)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	n.consumers = append(n.consumers, consumeCtx)
+	n.mu.Unlock()
+
+	return nil
+}
+
+func (n *NATSBroker) Drain(ctx context.Context) error {
+	n.mu.Lock()
+	for _, c := range n.consumers {
+		c.Stop()
+	}
+	n.consumers = nil
+	n.mu.Unlock()
+
+	return n.inFlight.drain(ctx)
+}
+
+func (n *NATSBroker) Close() error {
+	n.cancel()
+
+	n.mu.Lock()
+	for _, c := range n.consumers {
+		c.Stop()
+	}
+	n.consumers = nil
+	n.mu.Unlock()
+
+	if n.conn != nil {
+		n.conn.Close()
+	}
+	return nil
+}
+
+// declareStream creates the stream of queue the first time the queue is used, so publishing
+// afterwards costs a single JetStream request.
+func (n *NATSBroker) declareStream(ctx context.Context, queue string) error {
+	n.mu.Lock()
+	declared := n.streams[queue]
+	n.mu.Unlock()
+	if declared {
+		return nil
+	}
+
+	_, err := n.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      queue,
+		Subjects:  []string{queue},
+		Retention: jetstream.WorkQueuePolicy,
+		Storage:   jetstream.FileStorage,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	n.streams[queue] = true
+	n.mu.Unlock()
+	return nil
+}
@@ -1,6 +1,10 @@
 package marketdataprovider
 
-import "github.com/go-resty/resty/v2"
+import (
+	"context"
+
+	"github.com/go-resty/resty/v2"
+)
 
 var (
 	stooqUrl = "https://stooq.com/q/l/"
@@ -16,8 +20,9 @@ func New() MarketDataProviderPort {
 	}
 }
 
-func (c *Client) GetMarketData(stockCommand string) (string, error) {
+func (c *Client) GetMarketData(ctx context.Context, stockCommand string) (string, error) {
 	resp, err := c.client.R().
+		SetContext(ctx).
 		SetQueryParams(map[string]string{
 			"s": stockCommand,
 			"f": "sd2t2ohlcv",
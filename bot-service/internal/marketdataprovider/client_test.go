@@ -0,0 +1,61 @@
+package marketdataprovider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_GetMarketData(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		timeout time.Duration
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "Given a responsive provider, When GetMarketData is called, Then the CSV body is returned",
+			delay:   0,
+			timeout: time.Second,
+			want:    "Symbol,Close\nAAPL.US,262.82",
+			wantErr: nil,
+		},
+		{
+			name:    "Given a provider slower than the context deadline, When GetMarketData is called, Then the request is cancelled",
+			delay:   500 * time.Millisecond,
+			timeout: 20 * time.Millisecond,
+			want:    "",
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+				w.Write([]byte("Symbol,Close\nAAPL.US,262.82"))
+			}))
+			defer server.Close()
+
+			original := stooqUrl
+			stooqUrl = server.URL
+			defer func() { stooqUrl = original }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			got, err := New().GetMarketData(ctx, "AAPL.US")
+			assert.Equal(t, tt.want, got)
+			assert.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
@@ -1,12 +1,16 @@
 package mocks
 
-import "github.com/stretchr/testify/mock"
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
 
 type MockMarketDataProvider struct {
 	mock.Mock
 }
 
-func (m *MockMarketDataProvider) GetMarketData(stockCommand string) (string, error) {
-	args := m.Called(stockCommand)
+func (m *MockMarketDataProvider) GetMarketData(ctx context.Context, stockCommand string) (string, error) {
+	args := m.Called(ctx, stockCommand)
 	return args.String(0), args.Error(1)
 }
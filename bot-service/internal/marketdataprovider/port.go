@@ -1,5 +1,7 @@
 package marketdataprovider
 
+import "context"
+
 type MarketDataProviderPort interface {
-	GetMarketData(stockCommand string) (string, error)
+	GetMarketData(ctx context.Context, stockCommand string) (string, error)
 }
@@ -0,0 +1,81 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Lucas-Onofre/financial-chat/bot-service/internal/broker"
+	mktdatamock "github.com/Lucas-Onofre/financial-chat/bot-service/internal/marketdataprovider/mocks"
+	"github.com/Lucas-Onofre/financial-chat/bot-service/internal/message/dto"
+	"github.com/Lucas-Onofre/financial-chat/bot-service/internal/message/handler"
+	"github.com/Lucas-Onofre/financial-chat/bot-service/internal/message/service"
+	"github.com/Lucas-Onofre/financial-chat/bot-service/internal/shared"
+)
+
+func TestIntegration_CommandToReply(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		setup       func(mktdataClient *mktdatamock.MockMarketDataProvider)
+		wantType    dto.MessageType
+		wantContent string
+	}{
+		{
+			name:    "Given a stock command from chat-service, When it is consumed, Then a bot quote is published for the room",
+			command: `{"type":"command","user_id":"user1","username":"alice","room_id":"stocks","content":"/stock=AAPL.US","timestamp":0}`,
+			setup: func(mktdataClient *mktdatamock.MockMarketDataProvider) {
+				mktdataClient.On("GetMarketData", mock.Anything, "AAPL.US").Return("Symbol,Date,Time,Open,High,Low,Close,Volume\nAAPL.US,2025-10-24,22:00:17,261.19,264.13,259.18,262.82,38253717", nil)
+			},
+			wantType:    dto.MessageTypeBot,
+			wantContent: "AAPL.US quote is $262.82 per share",
+		},
+		{
+			name:    "Given the market data provider fails, When the command is consumed, Then an error message is published for the room",
+			command: `{"type":"command","user_id":"user1","username":"alice","room_id":"stocks","content":"/stock=AAPL.US","timestamp":0}`,
+			setup: func(mktdataClient *mktdatamock.MockMarketDataProvider) {
+				mktdataClient.On("GetMarketData", mock.Anything, "AAPL.US").Return("", errors.New("timeout"))
+			},
+			wantType:    dto.MessageTypeError,
+			wantContent: service.ReasonExternalServiceFailure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mb := broker.NewMemoryBroker()
+			defer mb.Close()
+
+			mktdataClient := new(mktdatamock.MockMarketDataProvider)
+			tt.setup(mktdataClient)
+
+			h := handler.New(service.New(mktdataClient, mb))
+			require.NoError(t, mb.Subscribe(shared.BrokerChatCommandsQueueName, h.Handle))
+
+			replies := make(chan string, 1)
+			require.NoError(t, mb.Subscribe(shared.BrokerChatResponsesQueueName, func(_ context.Context, message string) error {
+				replies <- message
+				return nil
+			}))
+
+			require.NoError(t, mb.Publish(shared.BrokerChatCommandsQueueName, tt.command))
+
+			select {
+			case reply := <-replies:
+				var got dto.ResponseMessage
+				require.NoError(t, json.Unmarshal([]byte(reply), &got))
+				assert.Equal(t, tt.wantType.ToString(), got.Type)
+				assert.Equal(t, "stocks", got.RoomID)
+				assert.Equal(t, tt.wantContent, got.Content)
+			case <-time.After(2 * time.Second):
+				t.Fatal("timed out waiting for reply")
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 
 var (
 	ReasonExternalServiceFailure = "external service failure"
+	ReasonExternalServiceTimeout = "quote service took too long to respond, please try again later"
 	ReasonInternalError          = "internal error, please try again later"
 )
 
@@ -33,10 +34,14 @@ func New(mktdataClient marketdataprovider.MarketDataProviderPort, brokerProducer
 	}
 }
 
-func (s *Service) Process(_ context.Context, msg dto.CommandMessage) error {
-	rawCsv, err := s.mktdataClient.GetMarketData(msg.Command.GetValue())
+func (s *Service) Process(ctx context.Context, msg dto.CommandMessage) error {
+	rawCsv, err := s.mktdataClient.GetMarketData(ctx, msg.Command.GetValue())
 	if err != nil {
-		_ = sendFailureMessage(s.brokerProducer, msg.RoomID, ReasonExternalServiceFailure)
+		reason := ReasonExternalServiceFailure
+		if errors.Is(err, context.DeadlineExceeded) {
+			reason = ReasonExternalServiceTimeout
+		}
+		_ = sendFailureMessage(s.brokerProducer, msg.RoomID, reason)
 		return err
 	}
 
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -46,7 +47,7 @@ func TestService_Process(t *testing.T) {
 				},
 			},
 			setup: func(mktdataClient *mktdatamock.MockMarketDataProvider, brokerProducer *brokermock.MockBroker) {
-				mktdataClient.On("GetMarketData", "AAPL").Return("Symbol,Date,Time,Open,High,Low,Close,Volume\nAAPL.US,2025-10-24,22:00:17,261.19,264.13,259.18,262.82,38253717", nil)
+				mktdataClient.On("GetMarketData", mock.Anything, "AAPL").Return("Symbol,Date,Time,Open,High,Low,Close,Volume\nAAPL.US,2025-10-24,22:00:17,261.19,264.13,259.18,262.82,38253717", nil)
 				brokerProducer.On("Publish", shared.BrokerChatResponsesQueueName, mock.Anything).Return(nil)
 			},
 			want: want{
@@ -64,13 +65,33 @@ func TestService_Process(t *testing.T) {
 				},
 			},
 			setup: func(mktdataClient *mktdatamock.MockMarketDataProvider, brokerProducer *brokermock.MockBroker) {
-				mktdataClient.On("GetMarketData", "AAPL").Return("", errors.New("error fetching market data"))
+				mktdataClient.On("GetMarketData", mock.Anything, "AAPL").Return("", errors.New("error fetching market data"))
 				brokerProducer.On("Publish", shared.BrokerChatResponsesQueueName, mock.Anything).Return(nil)
 			},
 			want: want{
 				error: errors.New("error fetching market data"),
 			},
 		},
+		{
+			name: "Given market data request exceeds its deadline, When Process is called, Then it should return the error and send a timeout message",
+			args: args{
+				ctx: context.Background(),
+				msg: dto.CommandMessage{
+					UserID:  "user1",
+					RoomID:  "room1",
+					Command: dto.Command("/stock=AAPL"),
+				},
+			},
+			setup: func(mktdataClient *mktdatamock.MockMarketDataProvider, brokerProducer *brokermock.MockBroker) {
+				mktdataClient.On("GetMarketData", mock.Anything, "AAPL").Return("", context.DeadlineExceeded)
+				brokerProducer.On("Publish", shared.BrokerChatResponsesQueueName, mock.MatchedBy(func(message string) bool {
+					return strings.Contains(message, ReasonExternalServiceTimeout)
+				})).Return(nil)
+			},
+			want: want{
+				error: context.DeadlineExceeded,
+			},
+		},
 		{
 			name: "Given invalid CSV data from market data provider, When Process is called, Then it should return an error and send a failure message",
 			args: args{
@@ -82,7 +103,7 @@ func TestService_Process(t *testing.T) {
 				},
 			},
 			setup: func(mktdataClient *mktdatamock.MockMarketDataProvider, brokerProducer *brokermock.MockBroker) {
-				mktdataClient.On("GetMarketData", "AAPL").Return(invalidCSVResponse, nil)
+				mktdataClient.On("GetMarketData", mock.Anything, "AAPL").Return(invalidCSVResponse, nil)
 				brokerProducer.On("Publish", shared.BrokerChatResponsesQueueName, mock.Anything).Return(nil)
 			},
 			want: want{
@@ -100,7 +121,7 @@ func TestService_Process(t *testing.T) {
 				},
 			},
 			setup: func(mktdataClient *mktdatamock.MockMarketDataProvider, brokerProducer *brokermock.MockBroker) {
-				mktdataClient.On("GetMarketData", "AAPL").Return(validCSVResponse, nil)
+				mktdataClient.On("GetMarketData", mock.Anything, "AAPL").Return(validCSVResponse, nil)
 				brokerProducer.On("Publish", shared.BrokerChatResponsesQueueName, mock.Anything).Return(errors.New("publish error"))
 			},
 			want: want{
@@ -122,6 +143,7 @@ func TestService_Process(t *testing.T) {
 			err := service.Process(tt.args.ctx, tt.args.msg)
 
 			assert.Equal(t, tt.want.error, err)
+			brokerProducer.AssertExpectations(t)
 		})
 	}
 }
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	shared "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/properties"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/websocket"
@@ -13,17 +19,53 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	authhttp "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/http"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/lockout"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/broker"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/filter"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/mail"
+	messagedao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	messagehandler "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/handler"
+	messagerepository "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/repository"
+	messageservice "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/service"
+	notificationdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+	notificationhandler "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/handler"
+	notificationrepository "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/repository"
+	notificationservice "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/service"
+	resetdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/passwordreset/dao"
+	resetrepository "github.com/Lucas-Onofre/financial-chat/chat-service/internal/passwordreset/repository"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/ratelimit"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/retention"
+	roomdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	roomhandler "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/handler"
+	roomrepository "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository"
+	roomservice "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/service"
+	sessiondao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/dao"
+	sessionrepository "github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/repository"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dao"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/handler"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/repository"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/service"
 )
 
+// shutdownTimeout bounds how long in-flight requests and bot responses are waited for on SIGTERM.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	mux := http.NewServeMux()
 
+	// Tokens are signed with the keys listed in JWT_KEYS_FILE; there is no fallback key, so a
+	// missing or broken keys file stops the service before it connects to anything.
+	signingKeys, err := jwt.LoadKeySet(os.Getenv("JWT_KEYS_FILE"))
+	if err != nil {
+		log.Fatal("failed to load JWT signing keys: ", err)
+	}
+
 	// Postgres
 	db, err := gorm.Open(postgres.Open(os.Getenv("DATABASE_URL")), &gorm.Config{})
 	if err != nil {
@@ -32,52 +74,154 @@ func main() {
 
 	if err := db.AutoMigrate(
 		&dao.User{},
+		&dao.RecoveryCode{},
+		&messagedao.Message{},
+		&messagedao.MessageRevision{},
+		&messagedao.Reaction{},
+		&messagedao.ReadCursor{},
+		&messagedao.ArchivedMessage{},
+		&notificationdao.Notification{},
+		&roomdao.Room{},
+		&roomdao.Member{},
+		&roomdao.ModerationEvent{},
+		&sessiondao.Session{},
+		&resetdao.PasswordReset{},
 	); err != nil {
 		log.Fatal("failed to migrate database:", err)
 	}
+	if err := db.Exec(messagerepository.SearchIndexSQL).Error; err != nil {
+		log.Fatal("failed to create message search index:", err)
+	}
+	if err := db.Exec(roomrepository.DisownDefaultRoomSQL).Error; err != nil {
+		log.Fatal("failed to disown default room:", err)
+	}
 
-	// RabbitMQ
-	rabbitmqURL := fmt.Sprintf("amqp://%s:%s@%s:%s/",
-		os.Getenv("RABBITMQ_USER"),
-		os.Getenv("RABBITMQ_PASSWORD"),
-		os.Getenv("RABBITMQ_HOST"),
-		os.Getenv("RABBITMQ_PORT"))
-
-	var rb *broker.RabbitMQBroker
+	// Message broker
+	var rb broker.Broker
 	var retryErr error
 	maxRetries := 10
 	for i := 0; i < maxRetries; i++ {
-		rb, retryErr = broker.NewRabbitMQBroker(rabbitmqURL)
+		rb, retryErr = connectBroker()
 		if retryErr == nil {
 			break
 		}
-		log.Printf("Failed to connect to RabbitMQ (attempt %d/%d): %v", i+1, maxRetries, err)
+		log.Printf("Failed to connect to message broker (attempt %d/%d): %v", i+1, maxRetries, retryErr)
 		time.Sleep(5 * time.Second)
 	}
 	if retryErr != nil {
-		log.Fatal("Failed to connect to RabbitMQ after all retries:", err)
+		log.Fatal("Failed to connect to message broker after all retries:", retryErr)
 	}
-	defer rb.Close()
 
-	// User auth
-	jwtService := jwt.NewJWTService(os.Getenv("SECRET_KEY"), 24*time.Hour)
+	// User auth. Access tokens are short-lived and checked against their session, so logging
+	// out revokes them; refresh tokens renew them.
+	sessionRepo := sessionrepository.NewRepository(db)
+	jwtService := jwt.NewJWTService(signingKeys, positiveDuration("ACCESS_TOKEN_TTL", 15*time.Minute), jwt.WithSessions(sessionRepo))
 	userRepo := repository.NewRepository(db)
-	userService := service.New(userRepo, jwtService)
-	userHandler := handler.New(*userService)
 
-	mux.HandleFunc("/register", handleMethod(http.MethodPost, userHandler.Register))
-	mux.HandleFunc("/login", handleMethod(http.MethodPost, userHandler.Login))
+	// Rooms
+	roomRepo := roomrepository.NewRepository(db)
+	roomService := roomservice.New(roomRepo)
+	roomHandler := roomhandler.New(*roomService)
+
+	// Messages
+	messageRepo := messagerepository.NewRepository(db)
+	messageService := messageservice.New(messageRepo, roomRepo)
+
+	messageHandler := messagehandler.New(*messageService)
+
+	authMiddleware := authhttp.AuthMiddleware(jwtService)
+	mux.Handle("/messages/{id}/thread", authMiddleware(handleMethod(http.MethodGet, messageHandler.Thread)))
+	mux.Handle("/messages/{id}/history", authMiddleware(handleMethod(http.MethodGet, messageHandler.History)))
+	mux.Handle("/search", authMiddleware(handleMethod(http.MethodGet, messageHandler.Search)))
+
+	// Notifications
+	notificationRepo := notificationrepository.NewRepository(db)
+	notificationService := notificationservice.New(notificationRepo, userRepo, roomRepo)
+	notificationHandler := notificationhandler.New(*notificationService)
+
+	mux.Handle("/notifications", authMiddleware(handleMethod(http.MethodGet, notificationHandler.List)))
+	mux.Handle("/notifications/read", authMiddleware(handleMethod(http.MethodPost, notificationHandler.MarkAllRead)))
+	mux.Handle("/notifications/{id}/read", authMiddleware(handleMethod(http.MethodPost, notificationHandler.MarkRead)))
 
 	// Websocket Hub
-	hub := websocket.NewHub(rb)
+	hub := websocket.NewHub(rb, messageService, notificationService, roomService,
+		websocket.WithRateLimits(
+			rateLimit("RATE_LIMIT_MESSAGES", websocket.DefaultMessageLimit),
+			rateLimit("RATE_LIMIT_COMMANDS", websocket.DefaultCommandLimit),
+		),
+		websocket.WithMaxFrameSize(int64(positiveInt("WS_MAX_FRAME_BYTES", int(websocket.DefaultMaxFrameSize)))),
+		websocket.WithMaxContentLength(positiveInt("MESSAGE_MAX_LENGTH", websocket.DefaultMaxContentLength)),
+		websocket.WithFilters(contentFilters()),
+		// Deprecated: ?token= leaks access tokens into logs; only enable it for old clients.
+		websocket.WithQueryTokenAuth(os.Getenv("WS_ALLOW_QUERY_TOKEN") == "true"),
+	)
 	go hub.Run()
 
+	// Users. Revoked sessions have their WebSockets closed by the hub.
+	// Password reset links point to PASSWORD_RESET_URL, the frontend page that reads reset_token.
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if resetURL == "" {
+		resetURL = "http://localhost:3000/"
+	}
+	// MFA_ISSUER is the name the service has in authenticator apps.
+	mfaIssuer := os.Getenv("MFA_ISSUER")
+	if mfaIssuer == "" {
+		mfaIssuer = service.DefaultMFAIssuer
+	}
+	userService := service.New(userRepo, sessionRepo, jwtService,
+		service.WithRefreshTokenTTL(positiveDuration("REFRESH_TOKEN_TTL", service.DefaultRefreshTokenTTL)),
+		service.WithSessionRevoked(hub.CloseSession),
+		service.WithLoginLockout(loginLockout()),
+		service.WithPasswordReset(resetrepository.NewRepository(db), mailer(), resetURL,
+			positiveDuration("PASSWORD_RESET_TTL", service.DefaultResetTokenTTL)),
+		service.WithMFAIssuer(mfaIssuer),
+	)
+	// ADMIN_USERNAMES is a comma-separated list of existing users made admins at startup.
+	missingAdmins, err := userService.PromoteAdmins(context.Background(), strings.Split(os.Getenv("ADMIN_USERNAMES"), ","))
+	if err != nil {
+		log.Fatal("failed to promote admins:", err)
+	}
+	for _, username := range missingAdmins {
+		log.Printf("ADMIN_USERNAMES lists %s, who has not registered; restart once they have to make them an admin", username)
+	}
+	userHandler := handler.New(*userService)
+
+	mux.HandleFunc("/register", handleMethod(http.MethodPost, userHandler.Register))
+	mux.HandleFunc("/login", handleMethod(http.MethodPost, userHandler.Login))
+	mux.HandleFunc("/login/mfa", handleMethod(http.MethodPost, userHandler.VerifyMFA))
+	mux.HandleFunc("/password/forgot", handleMethod(http.MethodPost, userHandler.ForgotPassword))
+	mux.HandleFunc("/password/reset", handleMethod(http.MethodPost, userHandler.ResetPassword))
+	mux.HandleFunc("/token/refresh", handleMethod(http.MethodPost, userHandler.Refresh))
+	mux.HandleFunc("/.well-known/jwks.json", handleMethod(http.MethodGet, authhttp.JWKSHandler(jwtService)))
+	mux.Handle("/logout", authMiddleware(handleMethod(http.MethodPost, userHandler.Logout)))
+	mux.Handle("GET /me", authMiddleware(http.HandlerFunc(userHandler.Profile)))
+	mux.Handle("PATCH /me", authMiddleware(http.HandlerFunc(userHandler.UpdateProfile)))
+	mux.Handle("DELETE /me", authMiddleware(http.HandlerFunc(userHandler.Delete)))
+	mux.Handle("/me/password", authMiddleware(handleMethod(http.MethodPost, userHandler.ChangePassword)))
+	mux.Handle("POST /me/mfa/totp", authMiddleware(http.HandlerFunc(userHandler.EnrolTOTP)))
+	mux.Handle("DELETE /me/mfa/totp", authMiddleware(http.HandlerFunc(userHandler.DisableTOTP)))
+	mux.Handle("/me/mfa/totp/confirm", authMiddleware(handleMethod(http.MethodPost, userHandler.ConfirmTOTP)))
+	mux.Handle("/me/mfa/recovery-codes", authMiddleware(handleMethod(http.MethodPost, userHandler.RegenerateRecoveryCodes)))
+	mux.Handle("/users/{id}/role", authMiddleware(authhttp.RequireRole(roles.Admin)(handleMethod(http.MethodPut, userHandler.SetRole))))
+
 	// Websocket
 	mux.HandleFunc("/ws", websocket.WsHandler(hub, jwtService))
+	mux.Handle("/ws/ticket", authMiddleware(handleMethod(http.MethodPost, websocket.TicketHandler(hub))))
+
+	// Rooms
+	mux.Handle("/rooms/unread", authMiddleware(handleMethod(http.MethodGet, messageHandler.Unread)))
+	mux.Handle("/rooms/{id}", authMiddleware(handleMethod(http.MethodGet, roomHandler.Get)))
+	mux.Handle("/rooms/{id}/retention", authMiddleware(handleMethod(http.MethodPut, roomHandler.SetRetention)))
+	mux.Handle("/rooms/{id}/export", authMiddleware(handleMethod(http.MethodGet, messageHandler.Export)))
+	mux.Handle("/rooms/{id}/filters", authMiddleware(handleMethod(http.MethodPut, roomHandler.SetFilters)))
+	mux.Handle("/rooms/{id}/moderation", authMiddleware(handleMethod(http.MethodGet, roomHandler.ModerationHistory)))
+	mux.Handle("DELETE /rooms/{id}", authMiddleware(websocket.DeleteRoomHandler(hub)))
+	mux.Handle("/rooms/{id}/members", authMiddleware(handleMethod(http.MethodGet, websocket.MembersHandler(hub))))
+	mux.Handle("/rooms/{id}/members/{userID}/role", authMiddleware(handleMethod(http.MethodPut, roomHandler.SetMemberRole)))
 
 	// Bot responses handling
-	if err := rb.Subscribe(shared.BrokerChatResponsesQueueName, func(message string) error {
-		if err := hub.HandleBotMessage(message); err != nil {
+	if err := rb.Subscribe(shared.BrokerChatResponsesQueueName, func(ctx context.Context, message string) error {
+		if err := hub.HandleBotMessage(ctx, message); err != nil {
 			log.Printf("failed to handle message: %v", err)
 			return err
 		}
@@ -87,16 +231,207 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Message retention, stopped with the rest of the service on shutdown
+	retentionJob := retention.NewJob(roomRepo, messageRepo, notificationRepo,
+		positiveDuration("RETENTION_INTERVAL", retention.DefaultInterval),
+		positiveInt("RETENTION_BATCH_SIZE", retention.DefaultBatchSize),
+	)
+	go retentionJob.Run(ctx)
+
 	// Health check
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 
-	log.Println("Starting server on port 8081")
-	if err := http.ListenAndServe(":8081", corsMiddleware(mux)); err != nil {
-		log.Fatal(err)
+	server := &http.Server{
+		Addr:    ":8081",
+		Handler: corsMiddleware(mux),
+	}
+
+	go func() {
+		log.Println("Starting server on port 8081")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections, deliver the bot responses and password reset emails already
+	// being handled, then close every WebSocket with a "server restarting" frame.
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("failed to shut down http server: %v", err)
+	}
+	if err := rb.Drain(shutdownCtx); err != nil {
+		log.Printf("failed to drain message broker: %v", err)
 	}
+	if err := userService.Drain(shutdownCtx); err != nil {
+		log.Printf("failed to send pending password reset emails: %v", err)
+	}
+	hub.Stop()
+	if err := rb.Close(); err != nil {
+		log.Printf("failed to close message broker: %v", err)
+	}
+}
+
+// connectBroker opens a connection to the backend selected by BROKER_DRIVER: "rabbitmq" (default) or "nats".
+func connectBroker() (broker.Broker, error) {
+	switch driver := os.Getenv("BROKER_DRIVER"); driver {
+	case "", "rabbitmq":
+		rabbitmqURL := fmt.Sprintf("amqp://%s:%s@%s:%s/",
+			os.Getenv("RABBITMQ_USER"),
+			os.Getenv("RABBITMQ_PASSWORD"),
+			os.Getenv("RABBITMQ_HOST"),
+			os.Getenv("RABBITMQ_PORT"))
+
+		rb, err := broker.NewRabbitMQBroker(rabbitmqURL)
+		if err != nil {
+			return nil, err
+		}
+		return rb, nil
+	case "nats":
+		nb, err := broker.NewNATSBroker(os.Getenv("NATS_URL"))
+		if err != nil {
+			return nil, err
+		}
+		return nb, nil
+	default:
+		return nil, fmt.Errorf("unsupported BROKER_DRIVER %q", driver)
+	}
+}
+
+// mailer selects how emails are delivered with MAIL_DRIVER, which has no default: "smtp" sends
+// them through SMTP_HOST, and "log", for local development only, writes them to MAIL_LOG_FILE or
+// to the service's log when it is unset. Password reset links are credentials, so a deployment
+// that forgot to configure mail refuses to start rather than log them.
+func mailer() mail.Mailer {
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "":
+		log.Fatal(`MAIL_DRIVER must be set: "smtp", or "log" for local development`)
+		return nil
+	case "log":
+		log.Println("MAIL_DRIVER=log: emails, including password reset links, are logged instead of sent; use it for local development only")
+		path := os.Getenv("MAIL_LOG_FILE")
+		if path == "" {
+			return mail.NewLogMailer(log.Writer())
+		}
+
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			log.Fatal("failed to open mail log file: ", err)
+		}
+		return mail.NewLogMailer(file)
+	case "smtp":
+		if os.Getenv("SMTP_HOST") == "" || os.Getenv("MAIL_FROM") == "" {
+			log.Fatal("MAIL_DRIVER=smtp needs SMTP_HOST and MAIL_FROM")
+		}
+
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		})
+	default:
+		log.Fatalf("unsupported MAIL_DRIVER %q", driver)
+		return nil
+	}
+}
+
+// positiveDuration reads a positive duration from the environment variable name, falling back when it is unset or invalid.
+func positiveDuration(name string, fallback time.Duration) time.Duration {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil || value <= 0 {
+		log.Printf("invalid %s %q, using %s", name, raw, fallback)
+		return fallback
+	}
+	return value
+}
+
+// positiveInt reads a positive integer from the environment variable name, falling back when it is unset or invalid.
+func positiveInt(name string, fallback int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return fallback
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 {
+		log.Printf("invalid %s %q, using %d", name, raw, fallback)
+		return fallback
+	}
+	return value
+}
+
+// contentFilters builds the filter chain offered to rooms. FILTER_BLOCKED_WORDS is a comma-separated
+// word list replacing the default one.
+func contentFilters() *filter.Chain {
+	words := filter.DefaultBlockedWords
+	if raw := os.Getenv("FILTER_BLOCKED_WORDS"); raw != "" {
+		words = strings.Split(raw, ",")
+	}
+
+	return filter.NewChain(
+		filter.NewProfanity(words),
+		filter.NewRepeat(
+			positiveDuration("FILTER_REPEAT_WINDOW", filter.DefaultRepeatWindow),
+			positiveInt("FILTER_MAX_REPEATS", filter.DefaultMaxRepeats),
+		),
+		filter.NewLinks(positiveInt("FILTER_MAX_LINKS", filter.DefaultMaxLinks)),
+	)
+}
+
+// loginLockout builds the failed login policies per username and per client address.
+// LOGIN_MAX_FAILURES and LOGIN_IP_MAX_FAILURES set how many failures lock them out, for LOGIN_LOCKOUT.
+func loginLockout() (user, ip lockout.Policy) {
+	user, ip = lockout.DefaultUserPolicy, lockout.DefaultIPPolicy
+	user.MaxFailures = positiveInt("LOGIN_MAX_FAILURES", user.MaxFailures)
+	ip.MaxFailures = positiveInt("LOGIN_IP_MAX_FAILURES", ip.MaxFailures)
+	user.Lockout = positiveDuration("LOGIN_LOCKOUT", user.Lockout)
+	ip.Lockout = user.Lockout
+	return user, ip
+}
+
+// rateLimit reads prefix_PER_MINUTE and prefix_BURST, keeping the default for any value that is unset or invalid.
+// A per-minute rate of 0 disables the limit.
+func rateLimit(prefix string, fallback ratelimit.Limit) ratelimit.Limit {
+	limit := fallback
+
+	if raw := os.Getenv(prefix + "_PER_MINUTE"); raw != "" {
+		perMinute, err := strconv.ParseFloat(raw, 64)
+		if err != nil || perMinute < 0 {
+			log.Printf("invalid %s_PER_MINUTE %q, using %g", prefix, raw, fallback.PerMinute)
+		} else {
+			limit.PerMinute = perMinute
+		}
+	}
+
+	if raw := os.Getenv(prefix + "_BURST"); raw != "" {
+		burst, err := strconv.Atoi(raw)
+		if err != nil || burst <= 0 {
+			log.Printf("invalid %s_BURST %q, using %d", prefix, raw, fallback.Burst)
+		} else {
+			limit.Burst = burst
+		}
+	}
+
+	return limit
 }
 
 func handleMethod(method string, handlerFunc http.HandlerFunc) http.HandlerFunc {
@@ -112,7 +447,7 @@ func handleMethod(method string, handlerFunc http.HandlerFunc) http.HandlerFunc
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == http.MethodOptions {
@@ -0,0 +1,54 @@
+// Command keygen creates a JWT signing key and schedules it in the keys file chat-service reads
+// from JWT_KEYS_FILE. Run it ahead of a rotation with -active-from set to when the new key
+// should take over; the current key keeps verifying the tokens it signed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt"
+)
+
+func main() {
+	dir := flag.String("dir", "keys", "directory holding the keys file and the PEM keys")
+	kid := flag.String("kid", time.Now().UTC().Format("20060102-150405"), "ID of the new key")
+	algorithm := flag.String("alg", jwt.AlgorithmEdDSA, "signing algorithm, RS256 or EdDSA")
+	activeFrom := flag.String("active-from", "", "RFC 3339 time the key starts signing at, now if empty")
+	flag.Parse()
+
+	from := time.Now().UTC().Truncate(time.Second)
+	if *activeFrom != "" {
+		parsed, err := time.Parse(time.RFC3339, *activeFrom)
+		if err != nil {
+			log.Fatalf("invalid -active-from: %v", err)
+		}
+		from = parsed
+	}
+
+	key, err := jwt.GenerateKey(*kid, *algorithm, from)
+	if err != nil {
+		log.Fatalf("generating key: %v", err)
+	}
+	pemBytes, err := jwt.MarshalPrivateKey(key.PrivateKey)
+	if err != nil {
+		log.Fatalf("encoding key: %v", err)
+	}
+
+	if err := os.MkdirAll(*dir, 0o700); err != nil {
+		log.Fatalf("creating %s: %v", *dir, err)
+	}
+	keyFile := key.ID + ".pem"
+	if err := os.WriteFile(filepath.Join(*dir, keyFile), pemBytes, 0o600); err != nil {
+		log.Fatalf("writing key: %v", err)
+	}
+	if err := jwt.AppendToKeysFile(filepath.Join(*dir, "keys.json"), key, keyFile); err != nil {
+		log.Fatalf("updating keys file: %v", err)
+	}
+
+	fmt.Printf("created %s key %q, active from %s\n", key.Algorithm(), key.ID, from.Format(time.RFC3339))
+}
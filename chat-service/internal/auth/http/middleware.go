@@ -3,47 +3,97 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
 )
 
 type contextKey string
 
 const (
 	UserIDKey contextKey = "user_id"
+	RoleKey   contextKey = "role"
 	ClaimsKey contextKey = "claims"
 )
 
 func AuthMiddleware(jwtService *jwt.JWTService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			if r.Header.Get("Authorization") == "" {
 				writeJSONError(w, http.StatusUnauthorized, "Authorization header missing")
 				return
 			}
 
-			parts := strings.SplitN(authHeader, " ", 2)
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+			tokenString, ok := BearerToken(r)
+			if !ok {
 				writeJSONError(w, http.StatusUnauthorized, "Invalid Authorization header format")
 				return
 			}
 
-			tokenString := parts[1]
 			claims, err := jwtService.ValidateToken(tokenString)
+			if errors.Is(err, jwt.ErrSessionRevoked) {
+				writeJSONError(w, http.StatusUnauthorized, "Session revoked")
+				return
+			}
 			if err != nil {
 				writeJSONError(w, http.StatusUnauthorized, "Invalid token")
 				return
 			}
 
+			role := claims.Role
+			if role == "" {
+				role = roles.Member
+			}
+
 			ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, RoleKey, role)
 			ctx = context.WithValue(ctx, ClaimsKey, claims)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+// BearerToken returns the token of the request's "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (string, bool) {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// RequireRole only lets through requests whose token carries one of allowed. It must be
+// wrapped by AuthMiddleware, which puts the role in the request context.
+func RequireRole(allowed ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(RoleKey).(string)
+			if !slices.Contains(allowed, role) {
+				writeJSONError(w, http.StatusForbidden, "Insufficient role")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// JWKSHandler serves the public signing keys at /.well-known/jwks.json, so other services can
+// verify access tokens without holding any secret. Verifiers refetch it when they meet an
+// unknown kid; the short cache lets scheduled keys reach them before they sign anything.
+func JWKSHandler(jwtService *jwt.JWTService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(jwtService.JWKS(time.Now()))
+	}
+}
+
 func writeJSONError(w http.ResponseWriter, status int, msg string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -8,13 +8,16 @@ import (
 
 	authhttp "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/http"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt"
+	sessionmocks "github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/repository/mocks"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func Test_AuthMiddleware(t *testing.T) {
-	jwtService := jwt.NewJWTService("secret", time.Minute*5)
-	validToken, err := jwtService.GenerateToken("user123", "test")
+	jwtService := jwt.NewJWTService(testKeys(t), time.Minute*5)
+	validToken, err := jwtService.GenerateToken("user123", "test", roles.Member, "session1")
 	if err != nil {
 		t.Fatalf("failed to generate valid token: %v", err)
 	}
@@ -68,3 +71,119 @@ func Test_AuthMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func Test_RequireRole(t *testing.T) {
+	jwtService := jwt.NewJWTService(testKeys(t), time.Minute*5)
+
+	tests := []struct {
+		name               string
+		role               string
+		expectedStatusCode int
+	}{
+		{
+			name:               "Given an admin token, When an admin-only request is made, Then respond with 200",
+			role:               roles.Admin,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given a member token, When an admin-only request is made, Then respond with 403",
+			role:               roles.Member,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Given a token without a role, When an admin-only request is made, Then respond with 403",
+			role:               "",
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwtService.GenerateToken("user123", "test", tt.role, "session1")
+			if err != nil {
+				t.Fatalf("failed to generate token: %v", err)
+			}
+
+			finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handlerToTest := authhttp.AuthMiddleware(jwtService)(authhttp.RequireRole(roles.Admin)(finalHandler))
+
+			req := httptest.NewRequest(http.MethodPut, "/users/user456/role", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := httptest.NewRecorder()
+			handlerToTest.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+		})
+	}
+}
+
+func Test_AuthMiddleware_Sessions(t *testing.T) {
+	sessions := new(sessionmocks.MockRepository)
+	sessions.On("IsActive", mock.Anything, "active").Return(true, nil)
+	sessions.On("IsActive", mock.Anything, "revoked").Return(false, nil)
+	jwtService := jwt.NewJWTService(testKeys(t), time.Minute*5, jwt.WithSessions(sessions))
+
+	tests := []struct {
+		name               string
+		sessionID          string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "Given a token of an active session, When request is made, Then respond with 200",
+			sessionID:          "active",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Given a token of a revoked session, When request is made, Then respond with 401",
+			sessionID:          "revoked",
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedBody:       "Session revoked",
+		},
+		{
+			name:               "Given a token without a session, When request is made, Then respond with 401",
+			sessionID:          "",
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedBody:       "Session revoked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwtService.GenerateToken("user123", "test", roles.Member, tt.sessionID)
+			if err != nil {
+				t.Fatalf("failed to generate token: %v", err)
+			}
+
+			finalHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			handlerToTest := authhttp.AuthMiddleware(jwtService)(finalHandler)
+
+			req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := httptest.NewRecorder()
+			handlerToTest.ServeHTTP(rr, req)
+			assert.Equal(t, tt.expectedStatusCode, rr.Code)
+			assert.Contains(t, rr.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+// testKeys returns a key set holding a single Ed25519 key, active now.
+func testKeys(t *testing.T) *jwt.KeySet {
+	t.Helper()
+
+	key, err := jwt.GenerateKey("test", jwt.AlgorithmEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	keys, err := jwt.NewKeySet(key)
+	if err != nil {
+		t.Fatalf("failed to build key set: %v", err)
+	}
+	return keys
+}
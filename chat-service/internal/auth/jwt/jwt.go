@@ -1,25 +1,55 @@
 package jwt
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/model"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/port"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// ErrSessionRevoked is returned by ValidateToken for a well-formed token whose session has
+// been revoked or has expired.
+var ErrSessionRevoked = errors.New("session revoked")
+
+// JWTService signs access tokens with the active key of a KeySet and verifies them with
+// whichever key their kid header names.
 type JWTService struct {
-	secretKey string
-	expiry    time.Duration
+	keys     *KeySet
+	expiry   time.Duration
+	sessions port.SessionChecker
 }
 
-func NewJWTService(secretKey string, expiry time.Duration) *JWTService {
-	return &JWTService{
-		secretKey: secretKey,
-		expiry:    expiry,
+type Option func(*JWTService)
+
+// WithSessions makes ValidateToken reject tokens whose session is no longer active in checker,
+// so a logout takes effect before the token expires.
+func WithSessions(checker port.SessionChecker) Option {
+	return func(s *JWTService) {
+		s.sessions = checker
 	}
 }
 
-func (s *JWTService) GenerateToken(userID string, username string) (string, error) {
+func NewJWTService(keys *KeySet, expiry time.Duration, opts ...Option) *JWTService {
+	s := &JWTService{
+		keys:   keys,
+		expiry: expiry,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Expiry is how long the access tokens issued by GenerateToken are valid for.
+func (s *JWTService) Expiry() time.Duration {
+	return s.expiry
+}
+
+func (s *JWTService) GenerateToken(userID string, username string, role string, sessionID string) (string, error) {
 	now := time.Now()
 
 	claims := model.CustomClaims{
@@ -28,25 +58,58 @@ func (s *JWTService) GenerateToken(userID string, username string) (string, erro
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
 		},
-		UserID:   userID,
-		Username: username,
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
+	}
+
+	key, err := s.keys.Active(now)
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.secretKey))
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
 }
 
 func (s *JWTService) ValidateToken(tokenString string) (*model.CustomClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &model.CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.secretKey), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := s.keys.Find(kid, time.Now())
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		// The algorithm comes from the key, never from the token, so a token cannot pick a
+		// weaker one.
+		if token.Method.Alg() != key.Algorithm() {
+			return nil, fmt.Errorf("signing key %q does not use %s", kid, token.Method.Alg())
+		}
+		return key.PrivateKey.Public(), nil
+	}, jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA}))
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*model.CustomClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*model.CustomClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
+	if s.sessions != nil {
+		// Tokens without a session predate sessions and cannot be revoked, so they are refused.
+		if claims.SessionID == "" {
+			return nil, ErrSessionRevoked
+		}
+		active, err := s.sessions.IsActive(context.Background(), claims.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, ErrSessionRevoked
+		}
 	}
 
-	return nil, jwt.ErrTokenInvalidClaims
+	return claims, nil
 }
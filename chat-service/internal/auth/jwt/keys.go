@@ -0,0 +1,274 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+
+	// minRSABits is the smallest RSA key accepted for signing.
+	minRSABits = 2048
+)
+
+// Key is a signing key identified by ID, which tokens carry in their kid header. It signs new
+// tokens from ActiveFrom on, until a key with a later ActiveFrom takes over; tokens it signed
+// stay verifiable until RetireAt, if set.
+type Key struct {
+	ID         string
+	PrivateKey crypto.Signer
+	ActiveFrom time.Time
+	RetireAt   *time.Time
+}
+
+// Algorithm is the JWS algorithm the key signs with, derived from its type.
+func (k Key) Algorithm() string {
+	if _, ok := k.PrivateKey.(ed25519.PrivateKey); ok {
+		return AlgorithmEdDSA
+	}
+	return AlgorithmRS256
+}
+
+func (k Key) method() jwt.SigningMethod {
+	if k.Algorithm() == AlgorithmEdDSA {
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+func (k Key) retired(now time.Time) bool {
+	return k.RetireAt != nil && !now.Before(*k.RetireAt)
+}
+
+// KeySet holds every key tokens may be signed or verified with. Keys are rotated on schedule:
+// the active key is the one with the latest ActiveFrom that has been reached. Keys scheduled
+// for the future are already published, so verifiers know them before the first token arrives.
+type KeySet struct {
+	keys []Key
+}
+
+// NewKeySet validates keys and fails if there are none, so a service cannot start signing with
+// missing key material.
+func NewKeySet(keys ...Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing key without an ID")
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate signing key ID %q", key.ID)
+		}
+		seen[key.ID] = true
+
+		switch private := key.PrivateKey.(type) {
+		case ed25519.PrivateKey:
+		case *rsa.PrivateKey:
+			if private.N.BitLen() < minRSABits {
+				return nil, fmt.Errorf("signing key %q: RSA keys must have at least %d bits", key.ID, minRSABits)
+			}
+		default:
+			return nil, fmt.Errorf("signing key %q: only RSA and Ed25519 keys are supported", key.ID)
+		}
+	}
+
+	sorted := slices.Clone(keys)
+	slices.SortStableFunc(sorted, func(a, b Key) int { return a.ActiveFrom.Compare(b.ActiveFrom) })
+	return &KeySet{keys: sorted}, nil
+}
+
+// Active returns the key to sign with at now.
+func (s *KeySet) Active(now time.Time) (Key, error) {
+	for i := len(s.keys) - 1; i >= 0; i-- {
+		key := s.keys[i]
+		if !key.ActiveFrom.After(now) && !key.retired(now) {
+			return key, nil
+		}
+	}
+	return Key{}, errors.New("no signing key is active")
+}
+
+// Find returns the key with id, as long as it is not retired at now.
+func (s *KeySet) Find(id string, now time.Time) (Key, bool) {
+	for _, key := range s.keys {
+		if key.ID == id && !key.retired(now) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Published returns the keys verifiers should trust at now: every key that is not retired.
+func (s *KeySet) Published(now time.Time) []Key {
+	var keys []Key
+	for _, key := range s.keys {
+		if !key.retired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// manifestEntry is one key in the keys file read by LoadKeySet. Relative paths are resolved
+// against the directory of the keys file.
+type manifestEntry struct {
+	ID         string     `json:"kid"`
+	Path       string     `json:"path"`
+	ActiveFrom time.Time  `json:"active_from"`
+	RetireAt   *time.Time `json:"retire_at,omitempty"`
+}
+
+type manifest struct {
+	Keys []manifestEntry `json:"keys"`
+}
+
+// LoadKeySet reads the keys file at path: a JSON document listing each key's kid, the path of
+// its PEM encoded private key, when it becomes active and, optionally, when it is retired.
+func LoadKeySet(path string) (*KeySet, error) {
+	if path == "" {
+		return nil, errors.New("no keys file configured")
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading keys file: %w", err)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, fmt.Errorf("parsing keys file: %w", err)
+	}
+
+	keys := make([]Key, 0, len(m.Keys))
+	for _, entry := range m.Keys {
+		keyPath := entry.Path
+		if !filepath.IsAbs(keyPath) {
+			keyPath = filepath.Join(filepath.Dir(path), keyPath)
+		}
+
+		pemBytes, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", entry.ID, err)
+		}
+		private, err := ParsePrivateKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", entry.ID, err)
+		}
+
+		keys = append(keys, Key{
+			ID:         entry.ID,
+			PrivateKey: private,
+			ActiveFrom: entry.ActiveFrom,
+			RetireAt:   entry.RetireAt,
+		})
+	}
+
+	return NewKeySet(keys...)
+}
+
+// AppendToKeysFile adds key, stored at keyPath, to the keys file at path, creating the file if
+// it does not exist yet.
+func AppendToKeysFile(path string, key Key, keyPath string) error {
+	var m manifest
+	raw, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("reading keys file: %w", err)
+	default:
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return fmt.Errorf("parsing keys file: %w", err)
+		}
+	}
+
+	for _, entry := range m.Keys {
+		if entry.ID == key.ID {
+			return fmt.Errorf("keys file already has a key %q", key.ID)
+		}
+	}
+	m.Keys = append(m.Keys, manifestEntry{
+		ID:         key.ID,
+		Path:       keyPath,
+		ActiveFrom: key.ActiveFrom,
+		RetireAt:   key.RetireAt,
+	})
+
+	out, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0o600)
+}
+
+// GenerateKey creates a new key of the given algorithm, active from activeFrom.
+func GenerateKey(id, algorithm string, activeFrom time.Time) (Key, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, minRSABits)
+	default:
+		return Key{}, fmt.Errorf("unsupported algorithm %q, use %s or %s", algorithm, AlgorithmRS256, AlgorithmEdDSA)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{ID: id, PrivateKey: private, ActiveFrom: activeFrom}, nil
+}
+
+// MarshalPrivateKey PEM encodes a private key as PKCS #8.
+func MarshalPrivateKey(private crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKey decodes a PEM encoded RSA or Ed25519 private key, in PKCS #8 or, for RSA,
+// PKCS #1 form.
+func ParsePrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch private := parsed.(type) {
+	case *rsa.PrivateKey:
+		return private, nil
+	case ed25519.PrivateKey:
+		return private, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+}
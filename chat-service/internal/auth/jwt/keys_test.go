@@ -0,0 +1,221 @@
+package jwt
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/model"
+)
+
+func generateKey(t *testing.T, id, algorithm string, activeFrom time.Time) Key {
+	t.Helper()
+
+	key, err := GenerateKey(id, algorithm, activeFrom)
+	require.NoError(t, err)
+	return key
+}
+
+func TestKeySet_Active(t *testing.T) {
+	now := time.Now()
+	retiredAt := now.Add(-time.Minute)
+	keys, err := NewKeySet(
+		generateKey(t, "next", AlgorithmEdDSA, now.Add(24*time.Hour)),
+		generateKey(t, "old", AlgorithmEdDSA, now.Add(-48*time.Hour)),
+		generateKey(t, "current", AlgorithmEdDSA, now.Add(-time.Hour)),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		wantKID string
+		wantErr bool
+	}{
+		{
+			name:    "Given keys scheduled over time, When Active is called now, Then the latest key already active is returned",
+			at:      now,
+			wantKID: "current",
+		},
+		{
+			name:    "Given a key scheduled for tomorrow, When Active is called after its activation, Then it takes over",
+			at:      now.Add(25 * time.Hour),
+			wantKID: "next",
+		},
+		{
+			name:    "Given no key is active yet, When Active is called, Then an error is returned",
+			at:      now.Add(-72 * time.Hour),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := keys.Active(tt.at)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantKID, key.ID)
+		})
+	}
+
+	t.Run("Given the active key is retired, When Active is called, Then the previous key is used", func(t *testing.T) {
+		retired := generateKey(t, "current", AlgorithmEdDSA, now.Add(-time.Hour))
+		retired.RetireAt = &retiredAt
+		keys, err := NewKeySet(generateKey(t, "old", AlgorithmEdDSA, now.Add(-48*time.Hour)), retired)
+		require.NoError(t, err)
+
+		key, err := keys.Active(now)
+		require.NoError(t, err)
+		assert.Equal(t, "old", key.ID)
+	})
+}
+
+func TestNewKeySet(t *testing.T) {
+	key := generateKey(t, "a", AlgorithmEdDSA, time.Now())
+
+	tests := []struct {
+		name string
+		keys []Key
+	}{
+		{
+			name: "Given no keys, When NewKeySet is called, Then an error is returned",
+			keys: nil,
+		},
+		{
+			name: "Given two keys with the same ID, When NewKeySet is called, Then an error is returned",
+			keys: []Key{key, key},
+		},
+		{
+			name: "Given a key without an ID, When NewKeySet is called, Then an error is returned",
+			keys: []Key{{PrivateKey: key.PrivateKey}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeySet(tt.keys...)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestJWTService_Rotation(t *testing.T) {
+	now := time.Now()
+	old := generateKey(t, "old", AlgorithmRS256, now.Add(-time.Hour))
+	oldOnly, err := NewKeySet(old)
+	require.NoError(t, err)
+	signedByOld, err := NewJWTService(oldOnly, time.Minute).GenerateToken("user1", "alice", "member", "session1")
+	require.NoError(t, err)
+
+	rotated, err := NewKeySet(old, generateKey(t, "new", AlgorithmEdDSA, now.Add(-time.Minute)))
+	require.NoError(t, err)
+	service := NewJWTService(rotated, time.Minute)
+
+	t.Run("Given a token signed before the rotation, When it is validated, Then the old key still verifies it", func(t *testing.T) {
+		claims, err := service.ValidateToken(signedByOld)
+		require.NoError(t, err)
+		assert.Equal(t, "user1", claims.UserID)
+	})
+
+	t.Run("Given a new token, When it is generated, Then it is signed by the new key", func(t *testing.T) {
+		token, err := service.GenerateToken("user1", "alice", "member", "session1")
+		require.NoError(t, err)
+
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, &model.CustomClaims{})
+		require.NoError(t, err)
+		assert.Equal(t, "new", parsed.Header["kid"])
+		assert.Equal(t, AlgorithmEdDSA, parsed.Method.Alg())
+	})
+
+	t.Run("Given the old key is retired, When a token it signed is validated, Then it is rejected", func(t *testing.T) {
+		retireAt := now.Add(-time.Second)
+		retiredOld := old
+		retiredOld.RetireAt = &retireAt
+		keys, err := NewKeySet(retiredOld, generateKey(t, "new", AlgorithmEdDSA, now.Add(-time.Minute)))
+		require.NoError(t, err)
+
+		_, err = NewJWTService(keys, time.Minute).ValidateToken(signedByOld)
+		assert.Error(t, err)
+	})
+
+	t.Run("Given a token of an unknown key, When it is validated, Then it is rejected", func(t *testing.T) {
+		other, err := NewKeySet(generateKey(t, "other", AlgorithmEdDSA, now.Add(-time.Minute)))
+		require.NoError(t, err)
+		token, err := NewJWTService(other, time.Minute).GenerateToken("user1", "alice", "member", "session1")
+		require.NoError(t, err)
+
+		_, err = service.ValidateToken(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("Given an HS256 token naming an RSA key, When it is validated, Then it is rejected", func(t *testing.T) {
+		// The public key is published, so it is what an attacker would use as the HMAC secret.
+		publicDER, err := x509.MarshalPKIXPublicKey(old.PrivateKey.Public())
+		require.NoError(t, err)
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, model.CustomClaims{UserID: "admin"})
+		forged.Header["kid"] = "old"
+		token, err := forged.SignedString(publicDER)
+		require.NoError(t, err)
+
+		_, err = service.ValidateToken(token)
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadKeySet(t *testing.T) {
+	t.Run("Given a keys file written by keygen, When it is loaded, Then its keys sign and verify tokens", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "keys.json")
+		for _, key := range []Key{
+			generateKey(t, "ed", AlgorithmEdDSA, time.Now().Add(-time.Hour)),
+			generateKey(t, "rsa", AlgorithmRS256, time.Now().Add(time.Hour)),
+		} {
+			pemBytes, err := MarshalPrivateKey(key.PrivateKey)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, key.ID+".pem"), pemBytes, 0o600))
+			require.NoError(t, AppendToKeysFile(path, key, key.ID+".pem"))
+		}
+
+		keys, err := LoadKeySet(path)
+		require.NoError(t, err)
+
+		service := NewJWTService(keys, time.Minute)
+		token, err := service.GenerateToken("user1", "alice", "member", "session1")
+		require.NoError(t, err)
+		_, err = service.ValidateToken(token)
+		assert.NoError(t, err)
+
+		jwks := service.JWKS(time.Now())
+		require.Len(t, jwks.Keys, 2)
+		assert.Equal(t, JWK{KeyType: "OKP", KeyID: "ed", Use: "sig", Algorithm: AlgorithmEdDSA, Curve: "Ed25519", X: jwks.Keys[0].X}, jwks.Keys[0])
+		assert.NotEmpty(t, jwks.Keys[0].X)
+		assert.Equal(t, "RSA", jwks.Keys[1].KeyType)
+		assert.Equal(t, "AQAB", jwks.Keys[1].E)
+		assert.NotEmpty(t, jwks.Keys[1].N)
+	})
+
+	t.Run("Given no keys file, When LoadKeySet is called, Then an error is returned", func(t *testing.T) {
+		_, err := LoadKeySet("")
+		assert.Error(t, err)
+
+		_, err = LoadKeySet(filepath.Join(t.TempDir(), "missing.json"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Given an empty keys file, When LoadKeySet is called, Then an error is returned", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"keys": []}`), 0o600))
+
+		_, err := LoadKeySet(path)
+		assert.Error(t, err)
+	})
+}
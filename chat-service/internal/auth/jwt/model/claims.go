@@ -6,4 +6,7 @@ type CustomClaims struct {
 	jwt.RegisteredClaims
 	UserID   string `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role"`
+	// SessionID ties the token to the login it was issued for, so it can be revoked.
+	SessionID string `json:"sid"`
 }
@@ -1,10 +1,19 @@
 package port
 
 import (
+	"context"
+	"time"
+
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/model"
 )
 
 type TokenService interface {
-	GenerateToken(userID string, username string) (string, error)
+	GenerateToken(userID string, username string, role string, sessionID string) (string, error)
 	ValidateToken(token string) (*model.CustomClaims, error)
+	Expiry() time.Duration
+}
+
+// SessionChecker reports whether the session an access token belongs to can still be used.
+type SessionChecker interface {
+	IsActive(ctx context.Context, sessionID string) (bool, error)
 }
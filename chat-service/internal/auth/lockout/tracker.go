@@ -0,0 +1,147 @@
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepThreshold is how many keys a Tracker holds before dropping the ones with nothing to remember.
+const sweepThreshold = 1024
+
+// Policy describes how failed attempts are throttled. After each failure the key has to wait
+// BaseDelay, doubled for every further failure up to MaxDelay, before trying again; after
+// MaxFailures failures within Window it is locked out for Lockout. A Policy with
+// MaxFailures <= 0 tracks nothing.
+type Policy struct {
+	MaxFailures int
+	Window      time.Duration
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Lockout     time.Duration
+}
+
+// DefaultUserPolicy applies to failed logins for one username: waits of 1s, 2s, 4s and 8s, then
+// a 15 minute lockout.
+var DefaultUserPolicy = Policy{
+	MaxFailures: 5,
+	Window:      15 * time.Minute,
+	BaseDelay:   time.Second,
+	MaxDelay:    30 * time.Second,
+	Lockout:     15 * time.Minute,
+}
+
+// DefaultIPPolicy applies to failed logins from one address, whatever the username. It has no
+// delays and a higher limit, since several people may share an address.
+var DefaultIPPolicy = Policy{
+	MaxFailures: 20,
+	Window:      15 * time.Minute,
+	Lockout:     15 * time.Minute,
+}
+
+// Tracker counts failed attempts per key, such as a username or an IP address.
+type Tracker struct {
+	policy Policy
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	failures     int
+	firstFailure time.Time
+	retryAt      time.Time
+	lockedUntil  time.Time
+}
+
+func NewTracker(policy Policy) *Tracker {
+	return &Tracker{
+		policy:  policy,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Check reports how long key has to wait before its next attempt, zero if it may try now, and
+// whether the wait is a lockout rather than a delay between attempts.
+func (t *Tracker) Check(key string) (wait time.Duration, locked bool) {
+	if t.policy.MaxFailures <= 0 {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		return 0, false
+	}
+
+	now := t.now()
+	if now.Before(e.lockedUntil) {
+		return e.lockedUntil.Sub(now), true
+	}
+	if now.Before(e.retryAt) {
+		return e.retryAt.Sub(now), false
+	}
+	return 0, false
+}
+
+// Fail records a failed attempt for key and reports whether it locked key out.
+func (t *Tracker) Fail(key string) bool {
+	if t.policy.MaxFailures <= 0 {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	e, ok := t.entries[key]
+	if !ok {
+		if len(t.entries) >= sweepThreshold {
+			t.sweep(now)
+		}
+		e = &entry{}
+		t.entries[key] = e
+	}
+
+	if e.failures == 0 || now.Sub(e.firstFailure) > t.policy.Window {
+		e.failures = 0
+		e.firstFailure = now
+	}
+	e.failures++
+
+	if e.failures >= t.policy.MaxFailures {
+		e.failures = 0
+		e.retryAt = time.Time{}
+		e.lockedUntil = now.Add(t.policy.Lockout)
+		return true
+	}
+
+	if t.policy.BaseDelay > 0 {
+		delay := t.policy.BaseDelay << (e.failures - 1)
+		if t.policy.MaxDelay > 0 && (delay > t.policy.MaxDelay || delay <= 0) {
+			delay = t.policy.MaxDelay
+		}
+		e.retryAt = now.Add(delay)
+	}
+	return false
+}
+
+// Reset forgets key's failures, as after a successful attempt.
+func (t *Tracker) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// sweep drops the keys that are neither locked, delayed nor within the window of a failure.
+func (t *Tracker) sweep(now time.Time) {
+	for key, e := range t.entries {
+		if now.After(e.lockedUntil) && now.After(e.retryAt) && now.Sub(e.firstFailure) > t.policy.Window {
+			delete(t.entries, key)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package lockout
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_ProgressiveDelays(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(Policy{MaxFailures: 4, Window: time.Hour, BaseDelay: time.Second, MaxDelay: 3 * time.Second, Lockout: time.Minute})
+	tracker.now = func() time.Time { return now }
+
+	wait, locked := tracker.Check("alice")
+	assert.Zero(t, wait, "no failures yet")
+	assert.False(t, locked)
+
+	assert.False(t, tracker.Fail("alice"))
+	wait, locked = tracker.Check("alice")
+	assert.Equal(t, time.Second, wait, "first failure waits the base delay")
+	assert.False(t, locked)
+
+	now = now.Add(time.Second)
+	assert.False(t, tracker.Fail("alice"))
+	wait, _ = tracker.Check("alice")
+	assert.Equal(t, 2*time.Second, wait, "the delay doubles")
+
+	now = now.Add(2 * time.Second)
+	assert.False(t, tracker.Fail("alice"))
+	wait, _ = tracker.Check("alice")
+	assert.Equal(t, 3*time.Second, wait, "the delay is capped")
+
+	wait, _ = tracker.Check("bob")
+	assert.Zero(t, wait, "failures are per key")
+
+	now = now.Add(3 * time.Second)
+	assert.True(t, tracker.Fail("alice"), "the fourth failure locks the key")
+	wait, locked = tracker.Check("alice")
+	assert.Equal(t, time.Minute, wait)
+	assert.True(t, locked)
+
+	now = now.Add(time.Minute)
+	wait, locked = tracker.Check("alice")
+	assert.Zero(t, wait, "the lockout ends")
+	assert.False(t, locked)
+}
+
+func TestTracker_WindowAndReset(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(Policy{MaxFailures: 2, Window: time.Minute, Lockout: time.Minute})
+	tracker.now = func() time.Time { return now }
+
+	assert.False(t, tracker.Fail("alice"))
+	now = now.Add(2 * time.Minute)
+	assert.False(t, tracker.Fail("alice"), "failures outside the window are forgotten")
+
+	tracker.Reset("alice")
+	assert.False(t, tracker.Fail("alice"), "a reset forgets failures")
+	assert.True(t, tracker.Fail("alice"))
+}
+
+func TestTracker_Disabled(t *testing.T) {
+	tracker := NewTracker(Policy{})
+	for range 100 {
+		assert.False(t, tracker.Fail("alice"))
+	}
+	wait, locked := tracker.Check("alice")
+	assert.Zero(t, wait)
+	assert.False(t, locked)
+}
+
+func TestTracker_Sweep(t *testing.T) {
+	now := time.Now()
+	tracker := NewTracker(Policy{MaxFailures: 3, Window: time.Minute, Lockout: time.Minute})
+	tracker.now = func() time.Time { return now }
+
+	for i := range sweepThreshold {
+		tracker.Fail(strconv.Itoa(i))
+	}
+	now = now.Add(2 * time.Minute)
+	tracker.Fail("alice")
+
+	assert.Len(t, tracker.entries, 1, "expired entries are swept")
+}
@@ -0,0 +1,94 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHOTP_RFC6238Vectors(t *testing.T) {
+	// The SHA1 test vectors of RFC 6238, appendix B.
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run("Given the RFC key, When the code at "+tt.want+" is generated, Then it matches the RFC", func(t *testing.T) {
+			assert.Equal(t, tt.want, hotp(key, uint64(Step(time.Unix(tt.unix, 0))), 8))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	now := time.Now()
+
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+	previous, err := Code(secret, now.Add(-Period))
+	require.NoError(t, err)
+	stale, err := Code(secret, now.Add(-3*Period))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{
+			name:     "Given the current code, When it is validated, Then it matches the current step",
+			code:     code,
+			wantStep: Step(now),
+			wantOK:   true,
+		},
+		{
+			name:     "Given the code of the previous period, When it is validated, Then it is accepted for clock drift",
+			code:     previous,
+			wantStep: Step(now) - 1,
+			wantOK:   true,
+		},
+		{
+			name: "Given a code from three periods ago, When it is validated, Then it is refused",
+			code: stale,
+		},
+		{
+			name: "Given a code of the wrong length, When it is validated, Then it is refused",
+			code: code[:5],
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(secret, tt.code, now)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.wantStep, step)
+			}
+		})
+	}
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("Financial Chat", "alice", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Financial%20Chat:alice?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Financial%20Chat")
+	assert.Contains(t, uri, "digits=6")
+	assert.Contains(t, uri, "period=30")
+}
@@ -1,7 +1,16 @@
 package broker
 
+import "context"
+
+// Handler processes a single message. Its context expires after the consumer's message timeout
+// and is cancelled when the consumer is closed.
+type Handler func(ctx context.Context, message string) error
+
 type Consumer interface {
-	Subscribe(queue string, handler func(message string) error) error
+	Subscribe(queue string, handler Handler) error
+	// Drain stops consuming and waits until in-flight handlers return or ctx is done.
+	// Messages that were not handled are left in their queue.
+	Drain(ctx context.Context) error
 	Close() error
 }
 
@@ -9,3 +18,9 @@ type Producer interface {
 	Publish(queue string, message string) error
 	Close() error
 }
+
+// Broker is a connection that can both publish and consume messages.
+type Broker interface {
+	Producer
+	Consumer
+}
@@ -0,0 +1,49 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// inFlight tracks the handlers a consumer is running so Drain can wait for them.
+// Once draining starts no new handler may begin; the message should be left in the queue instead.
+type inFlight struct {
+	mu       sync.Mutex
+	draining bool
+	wg       sync.WaitGroup
+}
+
+// start reports whether a message may be handled and, if so, registers it as in flight.
+func (f *inFlight) start() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.draining {
+		return false
+	}
+	f.wg.Add(1)
+	return true
+}
+
+func (f *inFlight) done() {
+	f.wg.Done()
+}
+
+func (f *inFlight) drain(ctx context.Context) error {
+	f.mu.Lock()
+	f.draining = true
+	f.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
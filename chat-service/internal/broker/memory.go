@@ -0,0 +1,120 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+)
+
+const memoryQueueSize = 1024
+
+var (
+	ErrBrokerClosed = errors.New("broker is closed")
+	ErrQueueFull    = errors.New("queue is full")
+)
+
+// MemoryBroker is an in-process implementation of Producer and Consumer.
+// Messages published to a queue with no subscribers are buffered until one subscribes,
+// and subscribers on the same queue compete for messages, as they do on a RabbitMQ queue.
+type MemoryBroker struct {
+	mu       sync.Mutex
+	queues   map[string]chan string
+	done     chan struct{}
+	stopOnce sync.Once
+	closed   bool
+	inFlight inFlight
+	opts     options
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+func NewMemoryBroker(opts ...Option) *MemoryBroker {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MemoryBroker{
+		queues: make(map[string]chan string),
+		done:   make(chan struct{}),
+		opts:   newOptions(opts),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (m *MemoryBroker) Publish(queue string, message string) error {
+	q, err := m.queue(queue)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case q <- message:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (m *MemoryBroker) Subscribe(queue string, handler Handler) error {
+	q, err := m.queue(queue)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			select {
+			case <-m.done:
+				return
+			case msg := <-q:
+				if !m.inFlight.start() {
+					select {
+					case q <- msg:
+					default:
+						log.Printf("dropping message on queue %s: %v", queue, ErrQueueFull)
+					}
+					return
+				}
+
+				ctx, cancel := context.WithTimeout(m.ctx, m.opts.messageTimeout)
+				if err := handler(ctx, msg); err != nil {
+					log.Printf("error handling message: %v", err)
+				}
+				cancel()
+				m.inFlight.done()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (m *MemoryBroker) Drain(ctx context.Context) error {
+	m.stopOnce.Do(func() { close(m.done) })
+	return m.inFlight.drain(ctx)
+}
+
+// Close stops consuming and cancels the contexts of handlers that are still running.
+func (m *MemoryBroker) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+
+	m.cancel()
+	return m.Drain(context.Background())
+}
+
+func (m *MemoryBroker) queue(name string) (chan string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrBrokerClosed
+	}
+
+	q, ok := m.queues[name]
+	if !ok {
+		q = make(chan string, memoryQueueSize)
+		m.queues[name] = q
+	}
+	return q, nil
+}
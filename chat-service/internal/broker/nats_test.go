@@ -0,0 +1,108 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runNATSServer starts an in-process NATS server with JetStream on a random port.
+func runNATSServer(t *testing.T) string {
+	t.Helper()
+
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	require.NoError(t, err)
+
+	go ns.Start()
+	if !ns.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not start")
+	}
+	t.Cleanup(ns.Shutdown)
+
+	return ns.ClientURL()
+}
+
+func TestNATSBroker_PublishSubscribe(t *testing.T) {
+	url := runNATSServer(t)
+
+	nb, err := NewNATSBroker(url)
+	require.NoError(t, err)
+	defer nb.Close()
+
+	t.Run("Given messages published before subscribing, When Subscribe is called, Then they are delivered in order and acknowledged", func(t *testing.T) {
+		for _, msg := range []string{"first", "second"} {
+			assert.NoError(t, nb.Publish("queue", msg))
+		}
+
+		received := make(chan string, 2)
+		require.NoError(t, nb.Subscribe("queue", func(_ context.Context, message string) error {
+			received <- message
+			return nil
+		}))
+
+		for _, want := range []string{"first", "second"} {
+			select {
+			case got := <-received:
+				assert.Equal(t, want, got)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for %q", want)
+			}
+		}
+
+		consumer, err := nb.js.Consumer(context.Background(), "queue", "queue")
+		require.NoError(t, err)
+		assert.Equal(t, DefaultMessageTimeout+natsAckMargin, consumer.CachedInfo().Config.AckWait)
+
+		// Work-queue streams drop a message once it is acknowledged.
+		stream, err := nb.js.Stream(context.Background(), "queue")
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			info, err := stream.Info(context.Background())
+			return err == nil && info.State.Msgs == 0
+		}, 5*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("Given a queue already used, When publishing again, Then the stream is not declared again", func(t *testing.T) {
+		require.NoError(t, nb.js.DeleteStream(context.Background(), "queue"))
+
+		// The deleted stream stays deleted, so the publish has no stream to land in.
+		assert.Error(t, nb.Publish("queue", "third"))
+	})
+}
+
+func TestNATSBroker_Durability(t *testing.T) {
+	url := runNATSServer(t)
+
+	producer, err := NewNATSBroker(url)
+	require.NoError(t, err)
+	require.NoError(t, producer.Publish("queue", "kept"))
+	require.NoError(t, producer.Close())
+
+	consumer, err := NewNATSBroker(url)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	received := make(chan string, 1)
+	require.NoError(t, consumer.Subscribe("queue", func(_ context.Context, message string) error {
+		received <- message
+		return nil
+	}))
+
+	select {
+	case got := <-received:
+		assert.Equal(t, "kept", got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message published before the producer closed")
+	}
+}
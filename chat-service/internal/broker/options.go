@@ -0,0 +1,31 @@
+package broker
+
+import "time"
+
+// DefaultMessageTimeout is the deadline given to a handler's context when none is configured.
+const DefaultMessageTimeout = 30 * time.Second
+
+type Option func(*options)
+
+type options struct {
+	messageTimeout time.Duration
+}
+
+// WithMessageTimeout sets the deadline of the context passed to each message handler.
+func WithMessageTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if timeout > 0 {
+			o.messageTimeout = timeout
+		}
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		messageTimeout: DefaultMessageTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -1,17 +1,31 @@
 package broker
 
 import (
+	"context"
 	"log"
+	"sync"
 
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// rabbitMQPrefetch bounds how many unacknowledged deliveries a consumer holds,
+// which is also how many messages are handed back to the queue when draining.
+const rabbitMQPrefetch = 10
+
 type RabbitMQBroker struct {
 	conn    *amqp.Connection
 	channel *amqp.Channel
+	opts    options
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu           sync.Mutex
+	consumerTags []string
+	inFlight     inFlight
 }
 
-func NewRabbitMQBroker(url string) (*RabbitMQBroker, error) {
+func NewRabbitMQBroker(url string, opts ...Option) (*RabbitMQBroker, error) {
 	conn, err := amqp.Dial(url)
 	if err != nil {
 		return nil, err
@@ -23,9 +37,19 @@ func NewRabbitMQBroker(url string) (*RabbitMQBroker, error) {
 		return nil, err
 	}
 
+	if err := ch.Qos(rabbitMQPrefetch, 0, false); err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	return &RabbitMQBroker{
 		conn:    conn,
 		channel: ch,
+		opts:    newOptions(opts),
+		ctx:     ctx,
+		cancel:  cancel,
 	}, nil
 }
 
@@ -54,7 +78,7 @@ func (r *RabbitMQBroker) Publish(queue string, message string) error {
 	)
 }
 
-func (r *RabbitMQBroker) Subscribe(queue string, handler func(message string) error) error {
+func (r *RabbitMQBroker) Subscribe(queue string, handler Handler) error {
 	_, err := r.channel.QueueDeclare(
 		queue,
 		true,
@@ -67,10 +91,11 @@ func (r *RabbitMQBroker) Subscribe(queue string, handler func(message string) er
 		return err
 	}
 
+	consumerTag := uuid.NewString()
 	msgs, err := r.channel.Consume(
 		queue,
-		"",
-		true,
+		consumerTag,
+		false,
 		false,
 		false,
 		false,
@@ -80,18 +105,49 @@ func (r *RabbitMQBroker) Subscribe(queue string, handler func(message string) er
 		return err
 	}
 
+	r.mu.Lock()
+	r.consumerTags = append(r.consumerTags, consumerTag)
+	r.mu.Unlock()
+
 	go func() {
 		for d := range msgs {
-			if err := handler(string(d.Body)); err != nil {
+			if !r.inFlight.start() {
+				d.Nack(false, true)
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(r.ctx, r.opts.messageTimeout)
+			if err := handler(ctx, string(d.Body)); err != nil {
 				log.Printf("error handling message: %v", err)
 			}
+			cancel()
+
+			if err := d.Ack(false); err != nil {
+				log.Printf("error acknowledging message: %v", err)
+			}
+			r.inFlight.done()
 		}
 	}()
 
 	return nil
 }
 
+func (r *RabbitMQBroker) Drain(ctx context.Context) error {
+	r.mu.Lock()
+	for _, tag := range r.consumerTags {
+		if err := r.channel.Cancel(tag, false); err != nil {
+			log.Printf("error cancelling consumer %s: %v", tag, err)
+		}
+	}
+	r.consumerTags = nil
+	r.mu.Unlock()
+
+	return r.inFlight.drain(ctx)
+}
+
 func (r *RabbitMQBroker) Close() error {
+	r.cancel()
+
 	if r.channel != nil {
 		r.channel.Close()
 	}
@@ -0,0 +1,111 @@
+package filter
+
+import (
+	"slices"
+)
+
+const (
+	NameProfanity = "profanity"
+	NameRepeat    = "repeat"
+	NameLinks     = "links"
+
+	// ModeMask replaces the offending parts of a message when the filter can, and blocks it otherwise.
+	ModeMask = "mask"
+	// ModeBlock drops any message a filter hits.
+	ModeBlock = "block"
+)
+
+// IsKnown reports whether name is one of the filters provided by this package.
+func IsKnown(name string) bool {
+	return name == NameProfanity || name == NameRepeat || name == NameLinks
+}
+
+// Input is a message about to be stored and broadcast. Edit is set when Content replaces a
+// message already sent rather than being a new one.
+type Input struct {
+	RoomID  string
+	UserID  string
+	Content string
+	Edit    bool
+}
+
+// Result is what a filter found in a message. Masked holds the content with the offending parts
+// replaced, and is empty when the filter cannot mask what it found.
+type Result struct {
+	Hit    bool
+	Masked string
+	Reason string
+}
+
+type Filter interface {
+	Name() string
+	Apply(input Input) Result
+}
+
+// Config selects which filters of a chain run for a room and what happens on a hit.
+// A nil Filters runs every filter of the chain.
+type Config struct {
+	Filters []string
+	Mode    string
+}
+
+// Hit records a filter that matched a message.
+type Hit struct {
+	Filter string
+	Reason string
+}
+
+// Outcome is the result of running a message through a chain. Content is the message to store
+// and broadcast, possibly masked; it is meaningless when Blocked is set.
+type Outcome struct {
+	Content string
+	Blocked bool
+	Hits    []Hit
+}
+
+// Chain runs filters in the order they were given.
+type Chain struct {
+	filters []Filter
+}
+
+func NewChain(filters ...Filter) *Chain {
+	return &Chain{
+		filters: filters,
+	}
+}
+
+// Names returns the names of the chain's filters, in order.
+func (c *Chain) Names() []string {
+	names := make([]string, 0, len(c.filters))
+	for _, f := range c.filters {
+		names = append(names, f.Name())
+	}
+	return names
+}
+
+// Apply runs input through the filters enabled by cfg. In mask mode each hit masks the content
+// seen by the next filter; a hit that cannot be masked blocks the message, as any hit does in block mode.
+func (c *Chain) Apply(cfg Config, input Input) Outcome {
+	outcome := Outcome{Content: input.Content}
+
+	for _, f := range c.filters {
+		if cfg.Filters != nil && !slices.Contains(cfg.Filters, f.Name()) {
+			continue
+		}
+
+		input.Content = outcome.Content
+		result := f.Apply(input)
+		if !result.Hit {
+			continue
+		}
+
+		outcome.Hits = append(outcome.Hits, Hit{Filter: f.Name(), Reason: result.Reason})
+		if cfg.Mode == ModeBlock || result.Masked == "" {
+			outcome.Blocked = true
+			return outcome
+		}
+		outcome.Content = result.Masked
+	}
+
+	return outcome
+}
@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain_Apply(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		in   string
+		want Outcome
+	}{
+		{
+			name: "Given a clean message, When Apply is called, Then it passes unchanged",
+			cfg:  Config{Mode: ModeMask},
+			in:   "PETR4 is up",
+			want: Outcome{Content: "PETR4 is up"},
+		},
+		{
+			name: "Given a blocked word in mask mode, When Apply is called, Then the word is masked",
+			cfg:  Config{Mode: ModeMask},
+			in:   "this is Shit news",
+			want: Outcome{Content: "this is **** news", Hits: []Hit{{Filter: NameProfanity, Reason: "contains a blocked word"}}},
+		},
+		{
+			name: "Given a blocked word in block mode, When Apply is called, Then the message is blocked",
+			cfg:  Config{Mode: ModeBlock},
+			in:   "this is shit news",
+			want: Outcome{Content: "this is shit news", Blocked: true, Hits: []Hit{{Filter: NameProfanity, Reason: "contains a blocked word"}}},
+		},
+		{
+			name: "Given a blocked word with the profanity filter disabled, When Apply is called, Then it passes unchanged",
+			cfg:  Config{Filters: []string{NameLinks}, Mode: ModeBlock},
+			in:   "this is shit news",
+			want: Outcome{Content: "this is shit news"},
+		},
+		{
+			name: "Given a word containing a blocked word, When Apply is called, Then it is not masked",
+			cfg:  Config{Mode: ModeMask},
+			in:   "Scunthorpe shitake",
+			want: Outcome{Content: "Scunthorpe shitake"},
+		},
+		{
+			name: "Given too many links and a blocked word in mask mode, When Apply is called, Then both are masked",
+			cfg:  Config{Mode: ModeMask},
+			in:   "fuck http://a.io www.b.io https://c.io",
+			want: Outcome{
+				Content: "**** [link removed] [link removed] [link removed]",
+				Hits: []Hit{
+					{Filter: NameProfanity, Reason: "contains a blocked word"},
+					{Filter: NameLinks, Reason: "contains 3 links, the limit is 2"},
+				},
+			},
+		},
+		{
+			name: "Given links within the limit, When Apply is called, Then they are kept",
+			cfg:  Config{Mode: ModeMask},
+			in:   "see https://a.io and https://b.io",
+			want: Outcome{Content: "see https://a.io and https://b.io"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := NewChain(NewProfanity(DefaultBlockedWords), NewLinks(DefaultMaxLinks))
+
+			got := chain.Apply(tt.cfg, Input{RoomID: "stocks", UserID: "user1", Content: tt.in})
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRepeat_Apply(t *testing.T) {
+	now := time.Now()
+	repeat := NewRepeat(time.Minute, 2)
+	repeat.now = func() time.Time { return now }
+	chain := NewChain(repeat)
+	send := func(userID, content string) Outcome {
+		return chain.Apply(Config{Mode: ModeMask}, Input{RoomID: "stocks", UserID: userID, Content: content})
+	}
+
+	assert.False(t, send("user1", "buy PETR4").Blocked)
+	assert.False(t, send("user1", "Buy  PETR4").Blocked, "second identical message is allowed")
+	assert.False(t, send("user2", "buy PETR4").Blocked, "repeats are counted per user")
+
+	got := send("user1", "buy petr4")
+	assert.True(t, got.Blocked, "third identical message within the window is blocked even in mask mode")
+	assert.Equal(t, []Hit{{Filter: NameRepeat, Reason: "repeats a recent message"}}, got.Hits)
+
+	edit := chain.Apply(Config{Mode: ModeMask}, Input{RoomID: "stocks", UserID: "user2", Content: "buy PETR4", Edit: true})
+	assert.False(t, edit.Blocked, "edits are not counted as messages sent")
+	assert.False(t, send("user2", "buy PETR4").Blocked, "the edit did not count towards user2's repeats")
+
+	now = now.Add(2 * time.Minute)
+	assert.False(t, send("user1", "buy PETR4").Blocked, "messages outside the window are forgotten")
+}
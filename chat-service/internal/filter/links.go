@@ -0,0 +1,42 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultMaxLinks is how many links a message may carry before Links treats it as spam.
+const DefaultMaxLinks = 2
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// Links flags messages carrying more than maxLinks links and masks all of their links.
+type Links struct {
+	maxLinks int
+}
+
+func NewLinks(maxLinks int) *Links {
+	if maxLinks < 0 {
+		maxLinks = DefaultMaxLinks
+	}
+	return &Links{
+		maxLinks: maxLinks,
+	}
+}
+
+func (l *Links) Name() string {
+	return NameLinks
+}
+
+func (l *Links) Apply(input Input) Result {
+	links := linkPattern.FindAllStringIndex(input.Content, -1)
+	if len(links) <= l.maxLinks {
+		return Result{}
+	}
+
+	return Result{
+		Hit:    true,
+		Masked: linkPattern.ReplaceAllString(input.Content, "[link removed]"),
+		Reason: fmt.Sprintf("contains %d links, the limit is %d", len(links), l.maxLinks),
+	}
+}
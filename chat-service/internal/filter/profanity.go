@@ -0,0 +1,45 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultBlockedWords is the word list used by NewProfanity when none is configured.
+var DefaultBlockedWords = []string{"asshole", "bastard", "bitch", "bullshit", "cunt", "dickhead", "fuck", "fucking", "motherfucker", "shit"}
+
+// Profanity masks whole words from a word list, ignoring case.
+type Profanity struct {
+	pattern *regexp.Regexp
+}
+
+func NewProfanity(words []string) *Profanity {
+	quoted := make([]string, 0, len(words))
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+
+	p := &Profanity{}
+	if len(quoted) > 0 {
+		p.pattern = regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	}
+	return p
+}
+
+func (p *Profanity) Name() string {
+	return NameProfanity
+}
+
+func (p *Profanity) Apply(input Input) Result {
+	if p.pattern == nil || !p.pattern.MatchString(input.Content) {
+		return Result{}
+	}
+
+	masked := p.pattern.ReplaceAllStringFunc(input.Content, func(word string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	})
+	return Result{Hit: true, Masked: masked, Reason: "contains a blocked word"}
+}
@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRepeatWindow is how long Repeat remembers a user's messages.
+	DefaultRepeatWindow = time.Minute
+	// DefaultMaxRepeats is how many identical messages a user may send within the window.
+	DefaultMaxRepeats = 2
+
+	// repeatSweepThreshold is how many users Repeat tracks before forgetting the idle ones.
+	repeatSweepThreshold = 1024
+)
+
+// Repeat flags a user who sends the same message in a room more than maxRepeats times
+// within window. Repeats cannot be masked, so a hit always blocks the message. Edits are not
+// new messages, so they are neither counted nor flagged.
+type Repeat struct {
+	window     time.Duration
+	maxRepeats int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	recent map[repeatKey][]sent
+}
+
+type repeatKey struct {
+	roomID string
+	userID string
+}
+
+type sent struct {
+	content string
+	at      time.Time
+}
+
+func NewRepeat(window time.Duration, maxRepeats int) *Repeat {
+	if window <= 0 {
+		window = DefaultRepeatWindow
+	}
+	if maxRepeats < 1 {
+		maxRepeats = DefaultMaxRepeats
+	}
+
+	return &Repeat{
+		window:     window,
+		maxRepeats: maxRepeats,
+		now:        time.Now,
+		recent:     make(map[repeatKey][]sent),
+	}
+}
+
+func (r *Repeat) Name() string {
+	return NameRepeat
+}
+
+func (r *Repeat) Apply(input Input) Result {
+	if input.Edit {
+		return Result{}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	content := strings.ToLower(strings.Join(strings.Fields(input.Content), " "))
+	key := repeatKey{roomID: input.RoomID, userID: input.UserID}
+
+	if len(r.recent) >= repeatSweepThreshold {
+		r.sweep(now)
+	}
+	kept := r.withinWindow(r.recent[key], now)
+	repeats := 0
+	for _, previous := range kept {
+		if previous.content == content {
+			repeats++
+		}
+	}
+	r.recent[key] = append(kept, sent{content: content, at: now})
+
+	if repeats < r.maxRepeats {
+		return Result{}
+	}
+	return Result{Hit: true, Reason: "repeats a recent message"}
+}
+
+// withinWindow drops the messages sent before the window, which are sorted oldest first.
+func (r *Repeat) withinWindow(messages []sent, now time.Time) []sent {
+	cutoff := now.Add(-r.window)
+	i := 0
+	for i < len(messages) && !messages[i].at.After(cutoff) {
+		i++
+	}
+	return messages[i:]
+}
+
+// sweep forgets the users with no message left in the window.
+func (r *Repeat) sweep(now time.Time) {
+	for key, messages := range r.recent {
+		if len(r.withinWindow(messages, now)) == 0 {
+			delete(r.recent, key)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails, such as password reset links.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// validate refuses line breaks in header values, which would let a value add headers of its own.
+func (m Message) validate() error {
+	if m.To == "" {
+		return errors.New("mail: message has no recipient")
+	}
+	if strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return errors.New("mail: header values cannot contain line breaks")
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPMailer_Send(t *testing.T) {
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+
+	mailer := NewSMTPMailer(SMTPConfig{Host: "smtp.example.com", Port: "587", From: "Financial Chat <no-reply@example.com>"})
+	mailer.send = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotFrom, gotTo, gotMsg = addr, from, to, msg
+		return nil
+	}
+
+	t.Run("Given a message, When it is sent, Then the envelope uses the bare addresses and the body has CRLF line endings", func(t *testing.T) {
+		err := mailer.Send(context.Background(), Message{To: "alice@example.com", Subject: "Reset your password", Body: "line one\nline two"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "smtp.example.com:587", gotAddr)
+		assert.Equal(t, "no-reply@example.com", gotFrom)
+		assert.Equal(t, []string{"alice@example.com"}, gotTo)
+		assert.Contains(t, string(gotMsg), "From: \"Financial Chat\" <no-reply@example.com>\r\n")
+		assert.Contains(t, string(gotMsg), "Subject: Reset your password\r\n")
+		assert.True(t, strings.HasSuffix(string(gotMsg), "\r\n\r\nline one\r\nline two"))
+	})
+
+	t.Run("Given a subject with a line break, When it is sent, Then it is refused before reaching the relay", func(t *testing.T) {
+		gotMsg = nil
+		err := mailer.Send(context.Background(), Message{To: "alice@example.com", Subject: "Hi\r\nBcc: mallory@example.com"})
+		assert.Error(t, err)
+		assert.Nil(t, gotMsg)
+	})
+}
+
+func TestLogMailer_Send(t *testing.T) {
+	var out bytes.Buffer
+	mailer := NewLogMailer(&out)
+
+	err := mailer.Send(context.Background(), Message{To: "alice@example.com", Subject: "Reset your password", Body: "http://localhost:3000/?reset_token=abc"})
+	require.NoError(t, err)
+
+	assert.Contains(t, out.String(), "To: alice@example.com\nSubject: Reset your password\n\nhttp://localhost:3000/?reset_token=abc\n")
+	assert.Error(t, mailer.Send(context.Background(), Message{}))
+}
@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/mail"
+)
+
+type MockMailer struct {
+	mock.Mock
+}
+
+func (m *MockMailer) Send(ctx context.Context, message mail.Message) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
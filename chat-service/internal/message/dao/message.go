@@ -0,0 +1,132 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+)
+
+type Message struct {
+	entity.Entity
+	RoomID     string          `json:"room_id" gorm:"index;not null"`
+	UserID     string          `json:"user_id" gorm:"index"`
+	Username   string          `json:"username"`
+	Type       string          `json:"type" gorm:"not null"`
+	Content    string          `json:"content" gorm:"type:text"`
+	ReplyTo    *string         `json:"reply_to,omitempty" gorm:"type:uuid;index"`
+	ReplyCount int             `json:"reply_count" gorm:"not null;default:0"`
+	EditedAt   *time.Time      `json:"edited_at,omitempty"`
+	DeletedAt  *time.Time      `json:"deleted_at,omitempty"`
+	Reactions  []ReactionCount `json:"reactions,omitempty" gorm:"-"`
+}
+
+func (m Message) Build() Message {
+	now := time.Now()
+	m.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return m
+}
+
+func (m Message) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+type RevisionAction string
+
+const (
+	RevisionActionEdit   RevisionAction = "edit"
+	RevisionActionDelete RevisionAction = "delete"
+)
+
+// MessageRevision keeps the content a message had before it was edited or deleted,
+// so moderators can still see what was originally said.
+type MessageRevision struct {
+	entity.Entity
+	MessageID       string         `json:"message_id" gorm:"type:uuid;index;not null"`
+	EditorID        string         `json:"editor_id"`
+	Action          RevisionAction `json:"action" gorm:"not null"`
+	PreviousContent string         `json:"previous_content" gorm:"type:text"`
+}
+
+func (r MessageRevision) Build() MessageRevision {
+	now := time.Now()
+	r.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return r
+}
+
+// Reaction is one user's emoji on a message; a user can add each emoji once per message.
+type Reaction struct {
+	entity.Entity
+	MessageID string `json:"message_id" gorm:"type:uuid;not null;uniqueIndex:idx_reaction"`
+	UserID    string `json:"user_id" gorm:"not null;uniqueIndex:idx_reaction"`
+	Emoji     string `json:"emoji" gorm:"not null;uniqueIndex:idx_reaction"`
+}
+
+func (r Reaction) Build() Reaction {
+	now := time.Now()
+	r.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return r
+}
+
+// ReactionCount aggregates the reactions of a message by emoji.
+type ReactionCount struct {
+	MessageID string `json:"-"`
+	Emoji     string `json:"emoji"`
+	Count     int64  `json:"count"`
+}
+
+// ReadCursor is the last message a user has read in a room. Keeping it server-side lets the
+// unread count follow the user across devices.
+type ReadCursor struct {
+	entity.Entity
+	UserID            string    `json:"user_id" gorm:"not null;uniqueIndex:idx_read_cursor"`
+	RoomID            string    `json:"room_id" gorm:"not null;uniqueIndex:idx_read_cursor"`
+	LastReadMessageID string    `json:"last_read_message_id" gorm:"type:uuid;not null"`
+	LastReadAt        time.Time `json:"last_read_at" gorm:"not null"`
+}
+
+func (c ReadCursor) Build() ReadCursor {
+	now := time.Now()
+	c.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return c
+}
+
+// UnreadCount is how many messages from others arrived in a room after the user's read cursor.
+type UnreadCount struct {
+	RoomID            string `json:"room_id"`
+	LastReadMessageID string `json:"last_read_message_id"`
+	Unread            int64  `json:"unread"`
+}
+
+// SearchHit is a message matching a search, with the matching terms of its content highlighted.
+type SearchHit struct {
+	Message `gorm:"embedded"`
+	Snippet string `json:"snippet"`
+}
+
+// ArchivedMessage is a message moved out of its room by the room's retention policy.
+type ArchivedMessage struct {
+	Message
+	ArchivedAt time.Time `json:"archived_at" gorm:"not null"`
+}
+
+func (ArchivedMessage) TableName() string {
+	return "archived_messages"
+}
@@ -0,0 +1,10 @@
+package dto
+
+import "time"
+
+// ExportDTO selects the messages of a room to export; From is inclusive and To exclusive.
+type ExportDTO struct {
+	RoomID string
+	From   *time.Time
+	To     *time.Time
+}
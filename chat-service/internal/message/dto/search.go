@@ -0,0 +1,12 @@
+package dto
+
+import "time"
+
+// SearchDTO holds the filters of a message search. Only Query is required.
+type SearchDTO struct {
+	Query    string
+	RoomID   string
+	Username string
+	From     *time.Time
+	To       *time.Time
+}
@@ -0,0 +1,9 @@
+package dto
+
+import "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+
+// ThreadDTO is a root message followed by its replies, oldest first.
+type ThreadDTO struct {
+	Root    dao.Message   `json:"root"`
+	Replies []dao.Message `json:"replies"`
+}
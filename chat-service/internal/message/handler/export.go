@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+)
+
+// exporter writes a transcript incrementally: begin once, write per message, end once.
+type exporter interface {
+	contentType() string
+	extension() string
+	begin() error
+	write(message dao.Message) error
+	end() error
+}
+
+// newExporter returns the exporter for format, or false if the format is not supported.
+func newExporter(format, roomID string, w io.Writer) (exporter, bool) {
+	switch format {
+	case "", "json":
+		return &jsonExporter{w: w}, true
+	case "csv":
+		return &csvExporter{w: csv.NewWriter(w)}, true
+	case "md":
+		return &markdownExporter{w: w, roomID: roomID}, true
+	default:
+		return nil, false
+	}
+}
+
+// jsonExporter writes a JSON array of messages, one element at a time.
+type jsonExporter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonExporter) contentType() string { return "application/json" }
+func (e *jsonExporter) extension() string   { return "json" }
+
+func (e *jsonExporter) begin() error {
+	_, err := io.WriteString(e.w, "[\n")
+	return err
+}
+
+func (e *jsonExporter) write(message dao.Message) error {
+	if e.count > 0 {
+		if _, err := io.WriteString(e.w, ",\n"); err != nil {
+			return err
+		}
+	}
+	e.count++
+
+	encoded, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(encoded)
+	return err
+}
+
+func (e *jsonExporter) end() error {
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
+
+var csvHeader = []string{"id", "created_at", "room_id", "user_id", "username", "type", "reply_to", "content", "edited_at", "deleted_at"}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvExporter) contentType() string { return "text/csv; charset=utf-8" }
+func (e *csvExporter) extension() string   { return "csv" }
+
+func (e *csvExporter) begin() error {
+	return e.w.Write(csvHeader)
+}
+
+func (e *csvExporter) write(message dao.Message) error {
+	var replyTo string
+	if message.ReplyTo != nil {
+		replyTo = *message.ReplyTo
+	}
+
+	if err := e.w.Write([]string{
+		message.ID,
+		message.CreatedAt.UTC().Format(time.RFC3339),
+		message.RoomID,
+		message.UserID,
+		message.Username,
+		message.Type,
+		replyTo,
+		message.Content,
+		formatOptionalTime(message.EditedAt),
+		formatOptionalTime(message.DeletedAt),
+	}); err != nil {
+		return err
+	}
+
+	// csv.Writer buffers; flushing per row keeps the response streaming.
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) end() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// markdownExporter writes a human-readable transcript, one list item per message.
+type markdownExporter struct {
+	w      io.Writer
+	roomID string
+}
+
+func (e *markdownExporter) contentType() string { return "text/markdown; charset=utf-8" }
+func (e *markdownExporter) extension() string   { return "md" }
+
+func (e *markdownExporter) begin() error {
+	_, err := fmt.Fprintf(e.w, "# Transcript of %s\n\n", e.roomID)
+	return err
+}
+
+func (e *markdownExporter) write(message dao.Message) error {
+	content := strings.ReplaceAll(message.Content, "\n", "\n  ")
+	switch {
+	case message.IsDeleted():
+		content = "_message deleted_"
+	case message.EditedAt != nil:
+		content += " _(edited)_"
+	}
+
+	prefix := ""
+	if message.ReplyTo != nil {
+		prefix = "↳ "
+	}
+
+	_, err := fmt.Fprintf(e.w, "- %s`%s` **%s**: %s\n", prefix, message.CreatedAt.UTC().Format(time.RFC3339), message.Username, content)
+	return err
+}
+
+func (e *markdownExporter) end() error {
+	return nil
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	authhttp "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/http"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dto"
+	messagesrv "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/service"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+)
+
+type Handler struct {
+	service messagesrv.Service
+}
+
+func New(service messagesrv.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// Thread serves GET /messages/{id}/thread: the thread the message belongs to, root first.
+func (h *Handler) Thread(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	thread, err := h.service.Thread(r.Context(), userID, r.PathValue("id"))
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(thread)
+}
+
+// History serves GET /messages/{id}/history: the message's previous contents, oldest first, for
+// the moderators of its room.
+func (h *Handler) History(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+	role, _ := r.Context().Value(authhttp.RoleKey).(string)
+
+	revisions, err := h.service.History(r.Context(), r.PathValue("id"), userID, role)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	if revisions == nil {
+		revisions = []dao.MessageRevision{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// Unread serves GET /rooms/unread: the unread count of every room the user has read before.
+func (h *Handler) Unread(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	counts, err := h.service.Unread(r.Context(), userID)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(counts)
+}
+
+// Search serves GET /search?q=&room=&user=&from=&to=. from and to accept RFC 3339 timestamps
+// or YYYY-MM-DD dates; to is exclusive.
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+	query := r.URL.Query()
+
+	filter := dto.SearchDTO{
+		Query:    query.Get("q"),
+		RoomID:   query.Get("room"),
+		Username: query.Get("user"),
+	}
+
+	var err error
+	if filter.From, err = parseTime(query.Get("from")); err != nil {
+		customerrors.HandleError(w, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("from must be an RFC 3339 timestamp or a YYYY-MM-DD date")))
+		return
+	}
+	if filter.To, err = parseTime(query.Get("to")); err != nil {
+		customerrors.HandleError(w, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("to must be an RFC 3339 timestamp or a YYYY-MM-DD date")))
+		return
+	}
+
+	hits, err := h.service.Search(r.Context(), userID, filter)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(hits)
+}
+
+// Export serves GET /rooms/{id}/export?format=json|csv|md&from=&to=, streaming the room's
+// history as it is read. Headers are only sent once the export is known to be allowed, so
+// errors found up front still get a proper status; a failure mid-stream truncates the body.
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+	query := r.URL.Query()
+	filter := dto.ExportDTO{RoomID: r.PathValue("id")}
+
+	exp, ok := newExporter(query.Get("format"), filter.RoomID, w)
+	if !ok {
+		customerrors.HandleError(w, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("format must be json, csv or md")))
+		return
+	}
+
+	var err error
+	if filter.From, err = parseTime(query.Get("from")); err != nil {
+		customerrors.HandleError(w, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("from must be an RFC 3339 timestamp or a YYYY-MM-DD date")))
+		return
+	}
+	if filter.To, err = parseTime(query.Get("to")); err != nil {
+		customerrors.HandleError(w, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("to must be an RFC 3339 timestamp or a YYYY-MM-DD date")))
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	started := false
+	begin := func() error {
+		started = true
+		w.Header().Set("Content-Type", exp.contentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filter.RoomID+"-transcript."+exp.extension()))
+		w.WriteHeader(http.StatusOK)
+		return exp.begin()
+	}
+
+	written := 0
+	err = h.service.Export(r.Context(), userID, filter, func(message dao.Message) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+		if err := exp.write(message); err != nil {
+			return err
+		}
+
+		written++
+		if written%100 == 0 {
+			controller.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = begin()
+	}
+	if err != nil {
+		if !started {
+			customerrors.HandleError(w, err)
+			return
+		}
+		log.Printf("error exporting room %s: %v", filter.RoomID, err)
+		return
+	}
+
+	if err := exp.end(); err != nil {
+		log.Printf("error exporting room %s: %v", filter.RoomID, err)
+	}
+}
+
+// parseTime returns nil for an empty value.
+func parseTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, errors.New("invalid time")
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	authhttp "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/http"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	messagerepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/repository/mocks"
+	messagesrv "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/service"
+	roomdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	roomrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository/mocks"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
+)
+
+func TestHandler_Export(t *testing.T) {
+	sentAt := time.Date(2026, 3, 2, 15, 4, 5, 0, time.UTC)
+	transcript := []dao.Message{
+		{Entity: entity.Entity{ID: "msg1", CreatedAt: sentAt}, RoomID: "stocks", UserID: "user1", Username: "alice", Type: "default", Content: "/stock=AAPL.US"},
+		{Entity: entity.Entity{ID: "msg2", CreatedAt: sentAt}, RoomID: "stocks", Username: "Financial Bot", Type: "bot", Content: "AAPL.US quote is $262.82 per share"},
+	}
+
+	tests := []struct {
+		name            string
+		query           string
+		member          *roomdao.Member
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "Given json format, When the room is exported, Then a JSON array is streamed",
+			query:           "?format=json",
+			member:          &roomdao.Member{},
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `"content":"AAPL.US quote is $262.82 per share"`,
+		},
+		{
+			name:            "Given csv format, When the room is exported, Then a header row and one row per message are streamed",
+			query:           "?format=csv",
+			member:          &roomdao.Member{},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        "id,created_at,room_id,user_id,username,type,reply_to,content,edited_at,deleted_at\nmsg1,2026-03-02T15:04:05Z,stocks,user1,alice,default,,/stock=AAPL.US,,\n",
+		},
+		{
+			name:            "Given md format, When the room is exported, Then a Markdown transcript is streamed",
+			query:           "?format=md",
+			member:          &roomdao.Member{},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/markdown; charset=utf-8",
+			wantBody:        "# Transcript of stocks\n\n- `2026-03-02T15:04:05Z` **alice**: /stock=AAPL.US\n",
+		},
+		{
+			name:       "Given an unknown format, When the room is exported, Then bad request is returned",
+			query:      "?format=xml",
+			member:     &roomdao.Member{},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Given a user who never joined the room, When the room is exported, Then forbidden is returned",
+			query:      "?format=json",
+			member:     nil,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			mockRepo.On("FindPage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(transcript, nil).Maybe()
+			rooms := new(roomrepomock.MockRepository)
+			rooms.On("FindMember", mock.Anything, "stocks", "user1").Return(tt.member, nil).Maybe()
+
+			req := httptest.NewRequest(http.MethodGet, "/rooms/stocks/export"+tt.query, nil)
+			req.SetPathValue("id", "stocks")
+			req = req.WithContext(context.WithValue(req.Context(), authhttp.UserIDKey, "user1"))
+			rec := httptest.NewRecorder()
+
+			New(*messagesrv.New(mockRepo, rooms)).Export(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus == http.StatusOK {
+				assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+				assert.Contains(t, rec.Header().Get("Content-Disposition"), "stocks-transcript.")
+				assert.Contains(t, rec.Body.String(), tt.wantBody)
+				assert.Contains(t, rec.Body.String(), "Financial Bot")
+			}
+		})
+	}
+}
+
+func TestHandler_History(t *testing.T) {
+	tests := []struct {
+		name       string
+		role       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Given a global moderator, When the history is requested, Then the revisions are returned",
+			role:       roles.Moderator,
+			wantStatus: http.StatusOK,
+			wantBody:   `"previous_content":"helo"`,
+		},
+		{
+			name:       "Given a regular member of the room, When the history is requested, Then forbidden is returned",
+			role:       roles.Member,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			mockRepo.On("FindByID", mock.Anything, "msg1").Return(&dao.Message{Entity: entity.Entity{ID: "msg1"}, RoomID: "stocks"}, nil)
+			mockRepo.On("FindRevisions", mock.Anything, "msg1").Return([]dao.MessageRevision{
+				{MessageID: "msg1", EditorID: "user2", Action: dao.RevisionActionEdit, PreviousContent: "helo"},
+			}, nil).Maybe()
+			rooms := new(roomrepomock.MockRepository)
+			rooms.On("FindRoom", mock.Anything, "stocks").Return(&roomdao.Room{ID: "stocks", OwnerID: "owner"}, nil).Maybe()
+			rooms.On("FindMember", mock.Anything, "stocks", "user1").Return(&roomdao.Member{Role: roomdao.MemberRoleMember}, nil).Maybe()
+
+			req := httptest.NewRequest(http.MethodGet, "/messages/msg1/history", nil)
+			req.SetPathValue("id", "msg1")
+			ctx := context.WithValue(req.Context(), authhttp.UserIDKey, "user1")
+			req = req.WithContext(context.WithValue(ctx, authhttp.RoleKey, tt.role))
+			rec := httptest.NewRecorder()
+
+			New(*messagesrv.New(mockRepo, rooms)).History(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dto"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, message dao.Message) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindByID(ctx context.Context, id string) (*dao.Message, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dao.Message), args.Error(1)
+}
+
+func (m *MockRepository) Update(ctx context.Context, message dao.Message, revision dao.MessageRevision) error {
+	args := m.Called(ctx, message, revision)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindRevisions(ctx context.Context, messageID string) ([]dao.MessageRevision, error) {
+	args := m.Called(ctx, messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dao.MessageRevision), args.Error(1)
+}
+
+func (m *MockRepository) CreateReply(ctx context.Context, reply dao.Message) error {
+	args := m.Called(ctx, reply)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindReplies(ctx context.Context, rootID string) ([]dao.Message, error) {
+	args := m.Called(ctx, rootID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dao.Message), args.Error(1)
+}
+
+func (m *MockRepository) ToggleReaction(ctx context.Context, reaction dao.Reaction) error {
+	args := m.Called(ctx, reaction)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CountReactions(ctx context.Context, messageIDs []string) ([]dao.ReactionCount, error) {
+	args := m.Called(ctx, messageIDs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dao.ReactionCount), args.Error(1)
+}
+
+func (m *MockRepository) SaveReadCursor(ctx context.Context, cursor dao.ReadCursor) error {
+	args := m.Called(ctx, cursor)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CountUnread(ctx context.Context, userID string) ([]dao.UnreadCount, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dao.UnreadCount), args.Error(1)
+}
+
+func (m *MockRepository) Search(ctx context.Context, filter dto.SearchDTO, roomIDs []string, limit int) ([]dao.SearchHit, error) {
+	args := m.Called(ctx, filter, roomIDs, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dao.SearchHit), args.Error(1)
+}
+
+func (m *MockRepository) FindPage(ctx context.Context, filter dto.ExportDTO, after *dao.Message, limit int) ([]dao.Message, error) {
+	args := m.Called(ctx, filter, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dao.Message), args.Error(1)
+}
+
+func (m *MockRepository) ExpireBefore(ctx context.Context, roomID string, cutoff time.Time, archive bool, limit int) ([]string, error) {
+	args := m.Called(ctx, roomID, cutoff, archive, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
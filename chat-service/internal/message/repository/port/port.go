@@ -0,0 +1,25 @@
+package port
+
+import (
+	"context"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dto"
+)
+
+type RepositoryPort interface {
+	Create(ctx context.Context, message dao.Message) error
+	FindByID(ctx context.Context, id string) (*dao.Message, error)
+	Update(ctx context.Context, message dao.Message, revision dao.MessageRevision) error
+	FindRevisions(ctx context.Context, messageID string) ([]dao.MessageRevision, error)
+	CreateReply(ctx context.Context, reply dao.Message) error
+	FindReplies(ctx context.Context, rootID string) ([]dao.Message, error)
+	ToggleReaction(ctx context.Context, reaction dao.Reaction) error
+	CountReactions(ctx context.Context, messageIDs []string) ([]dao.ReactionCount, error)
+	SaveReadCursor(ctx context.Context, cursor dao.ReadCursor) error
+	CountUnread(ctx context.Context, userID string) ([]dao.UnreadCount, error)
+	Search(ctx context.Context, filter dto.SearchDTO, roomIDs []string, limit int) ([]dao.SearchHit, error)
+	FindPage(ctx context.Context, filter dto.ExportDTO, after *dao.Message, limit int) ([]dao.Message, error)
+	ExpireBefore(ctx context.Context, roomID string, cutoff time.Time, archive bool, limit int) ([]string, error)
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dto"
+)
+
+// searchConfig is the text search configuration used for message content. "simple" does no
+// stemming, which suits tickers and a mix of English and Portuguese.
+const searchConfig = "simple"
+
+// SearchIndexSQL creates the GIN index backing Search; AutoMigrate cannot express it.
+const SearchIndexSQL = `CREATE INDEX IF NOT EXISTS idx_messages_content_search ON messages USING GIN (to_tsvector('` + searchConfig + `', content))`
+
+type DB interface {
+	Create(entity any) *gorm.DB
+	Where(query any, args ...any) *gorm.DB
+	First(dest any, conds ...any) *gorm.DB
+	Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
+	Clauses(conds ...clause.Expression) *gorm.DB
+	Raw(query string, values ...any) *gorm.DB
+}
+
+type Repository struct {
+	db DB
+}
+
+func NewRepository(db DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(_ context.Context, message dao.Message) error {
+	tx := r.db.Create(&message)
+	return tx.Error
+}
+
+func (r *Repository) FindByID(_ context.Context, id string) (*dao.Message, error) {
+	var message dao.Message
+
+	tx := r.db.Where("id = ?", id).First(&message)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &message, nil
+}
+
+// Update saves the new state of a message together with the revision describing what it replaced.
+func (r *Repository) Update(_ context.Context, message dao.Message, revision dao.MessageRevision) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&revision).Error; err != nil {
+			return err
+		}
+		return tx.Save(&message).Error
+	})
+}
+
+func (r *Repository) FindRevisions(_ context.Context, messageID string) ([]dao.MessageRevision, error) {
+	var revisions []dao.MessageRevision
+
+	tx := r.db.Where("message_id = ?", messageID).Order("created_at").Find(&revisions)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return revisions, nil
+}
+
+// CreateReply stores a reply and bumps the reply count of the thread's root message.
+func (r *Repository) CreateReply(_ context.Context, reply dao.Message) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&reply).Error; err != nil {
+			return err
+		}
+		return tx.Model(&dao.Message{}).
+			Where("id = ?", *reply.ReplyTo).
+			UpdateColumn("reply_count", gorm.Expr("reply_count + 1")).Error
+	})
+}
+
+func (r *Repository) FindReplies(_ context.Context, rootID string) ([]dao.Message, error) {
+	var replies []dao.Message
+
+	tx := r.db.Where("reply_to = ?", rootID).Order("created_at").Find(&replies)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return replies, nil
+}
+
+// ToggleReaction adds the reaction, or removes it if the user already reacted with that emoji.
+func (r *Repository) ToggleReaction(_ context.Context, reaction dao.Reaction) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("message_id = ? AND user_id = ? AND emoji = ?", reaction.MessageID, reaction.UserID, reaction.Emoji).
+			Delete(&dao.Reaction{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+		return tx.Create(&reaction).Error
+	})
+}
+
+func (r *Repository) CountReactions(_ context.Context, messageIDs []string) ([]dao.ReactionCount, error) {
+	var counts []dao.ReactionCount
+
+	tx := r.db.Where("message_id IN ?", messageIDs).
+		Model(&dao.Reaction{}).
+		Select("message_id, emoji, count(*) AS count").
+		Group("message_id, emoji").
+		Order("message_id, min(created_at)").
+		Scan(&counts)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return counts, nil
+}
+
+// SaveReadCursor moves the user's cursor in a room forward. A cursor pointing at an older message
+// than the stored one is ignored, so receipts arriving out of order never move it back.
+func (r *Repository) SaveReadCursor(_ context.Context, cursor dao.ReadCursor) error {
+	tx := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "room_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_read_message_id", "last_read_at", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "excluded.last_read_at > read_cursors.last_read_at"},
+		}},
+	}).Create(&cursor)
+	return tx.Error
+}
+
+// CountUnread returns, for every room the user is a member of and not banned from, how many
+// messages from other users arrived after their read cursor. Rooms they never marked read have
+// no cursor, so every message in them counts and LastReadMessageID is empty.
+func (r *Repository) CountUnread(_ context.Context, userID string) ([]dao.UnreadCount, error) {
+	var counts []dao.UnreadCount
+
+	tx := r.db.Raw(`
+		SELECT rm.room_id, COALESCE(c.last_read_message_id::text, '') AS last_read_message_id, COUNT(m.id) AS unread
+		FROM room_members rm
+		LEFT JOIN read_cursors c
+			ON c.room_id = rm.room_id
+			AND c.user_id = rm.user_id
+		LEFT JOIN messages m
+			ON m.room_id = rm.room_id
+			AND (c.last_read_at IS NULL OR m.created_at > c.last_read_at)
+			AND m.user_id <> rm.user_id
+			AND m.deleted_at IS NULL
+		WHERE rm.user_id = ?
+			AND (rm.banned_at IS NULL OR rm.banned_until <= ?)
+		GROUP BY rm.room_id, c.last_read_message_id
+		ORDER BY rm.room_id`, userID, time.Now()).Scan(&counts)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return counts, nil
+}
+
+// Search runs a full-text search over the non-deleted messages of roomIDs, newest first.
+// Matching terms are wrapped in <mark> tags in each hit's snippet.
+func (r *Repository) Search(_ context.Context, filter dto.SearchDTO, roomIDs []string, limit int) ([]dao.SearchHit, error) {
+	var hits []dao.SearchHit
+
+	var query strings.Builder
+	query.WriteString(`
+		SELECT m.*, ts_headline('` + searchConfig + `', m.content, q, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2') AS snippet
+		FROM messages m, websearch_to_tsquery('` + searchConfig + `', ?) q
+		WHERE to_tsvector('` + searchConfig + `', m.content) @@ q
+			AND m.room_id IN ?
+			AND m.deleted_at IS NULL`)
+	args := []any{filter.Query, roomIDs}
+
+	if filter.Username != "" {
+		query.WriteString(" AND m.username = ?")
+		args = append(args, filter.Username)
+	}
+	if filter.From != nil {
+		query.WriteString(" AND m.created_at >= ?")
+		args = append(args, *filter.From)
+	}
+	if filter.To != nil {
+		query.WriteString(" AND m.created_at < ?")
+		args = append(args, *filter.To)
+	}
+	query.WriteString(" ORDER BY m.created_at DESC LIMIT ?")
+	args = append(args, limit)
+
+	tx := r.db.Raw(query.String(), args...).Scan(&hits)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return hits, nil
+}
+
+// FindPage returns up to limit messages of a room in chronological order, starting after the
+// given message. Paging on (created_at, id) keeps each query cheap however deep the export goes.
+func (r *Repository) FindPage(_ context.Context, filter dto.ExportDTO, after *dao.Message, limit int) ([]dao.Message, error) {
+	var messages []dao.Message
+
+	query := r.db.Where("room_id = ?", filter.RoomID)
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if after != nil {
+		query = query.Where("(created_at, id) > (?, ?)", after.CreatedAt, after.ID)
+	}
+
+	tx := query.Order("created_at, id").Limit(limit).Find(&messages)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return messages, nil
+}
+
+// ExpireBefore removes up to limit messages of a room created before cutoff, oldest first, and
+// returns their IDs. Their reactions go with them; with archive set the messages are copied to
+// archived_messages first and their edit history is kept, otherwise it is deleted too.
+// Rows locked by a concurrent run are skipped rather than waited for.
+func (r *Repository) ExpireBefore(_ context.Context, roomID string, cutoff time.Time, archive bool, limit int) ([]string, error) {
+	var ids []string
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&dao.Message{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("room_id = ? AND created_at < ?", roomID, cutoff).
+			Order("created_at").
+			Limit(limit).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if archive {
+			if err := tx.Exec(`
+				INSERT INTO archived_messages (id, created_at, updated_at, room_id, user_id, username, type, content, reply_to, reply_count, edited_at, deleted_at, archived_at)
+				SELECT id, created_at, updated_at, room_id, user_id, username, type, content, reply_to, reply_count, edited_at, deleted_at, ?
+				FROM messages WHERE id IN ?`, time.Now(), ids).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Where("message_id IN ?", ids).Delete(&dao.MessageRevision{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("message_id IN ?", ids).Delete(&dao.Reaction{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&dao.Message{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
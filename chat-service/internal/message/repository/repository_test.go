@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"gorm.io/gorm"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/repository/mocks"
+)
+
+func Test_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(db *mocks.MockDB)
+		wantErr bool
+	}{
+		{
+			name: "Given valid message, When Create is called, Then no error is returned",
+			setup: func(db *mocks.MockDB) {
+				db.On("Create", mock.AnythingOfType("*dao.Message")).Return(&gorm.DB{Error: nil})
+			},
+			wantErr: false,
+		},
+		{
+			name: "Given DB error, When Create is called, Then error is returned",
+			setup: func(db *mocks.MockDB) {
+				db.On("Create", mock.AnythingOfType("*dao.Message")).Return(&gorm.DB{Error: gorm.ErrInvalidData})
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			tt.setup(mockDB)
+
+			repo := NewRepository(mockDB)
+			err := repo.Create(context.Background(), dao.Message{RoomID: "room1", Content: "hello"})
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
+
+func Test_Update(t *testing.T) {
+	tests := []struct {
+		name    string
+		txErr   error
+		wantErr bool
+	}{
+		{
+			name:    "Given the transaction commits, When Update is called, Then no error is returned",
+			txErr:   nil,
+			wantErr: false,
+		},
+		{
+			name:    "Given the transaction fails, When Update is called, Then error is returned",
+			txErr:   gorm.ErrInvalidTransaction,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			mockDB.On("Transaction", mock.Anything).Return(tt.txErr)
+
+			repo := NewRepository(mockDB)
+			err := repo.Update(context.Background(), dao.Message{}, dao.MessageRevision{})
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
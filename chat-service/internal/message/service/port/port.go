@@ -0,0 +1,16 @@
+package port
+
+import (
+	"context"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+)
+
+type MessageService interface {
+	Save(ctx context.Context, message dao.Message) (*dao.Message, error)
+	Edit(ctx context.Context, roomID, messageID, userID, content string) (*dao.Message, error)
+	Delete(ctx context.Context, roomID, messageID, userID string) (*dao.Message, error)
+	React(ctx context.Context, roomID, messageID, userID, emoji string) ([]dao.ReactionCount, error)
+	MarkRead(ctx context.Context, roomID, messageID, userID string) (*dao.ReadCursor, error)
+	PurgeRoom(ctx context.Context, roomID string) ([]string, error)
+}
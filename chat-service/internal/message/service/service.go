@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dto"
+	messagerepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/repository/port"
+	roomdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	roomrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository/port"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
+)
+
+const (
+	// maxEmojiLength bounds a reaction, in runes, so it fits flags and skin-tone sequences but not text.
+	maxEmojiLength = 8
+	// maxSearchQueryLength bounds the search terms accepted by Search, in runes.
+	maxSearchQueryLength = 200
+	// searchLimit caps how many hits Search returns.
+	searchLimit = 50
+)
+
+// exportPageSize is how many messages Export loads at a time, and PurgeRoom deletes per transaction.
+var exportPageSize = 500
+
+type Service struct {
+	repo  messagerepo.RepositoryPort
+	rooms roomrepo.RepositoryPort
+}
+
+func New(repo messagerepo.RepositoryPort, rooms roomrepo.RepositoryPort) *Service {
+	return &Service{
+		repo:  repo,
+		rooms: rooms,
+	}
+}
+
+// Save stores a new message. Messages with ReplyTo set join the thread of the message they answer.
+func (s *Service) Save(ctx context.Context, message dao.Message) (*dao.Message, error) {
+	if message.ReplyTo != nil {
+		return s.saveReply(ctx, message)
+	}
+
+	built := message.Build()
+	if err := s.repo.Create(ctx, built); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred saving message"))
+	}
+
+	return &built, nil
+}
+
+// Edit replaces the content of a message sent by userID in roomID, recording the previous content.
+func (s *Service) Edit(ctx context.Context, roomID, messageID, userID, content string) (*dao.Message, error) {
+	message, err := s.findAuthored(ctx, roomID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	revision := dao.MessageRevision{
+		MessageID:       message.ID,
+		EditorID:        userID,
+		Action:          dao.RevisionActionEdit,
+		PreviousContent: message.Content,
+	}.Build()
+
+	message.Content = content
+	message.EditedAt = &now
+	message.UpdatedAt = now
+
+	if err := s.repo.Update(ctx, *message, revision); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred editing message"))
+	}
+
+	return message, nil
+}
+
+// Delete retracts a message sent by userID in roomID. The row is kept with its content cleared
+// and the original content moves to the message's revision history.
+func (s *Service) Delete(ctx context.Context, roomID, messageID, userID string) (*dao.Message, error) {
+	message, err := s.findAuthored(ctx, roomID, messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	revision := dao.MessageRevision{
+		MessageID:       message.ID,
+		EditorID:        userID,
+		Action:          dao.RevisionActionDelete,
+		PreviousContent: message.Content,
+	}.Build()
+
+	message.Content = ""
+	message.DeletedAt = &now
+	message.UpdatedAt = now
+
+	if err := s.repo.Update(ctx, *message, revision); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred deleting message"))
+	}
+
+	return message, nil
+}
+
+// History returns the edits and deletion of a message, oldest first. Only the owner and
+// moderators of the message's room, and global admins and moderators, can read it.
+func (s *Service) History(ctx context.Context, messageID, userID, globalRole string) ([]dao.MessageRevision, error) {
+	message, err := s.repo.FindByID(ctx, messageID)
+	if err != nil || message == nil {
+		return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("message not found"))
+	}
+
+	if !roles.CanModerateAnyRoom(globalRole) {
+		room, err := s.rooms.FindRoom(ctx, message.RoomID)
+		if err != nil {
+			return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room"))
+		}
+		member, err := s.rooms.FindMember(ctx, message.RoomID, userID)
+		if err != nil {
+			return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room membership"))
+		}
+		owner := room != nil && room.OwnerID == userID
+		if member == nil || member.IsBanned(time.Now()) || (!owner && member.Role != roomdao.MemberRoleModerator) {
+			return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("only the room owner and moderators can read message history"))
+		}
+	}
+
+	revisions, err := s.repo.FindRevisions(ctx, messageID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading message history"))
+	}
+
+	return revisions, nil
+}
+
+// React toggles userID's emoji on a message in roomID and returns the message's updated reaction counts.
+func (s *Service) React(ctx context.Context, roomID, messageID, userID, emoji string) ([]dao.ReactionCount, error) {
+	emoji = strings.TrimSpace(emoji)
+	if !isEmoji(emoji) {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("a reaction must be a single emoji"))
+	}
+
+	if _, err := s.findInRoom(ctx, roomID, messageID); err != nil {
+		return nil, err
+	}
+
+	reaction := dao.Reaction{
+		MessageID: messageID,
+		UserID:    userID,
+		Emoji:     emoji,
+	}.Build()
+
+	if err := s.repo.ToggleReaction(ctx, reaction); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred saving reaction"))
+	}
+
+	counts, err := s.repo.CountReactions(ctx, []string{messageID})
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading reactions"))
+	}
+
+	return counts, nil
+}
+
+// Thread returns the thread a message belongs to, with the reactions of every message in it.
+// Only members of the message's room can read it.
+func (s *Service) Thread(ctx context.Context, userID, messageID string) (*dto.ThreadDTO, error) {
+	root, err := s.repo.FindByID(ctx, messageID)
+	if err != nil || root == nil {
+		return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("message not found"))
+	}
+
+	if root.ReplyTo != nil {
+		root, err = s.repo.FindByID(ctx, *root.ReplyTo)
+		if err != nil || root == nil {
+			return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("message not found"))
+		}
+	}
+
+	member, err := s.rooms.FindMember(ctx, root.RoomID, userID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room membership"))
+	}
+	if member == nil {
+		return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("you are not a member of this room"))
+	}
+	if member.IsBanned(time.Now()) {
+		return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("you are banned from this room"))
+	}
+
+	replies, err := s.repo.FindReplies(ctx, root.ID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading thread"))
+	}
+
+	ids := make([]string, 0, len(replies)+1)
+	ids = append(ids, root.ID)
+	for _, reply := range replies {
+		ids = append(ids, reply.ID)
+	}
+
+	counts, err := s.repo.CountReactions(ctx, ids)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading reactions"))
+	}
+
+	byMessage := make(map[string][]dao.ReactionCount)
+	for _, count := range counts {
+		byMessage[count.MessageID] = append(byMessage[count.MessageID], count)
+	}
+
+	root.Reactions = byMessage[root.ID]
+	for i := range replies {
+		replies[i].Reactions = byMessage[replies[i].ID]
+	}
+
+	return &dto.ThreadDTO{
+		Root:    *root,
+		Replies: replies,
+	}, nil
+}
+
+// MarkRead moves userID's read cursor in roomID up to messageID.
+func (s *Service) MarkRead(ctx context.Context, roomID, messageID, userID string) (*dao.ReadCursor, error) {
+	message, err := s.findInRoom(ctx, roomID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := dao.ReadCursor{
+		UserID:            userID,
+		RoomID:            roomID,
+		LastReadMessageID: message.ID,
+		LastReadAt:        message.CreatedAt,
+	}.Build()
+
+	if err := s.repo.SaveReadCursor(ctx, cursor); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred saving read cursor"))
+	}
+
+	return &cursor, nil
+}
+
+// Unread returns the unread message count of every room userID belongs to.
+func (s *Service) Unread(ctx context.Context, userID string) ([]dao.UnreadCount, error) {
+	counts, err := s.repo.CountUnread(ctx, userID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading unread counts"))
+	}
+
+	if counts == nil {
+		counts = []dao.UnreadCount{}
+	}
+	return counts, nil
+}
+
+// Search looks for messages matching filter.Query in the rooms userID has joined, or only in
+// filter.RoomID when set.
+func (s *Service) Search(ctx context.Context, userID string, filter dto.SearchDTO) ([]dao.SearchHit, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	if filter.Query == "" || utf8.RuneCountInString(filter.Query) > maxSearchQueryLength {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("a search query of up to 200 characters is required"))
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("from must be before to"))
+	}
+
+	var roomIDs []string
+	if filter.RoomID != "" {
+		member, err := s.rooms.FindMember(ctx, filter.RoomID, userID)
+		if err != nil {
+			return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room membership"))
+		}
+		if member == nil {
+			return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("you are not a member of this room"))
+		}
+		if member.IsBanned(time.Now()) {
+			return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("you are banned from this room"))
+		}
+		roomIDs = []string{filter.RoomID}
+	} else {
+		var err error
+		roomIDs, err = s.rooms.FindRoomIDsByUser(ctx, userID)
+		if err != nil {
+			return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading rooms"))
+		}
+	}
+
+	if len(roomIDs) == 0 {
+		return []dao.SearchHit{}, nil
+	}
+
+	hits, err := s.repo.Search(ctx, filter, roomIDs, searchLimit)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred searching messages"))
+	}
+
+	if hits == nil {
+		hits = []dao.SearchHit{}
+	}
+	return hits, nil
+}
+
+// Export passes every message of filter.RoomID to write, oldest first, loading them a page at a
+// time so a room's whole history is never held in memory. Deleted messages are included with
+// their content cleared. Only members of the room who are not banned from it can export it.
+func (s *Service) Export(ctx context.Context, userID string, filter dto.ExportDTO, write func(dao.Message) error) error {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return customerrors.Wrap(customerrors.ErrBadRequest, errors.New("from must be before to"))
+	}
+
+	member, err := s.rooms.FindMember(ctx, filter.RoomID, userID)
+	if err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room membership"))
+	}
+	if member == nil {
+		return customerrors.Wrap(customerrors.ErrForbidden, errors.New("you are not a member of this room"))
+	}
+	if member.IsBanned(time.Now()) {
+		return customerrors.Wrap(customerrors.ErrForbidden, errors.New("you are banned from this room"))
+	}
+
+	var after *dao.Message
+	for {
+		page, err := s.repo.FindPage(ctx, filter, after, exportPageSize)
+		if err != nil {
+			return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading messages"))
+		}
+
+		for _, message := range page {
+			if err := write(message); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < exportPageSize {
+			return nil
+		}
+		after = &page[len(page)-1]
+	}
+}
+
+// saveReply stores a reply under the root of the thread it answers; threads are one level deep,
+// so answering a reply joins the same thread.
+func (s *Service) saveReply(ctx context.Context, message dao.Message) (*dao.Message, error) {
+	parent, err := s.findInRoom(ctx, message.RoomID, *message.ReplyTo)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("the message being replied to was not found"))
+	}
+
+	rootID := parent.ID
+	if parent.ReplyTo != nil {
+		rootID = *parent.ReplyTo
+	}
+
+	built := message.Build()
+	built.ReplyTo = &rootID
+	if err := s.repo.CreateReply(ctx, built); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred saving message"))
+	}
+
+	return &built, nil
+}
+
+func (s *Service) findInRoom(ctx context.Context, roomID, messageID string) (*dao.Message, error) {
+	message, err := s.repo.FindByID(ctx, messageID)
+	if err != nil || message == nil || message.RoomID != roomID || message.IsDeleted() {
+		return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("message not found"))
+	}
+
+	return message, nil
+}
+
+// isEmoji accepts short, non-empty strings without letters, digits or spaces.
+func isEmoji(s string) bool {
+	if s == "" || utf8.RuneCountInString(s) > maxEmojiLength {
+		return false
+	}
+
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Service) findAuthored(ctx context.Context, roomID, messageID, userID string) (*dao.Message, error) {
+	message, err := s.findInRoom(ctx, roomID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.UserID != userID {
+		return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("only the author can change this message"))
+	}
+
+	return message, nil
+}
+
+// PurgeRoom deletes every message of roomID with its reactions and revisions, and returns the
+// IDs of the deleted messages.
+func (s *Service) PurgeRoom(ctx context.Context, roomID string) ([]string, error) {
+	cutoff := time.Now()
+
+	var purged []string
+	for {
+		ids, err := s.repo.ExpireBefore(ctx, roomID, cutoff, false, exportPageSize)
+		if err != nil {
+			return purged, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred deleting room messages"))
+		}
+
+		purged = append(purged, ids...)
+		if len(ids) < exportPageSize {
+			return purged, nil
+		}
+	}
+}
@@ -0,0 +1,561 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dto"
+	messagerepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/repository/mocks"
+	roomdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	roomrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository/mocks"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
+)
+
+func storedMessage() *dao.Message {
+	return &dao.Message{
+		Entity:   entity.Entity{ID: "msg1"},
+		RoomID:   "room1",
+		UserID:   "user1",
+		Username: "alice",
+		Type:     "default",
+		Content:  "helo",
+	}
+}
+
+func TestService_Save(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(repo *messagerepomock.MockRepository)
+		wantErr bool
+	}{
+		{
+			name: "Given a chat message, When Save is called, Then it is stored with a generated ID",
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("Create", mock.Anything, mock.MatchedBy(func(m dao.Message) bool {
+					return m.ID != "" && m.Content == "hello" && !m.CreatedAt.IsZero()
+				})).Return(nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "Given repository error, When Save is called, Then error is returned",
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("Create", mock.Anything, mock.Anything).Return(assert.AnError)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			tt.setup(mockRepo)
+
+			saved, err := New(mockRepo, new(roomrepomock.MockRepository)).Save(context.Background(), dao.Message{RoomID: "room1", UserID: "user1", Content: "hello"})
+			assert.Equal(t, tt.wantErr, err != nil)
+			assert.Equal(t, tt.wantErr, saved == nil)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Edit(t *testing.T) {
+	type args struct {
+		roomID string
+		userID string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		setup      func(repo *messagerepomock.MockRepository)
+		wantStatus int
+	}{
+		{
+			name: "Given the author, When Edit is called, Then content is replaced and the previous one is kept as a revision",
+			args: args{roomID: "room1", userID: "user1"},
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(storedMessage(), nil)
+				repo.On("Update", mock.Anything,
+					mock.MatchedBy(func(m dao.Message) bool {
+						return m.Content == "hello" && m.EditedAt != nil
+					}),
+					mock.MatchedBy(func(r dao.MessageRevision) bool {
+						return r.MessageID == "msg1" && r.Action == dao.RevisionActionEdit && r.PreviousContent == "helo" && r.EditorID == "user1"
+					}),
+				).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name: "Given another user, When Edit is called, Then a forbidden error is returned",
+			args: args{roomID: "room1", userID: "user2"},
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(storedMessage(), nil)
+			},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name: "Given a message from another room, When Edit is called, Then a not found error is returned",
+			args: args{roomID: "room2", userID: "user1"},
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(storedMessage(), nil)
+			},
+			wantStatus: customerrors.ErrNotFound.Status,
+		},
+		{
+			name: "Given a missing message, When Edit is called, Then a not found error is returned",
+			args: args{roomID: "room1", userID: "user1"},
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(nil, assert.AnError)
+			},
+			wantStatus: customerrors.ErrNotFound.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			tt.setup(mockRepo)
+
+			edited, err := New(mockRepo, new(roomrepomock.MockRepository)).Edit(context.Background(), tt.args.roomID, "msg1", tt.args.userID, "hello")
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, "hello", edited.Content)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Delete(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     func() *dao.Message
+		setup      func(repo *messagerepomock.MockRepository, stored *dao.Message)
+		wantStatus int
+	}{
+		{
+			name:   "Given the author, When Delete is called, Then content is cleared and kept as a revision",
+			stored: storedMessage,
+			setup: func(repo *messagerepomock.MockRepository, stored *dao.Message) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(stored, nil)
+				repo.On("Update", mock.Anything,
+					mock.MatchedBy(func(m dao.Message) bool {
+						return m.Content == "" && m.DeletedAt != nil
+					}),
+					mock.MatchedBy(func(r dao.MessageRevision) bool {
+						return r.Action == dao.RevisionActionDelete && r.PreviousContent == "helo"
+					}),
+				).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name: "Given an already deleted message, When Delete is called, Then a not found error is returned",
+			stored: func() *dao.Message {
+				m := storedMessage()
+				m.Content = ""
+				m.DeletedAt = &m.CreatedAt
+				return m
+			},
+			setup: func(repo *messagerepomock.MockRepository, stored *dao.Message) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(stored, nil)
+			},
+			wantStatus: customerrors.ErrNotFound.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			tt.setup(mockRepo, tt.stored())
+
+			_, err := New(mockRepo, new(roomrepomock.MockRepository)).Delete(context.Background(), "room1", "msg1", "user1")
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_History(t *testing.T) {
+	revisions := []dao.MessageRevision{
+		{MessageID: "msg1", EditorID: "user1", Action: dao.RevisionActionEdit, PreviousContent: "helo"},
+	}
+	bannedAt := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name       string
+		userID     string
+		globalRole string
+		member     *roomdao.Member
+		wantStatus int
+	}{
+		{
+			name:   "Given the room owner, When History is called, Then the revisions are returned",
+			userID: "owner",
+			member: &roomdao.Member{RoomID: "room1", UserID: "owner", Role: roomdao.MemberRoleMember},
+		},
+		{
+			name:   "Given a moderator of the room, When History is called, Then the revisions are returned",
+			userID: "user3",
+			member: &roomdao.Member{RoomID: "room1", UserID: "user3", Role: roomdao.MemberRoleModerator},
+		},
+		{
+			name:       "Given a global admin who never joined the room, When History is called, Then the revisions are returned",
+			userID:     "admin1",
+			globalRole: roles.Admin,
+		},
+		{
+			name:       "Given the message's author without moderation rights, When History is called, Then forbidden is returned",
+			userID:     "user1",
+			member:     &roomdao.Member{RoomID: "room1", UserID: "user1", Role: roomdao.MemberRoleMember},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:       "Given a moderator banned from the room, When History is called, Then forbidden is returned",
+			userID:     "user3",
+			member:     &roomdao.Member{RoomID: "room1", UserID: "user3", Role: roomdao.MemberRoleModerator, BannedAt: &bannedAt},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:       "Given a user who never joined the room, When History is called, Then forbidden is returned",
+			userID:     "user4",
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			mockRepo.On("FindByID", mock.Anything, "msg1").Return(storedMessage(), nil)
+			mockRepo.On("FindRevisions", mock.Anything, "msg1").Return(revisions, nil).Maybe()
+			rooms := new(roomrepomock.MockRepository)
+			rooms.On("FindRoom", mock.Anything, "room1").Return(&roomdao.Room{ID: "room1", OwnerID: "owner"}, nil).Maybe()
+			rooms.On("FindMember", mock.Anything, "room1", tt.userID).Return(tt.member, nil).Maybe()
+
+			got, err := New(mockRepo, rooms).History(context.Background(), "msg1", tt.userID, tt.globalRole)
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, revisions, got)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+				mockRepo.AssertNotCalled(t, "FindRevisions", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestService_SaveReply(t *testing.T) {
+	tests := []struct {
+		name       string
+		parent     func() *dao.Message
+		wantRootID string
+		wantStatus int
+	}{
+		{
+			name:       "Given a reply to a root message, When Save is called, Then it joins that message's thread",
+			parent:     storedMessage,
+			wantRootID: "msg1",
+		},
+		{
+			name: "Given a reply to a reply, When Save is called, Then it joins the root's thread",
+			parent: func() *dao.Message {
+				m := storedMessage()
+				root := "root1"
+				m.ReplyTo = &root
+				return m
+			},
+			wantRootID: "root1",
+		},
+		{
+			name: "Given a reply to a message in another room, When Save is called, Then a not found error is returned",
+			parent: func() *dao.Message {
+				m := storedMessage()
+				m.RoomID = "room2"
+				return m
+			},
+			wantStatus: customerrors.ErrNotFound.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			mockRepo.On("FindByID", mock.Anything, "msg1").Return(tt.parent(), nil)
+			if tt.wantStatus == 0 {
+				mockRepo.On("CreateReply", mock.Anything, mock.MatchedBy(func(m dao.Message) bool {
+					return m.ID != "" && *m.ReplyTo == tt.wantRootID
+				})).Return(nil)
+			}
+
+			parentID := "msg1"
+			saved, err := New(mockRepo, new(roomrepomock.MockRepository)).Save(context.Background(), dao.Message{RoomID: "room1", UserID: "user2", Content: "agreed", ReplyTo: &parentID})
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantRootID, *saved.ReplyTo)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_React(t *testing.T) {
+	tests := []struct {
+		name       string
+		emoji      string
+		setup      func(repo *messagerepomock.MockRepository)
+		wantStatus int
+	}{
+		{
+			name:  "Given an emoji, When React is called, Then the reaction is toggled and the counts are returned",
+			emoji: " 👍 ",
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(storedMessage(), nil)
+				repo.On("ToggleReaction", mock.Anything, mock.MatchedBy(func(r dao.Reaction) bool {
+					return r.MessageID == "msg1" && r.UserID == "user2" && r.Emoji == "👍"
+				})).Return(nil)
+				repo.On("CountReactions", mock.Anything, []string{"msg1"}).Return([]dao.ReactionCount{{MessageID: "msg1", Emoji: "👍", Count: 1}}, nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:       "Given text instead of an emoji, When React is called, Then a bad request error is returned",
+			emoji:      "nice",
+			setup:      func(repo *messagerepomock.MockRepository) {},
+			wantStatus: customerrors.ErrBadRequest.Status,
+		},
+		{
+			name:  "Given a missing message, When React is called, Then a not found error is returned",
+			emoji: "👍",
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(nil, assert.AnError)
+			},
+			wantStatus: customerrors.ErrNotFound.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			tt.setup(mockRepo)
+
+			counts, err := New(mockRepo, new(roomrepomock.MockRepository)).React(context.Background(), "room1", "msg1", "user2", tt.emoji)
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				assert.Len(t, counts, 1)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Thread(t *testing.T) {
+	rootID := "msg1"
+	reply := dao.Message{Entity: entity.Entity{ID: "msg2"}, RoomID: "room1", Content: "agreed", ReplyTo: &rootID}
+
+	mockRepo := new(messagerepomock.MockRepository)
+	mockRepo.On("FindByID", mock.Anything, "msg2").Return(&reply, nil)
+	mockRepo.On("FindByID", mock.Anything, "msg1").Return(storedMessage(), nil)
+	mockRepo.On("FindReplies", mock.Anything, "msg1").Return([]dao.Message{reply}, nil)
+	mockRepo.On("CountReactions", mock.Anything, []string{"msg1", "msg2"}).Return([]dao.ReactionCount{
+		{MessageID: "msg2", Emoji: "👍", Count: 2},
+	}, nil)
+
+	rooms := new(roomrepomock.MockRepository)
+	rooms.On("FindMember", mock.Anything, "room1", "user1").Return(&roomdao.Member{RoomID: "room1", UserID: "user1"}, nil)
+
+	thread, err := New(mockRepo, rooms).Thread(context.Background(), "user1", "msg2")
+	assert.NoError(t, err)
+	assert.Equal(t, "msg1", thread.Root.ID)
+	assert.Empty(t, thread.Root.Reactions)
+	assert.Len(t, thread.Replies, 1)
+	assert.Equal(t, []dao.ReactionCount{{MessageID: "msg2", Emoji: "👍", Count: 2}}, thread.Replies[0].Reactions)
+	mockRepo.AssertExpectations(t)
+
+	t.Run("Given a member banned from the room, When Thread is called, Then forbidden is returned", func(t *testing.T) {
+		bannedAt := time.Now().Add(-time.Hour)
+		banned := new(roomrepomock.MockRepository)
+		banned.On("FindMember", mock.Anything, "room1", "user2").Return(&roomdao.Member{RoomID: "room1", UserID: "user2", BannedAt: &bannedAt}, nil)
+
+		_, err := New(mockRepo, banned).Thread(context.Background(), "user2", "msg1")
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, customerrors.ErrForbidden.Status, appErr.Status)
+	})
+}
+
+func TestService_MarkRead(t *testing.T) {
+	tests := []struct {
+		name       string
+		roomID     string
+		setup      func(repo *messagerepomock.MockRepository)
+		wantStatus int
+	}{
+		{
+			name:   "Given a message in the room, When MarkRead is called, Then the cursor points at it",
+			roomID: "room1",
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(storedMessage(), nil)
+				repo.On("SaveReadCursor", mock.Anything, mock.MatchedBy(func(c dao.ReadCursor) bool {
+					return c.UserID == "user2" && c.RoomID == "room1" && c.LastReadMessageID == "msg1"
+				})).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:   "Given a message from another room, When MarkRead is called, Then a not found error is returned",
+			roomID: "room2",
+			setup: func(repo *messagerepomock.MockRepository) {
+				repo.On("FindByID", mock.Anything, "msg1").Return(storedMessage(), nil)
+			},
+			wantStatus: customerrors.ErrNotFound.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			tt.setup(mockRepo)
+
+			_, err := New(mockRepo, new(roomrepomock.MockRepository)).MarkRead(context.Background(), tt.roomID, "msg1", "user2")
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Search(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     dto.SearchDTO
+		setup      func(repo *messagerepomock.MockRepository, rooms *roomrepomock.MockRepository)
+		wantStatus int
+	}{
+		{
+			name:   "Given no room filter, When Search is called, Then it searches every room the user joined",
+			filter: dto.SearchDTO{Query: " PETR4 "},
+			setup: func(repo *messagerepomock.MockRepository, rooms *roomrepomock.MockRepository) {
+				rooms.On("FindRoomIDsByUser", mock.Anything, "user1").Return([]string{"forex", "stocks"}, nil)
+				repo.On("Search", mock.Anything, dto.SearchDTO{Query: "PETR4"}, []string{"forex", "stocks"}, searchLimit).
+					Return([]dao.SearchHit{{Message: *storedMessage(), Snippet: "<mark>PETR4</mark>"}}, nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:   "Given a room the user has not joined, When Search is called, Then a forbidden error is returned",
+			filter: dto.SearchDTO{Query: "PETR4", RoomID: "vip"},
+			setup: func(repo *messagerepomock.MockRepository, rooms *roomrepomock.MockRepository) {
+				rooms.On("FindMember", mock.Anything, "vip", "user1").Return(nil, nil)
+			},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:   "Given a room the user is banned from, When Search is called, Then a forbidden error is returned",
+			filter: dto.SearchDTO{Query: "PETR4", RoomID: "vip"},
+			setup: func(repo *messagerepomock.MockRepository, rooms *roomrepomock.MockRepository) {
+				bannedAt := time.Now().Add(-time.Hour)
+				rooms.On("FindMember", mock.Anything, "vip", "user1").Return(&roomdao.Member{RoomID: "vip", UserID: "user1", BannedAt: &bannedAt}, nil)
+			},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:       "Given an empty query, When Search is called, Then a bad request error is returned",
+			filter:     dto.SearchDTO{Query: "  "},
+			setup:      func(repo *messagerepomock.MockRepository, rooms *roomrepomock.MockRepository) {},
+			wantStatus: customerrors.ErrBadRequest.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(messagerepomock.MockRepository)
+			mockRooms := new(roomrepomock.MockRepository)
+			tt.setup(mockRepo, mockRooms)
+
+			hits, err := New(mockRepo, mockRooms).Search(context.Background(), "user1", tt.filter)
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				assert.Len(t, hits, 1)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+			mockRooms.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Export(t *testing.T) {
+	defer func(size int) { exportPageSize = size }(exportPageSize)
+	exportPageSize = 2
+
+	filter := dto.ExportDTO{RoomID: "room1"}
+	first := dao.Message{Entity: entity.Entity{ID: "msg1"}, RoomID: "room1"}
+	second := dao.Message{Entity: entity.Entity{ID: "msg2"}, RoomID: "room1"}
+	third := dao.Message{Entity: entity.Entity{ID: "msg3"}, RoomID: "room1"}
+
+	mockRepo := new(messagerepomock.MockRepository)
+	mockRepo.On("FindPage", mock.Anything, filter, (*dao.Message)(nil), 2).Return([]dao.Message{first, second}, nil)
+	mockRepo.On("FindPage", mock.Anything, filter, &second, 2).Return([]dao.Message{third}, nil)
+
+	rooms := new(roomrepomock.MockRepository)
+	rooms.On("FindMember", mock.Anything, "room1", "user1").Return(&roomdao.Member{RoomID: "room1", UserID: "user1"}, nil)
+
+	var exported []string
+	err := New(mockRepo, rooms).Export(context.Background(), "user1", filter, func(m dao.Message) error {
+		exported = append(exported, m.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"msg1", "msg2", "msg3"}, exported)
+	mockRepo.AssertExpectations(t)
+
+	t.Run("Given a member banned from the room, When Export is called, Then forbidden is returned and nothing is written", func(t *testing.T) {
+		bannedAt := time.Now().Add(-time.Hour)
+		banned := new(roomrepomock.MockRepository)
+		banned.On("FindMember", mock.Anything, "room1", "user2").Return(&roomdao.Member{RoomID: "room1", UserID: "user2", BannedAt: &bannedAt}, nil)
+
+		err := New(new(messagerepomock.MockRepository), banned).Export(context.Background(), "user2", filter, func(dao.Message) error {
+			t.Fatal("a banned member exported a message")
+			return nil
+		})
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, customerrors.ErrForbidden.Status, appErr.Status)
+	})
+}
@@ -0,0 +1,35 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+)
+
+const TypeMention = "mention"
+
+// Notification tells a user about something that happened while they may not have been looking,
+// such as being mentioned in a room. It stays unread until the user marks it as read.
+type Notification struct {
+	entity.Entity
+	UserID        string     `json:"user_id" gorm:"index;not null"`
+	Type          string     `json:"type" gorm:"not null"`
+	RoomID        string     `json:"room_id"`
+	MessageID     string     `json:"message_id" gorm:"type:uuid"`
+	ActorID       string     `json:"actor_id"`
+	ActorUsername string     `json:"actor_username"`
+	Content       string     `json:"content" gorm:"type:text"`
+	ReadAt        *time.Time `json:"read_at,omitempty"`
+}
+
+func (n Notification) Build() Notification {
+	now := time.Now()
+	n.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return n
+}
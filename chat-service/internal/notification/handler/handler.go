@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	authhttp "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/http"
+	notificationsrv "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/service"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+)
+
+type Handler struct {
+	service notificationsrv.Service
+}
+
+func New(service notificationsrv.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// List serves GET /notifications; ?unread=true leaves out notifications already read.
+func (h *Handler) List(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := h.service.List(r.Context(), userID, unreadOnly)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// MarkRead serves POST /notifications/{id}/read.
+func (h *Handler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	if err := h.service.MarkRead(r.Context(), userID, r.PathValue("id")); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllRead serves POST /notifications/read.
+func (h *Handler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	if err := h.service.MarkAllRead(r.Context(), userID); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
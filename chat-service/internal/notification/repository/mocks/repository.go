@@ -0,0 +1,46 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) Create(ctx context.Context, notification dao.Notification) error {
+	args := m.Called(ctx, notification)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindByUser(ctx context.Context, userID string, unreadOnly bool, limit int) ([]dao.Notification, error) {
+	args := m.Called(ctx, userID, unreadOnly, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dao.Notification), args.Error(1)
+}
+
+func (m *MockRepository) MarkRead(ctx context.Context, userID, id string) (bool, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) MarkAllRead(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteByMessageIDs(ctx context.Context, messageIDs []string) error {
+	args := m.Called(ctx, messageIDs)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UpdateContent(ctx context.Context, messageID, content string) error {
+	args := m.Called(ctx, messageID, content)
+	return args.Error(0)
+}
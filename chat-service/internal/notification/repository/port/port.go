@@ -0,0 +1,16 @@
+package port
+
+import (
+	"context"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+)
+
+type RepositoryPort interface {
+	Create(ctx context.Context, notification dao.Notification) error
+	FindByUser(ctx context.Context, userID string, unreadOnly bool, limit int) ([]dao.Notification, error)
+	MarkRead(ctx context.Context, userID, id string) (bool, error)
+	MarkAllRead(ctx context.Context, userID string) error
+	DeleteByMessageIDs(ctx context.Context, messageIDs []string) error
+	UpdateContent(ctx context.Context, messageID, content string) error
+}
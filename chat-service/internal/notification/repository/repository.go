@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+)
+
+type DB interface {
+	Create(entity any) *gorm.DB
+	Where(query any, args ...any) *gorm.DB
+	Model(value any) *gorm.DB
+}
+
+type Repository struct {
+	db DB
+}
+
+func NewRepository(db DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(_ context.Context, notification dao.Notification) error {
+	tx := r.db.Create(&notification)
+	return tx.Error
+}
+
+// FindByUser returns up to limit notifications of a user, newest first.
+func (r *Repository) FindByUser(_ context.Context, userID string, unreadOnly bool, limit int) ([]dao.Notification, error) {
+	var notifications []dao.Notification
+
+	query := r.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	tx := query.Order("created_at DESC").Limit(limit).Find(&notifications)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return notifications, nil
+}
+
+// MarkRead marks one of the user's notifications as read. It reports false when the user has no
+// notification with that id; marking an already read notification keeps its original read time.
+func (r *Repository) MarkRead(_ context.Context, userID, id string) (bool, error) {
+	tx := r.db.Model(&dao.Notification{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+func (r *Repository) MarkAllRead(_ context.Context, userID string) error {
+	tx := r.db.Model(&dao.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	return tx.Error
+}
+
+// DeleteByMessageIDs removes the notifications about messages that no longer exist, since they
+// carry a copy of the message content.
+func (r *Repository) DeleteByMessageIDs(_ context.Context, messageIDs []string) error {
+	tx := r.db.Where("message_id IN ?", messageIDs).Delete(&dao.Notification{})
+	return tx.Error
+}
+
+// UpdateContent replaces the copy of a message's content kept by the notifications about it,
+// after the message was edited.
+func (r *Repository) UpdateContent(_ context.Context, messageID, content string) error {
+	tx := r.db.Model(&dao.Notification{}).
+		Where("message_id = ?", messageID).
+		Update("content", content)
+	return tx.Error
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/repository/mocks"
+)
+
+func Test_Create(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(db *mocks.MockDB)
+		wantErr bool
+	}{
+		{
+			name: "Given valid notification, When Create is called, Then no error is returned",
+			setup: func(db *mocks.MockDB) {
+				db.On("Create", mock.AnythingOfType("*dao.Notification")).Return(&gorm.DB{Error: nil})
+			},
+			wantErr: false,
+		},
+		{
+			name: "Given DB error, When Create is called, Then error is returned",
+			setup: func(db *mocks.MockDB) {
+				db.On("Create", mock.AnythingOfType("*dao.Notification")).Return(&gorm.DB{Error: gorm.ErrInvalidData})
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := new(mocks.MockDB)
+			tt.setup(mockDB)
+
+			repo := NewRepository(mockDB)
+			err := repo.Create(context.Background(), dao.Notification{UserID: "user1", Type: dao.TypeMention})
+
+			assert.Equal(t, tt.wantErr, err != nil)
+			mockDB.AssertExpectations(t)
+		})
+	}
+}
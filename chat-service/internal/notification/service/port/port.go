@@ -0,0 +1,13 @@
+package port
+
+import (
+	"context"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+)
+
+type NotificationService interface {
+	Mention(ctx context.Context, roomID, messageID, actorID, actorUsername, content string) ([]dao.Notification, error)
+	Forget(ctx context.Context, messageIDs []string) error
+	Revise(ctx context.Context, messageID, content string) error
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+	notificationrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/repository/port"
+	roomrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository/port"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	userrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/repository/port"
+)
+
+const (
+	// maxMentions caps how many users a single message can notify.
+	maxMentions = 10
+	// listLimit caps how many notifications List returns.
+	listLimit = 50
+)
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([\w.-]+)`)
+
+type Service struct {
+	repo  notificationrepo.RepositoryPort
+	users userrepo.RepositoryPort
+	rooms roomrepo.RepositoryPort
+}
+
+func New(repo notificationrepo.RepositoryPort, users userrepo.RepositoryPort, rooms roomrepo.RepositoryPort) *Service {
+	return &Service{
+		repo:  repo,
+		users: users,
+		rooms: rooms,
+	}
+}
+
+// Mention stores an unread mention notification for every member of roomID @mentioned in content,
+// other than the author, and returns them so they can be pushed to connected clients. Notifications
+// carry the message, so users who never joined the room or are banned from it are not notified.
+func (s *Service) Mention(ctx context.Context, roomID, messageID, actorID, actorUsername, content string) ([]dao.Notification, error) {
+	var notifications []dao.Notification
+
+	for _, username := range parseMentions(content) {
+		user, err := s.users.FindByUsername(ctx, username)
+		if err != nil || user == nil || user.ID == actorID {
+			continue
+		}
+
+		member, err := s.rooms.FindMember(ctx, roomID, user.ID)
+		if err != nil {
+			return notifications, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room membership"))
+		}
+		if member == nil || member.IsBanned(time.Now()) {
+			continue
+		}
+
+		notification := dao.Notification{
+			UserID:        user.ID,
+			Type:          dao.TypeMention,
+			RoomID:        roomID,
+			MessageID:     messageID,
+			ActorID:       actorID,
+			ActorUsername: actorUsername,
+			Content:       content,
+		}.Build()
+
+		if err := s.repo.Create(ctx, notification); err != nil {
+			return notifications, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred saving notification"))
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+// List returns the user's most recent notifications, newest first.
+func (s *Service) List(ctx context.Context, userID string, unreadOnly bool) ([]dao.Notification, error) {
+	notifications, err := s.repo.FindByUser(ctx, userID, unreadOnly, listLimit)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading notifications"))
+	}
+
+	if notifications == nil {
+		notifications = []dao.Notification{}
+	}
+	return notifications, nil
+}
+
+func (s *Service) MarkRead(ctx context.Context, userID, id string) error {
+	found, err := s.repo.MarkRead(ctx, userID, id)
+	if err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating notification"))
+	}
+	if !found {
+		return customerrors.Wrap(customerrors.ErrNotFound, errors.New("notification not found"))
+	}
+
+	return nil
+}
+
+func (s *Service) MarkAllRead(ctx context.Context, userID string) error {
+	if err := s.repo.MarkAllRead(ctx, userID); err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating notifications"))
+	}
+
+	return nil
+}
+
+// parseMentions returns the distinct usernames @mentioned in content, in order of appearance.
+// Trailing punctuation is not part of the username, so "thanks @bob." mentions bob.
+func parseMentions(content string) []string {
+	seen := make(map[string]bool)
+	var usernames []string
+
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		username := strings.TrimRight(match[1], ".-")
+		if username == "" || seen[username] {
+			continue
+		}
+
+		seen[username] = true
+		usernames = append(usernames, username)
+		if len(usernames) == maxMentions {
+			break
+		}
+	}
+
+	return usernames
+}
+
+// Forget removes the notifications about messages that were deleted.
+func (s *Service) Forget(ctx context.Context, messageIDs []string) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	if err := s.repo.DeleteByMessageIDs(ctx, messageIDs); err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred deleting notifications"))
+	}
+
+	return nil
+}
+
+// Revise updates the notifications about an edited message, so they no longer show the content
+// it was corrected from.
+func (s *Service) Revise(ctx context.Context, messageID, content string) error {
+	if err := s.repo.UpdateContent(ctx, messageID, content); err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating notifications"))
+	}
+
+	return nil
+}
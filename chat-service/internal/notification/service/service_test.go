@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+	notificationrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/repository/mocks"
+	roomdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	roomrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository/mocks"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	userdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dao"
+	userrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/repository/mocks"
+)
+
+func Test_parseMentions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "Given mentions surrounded by punctuation, When parsed, Then the usernames are returned without it",
+			content: "@alice, have you seen this? thanks @bob.",
+			want:    []string{"alice", "bob"},
+		},
+		{
+			name:    "Given the same user mentioned twice, When parsed, Then the username is returned once",
+			content: "@alice @alice",
+			want:    []string{"alice"},
+		},
+		{
+			name:    "Given an email address, When parsed, Then it is not a mention",
+			content: "write to alice@example.com",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseMentions(tt.content))
+		})
+	}
+}
+
+func TestService_Mention(t *testing.T) {
+	bannedAt := time.Now().Add(-time.Minute)
+
+	users := new(userrepomock.MockRepository)
+	for id, username := range map[string]string{"user1": "alice", "user2": "bob", "user3": "carol", "user4": "dave"} {
+		users.On("FindByUsername", mock.Anything, username).Return(&userdao.User{Entity: entity.Entity{ID: id}, Username: username}, nil)
+	}
+	users.On("FindByUsername", mock.Anything, "ghost").Return(nil, assert.AnError)
+
+	rooms := new(roomrepomock.MockRepository)
+	rooms.On("FindMember", mock.Anything, "stocks", "user2").Return(&roomdao.Member{RoomID: "stocks", UserID: "user2"}, nil)
+	rooms.On("FindMember", mock.Anything, "stocks", "user3").Return(nil, nil)
+	rooms.On("FindMember", mock.Anything, "stocks", "user4").Return(&roomdao.Member{RoomID: "stocks", UserID: "user4", BannedAt: &bannedAt}, nil)
+
+	repo := new(notificationrepomock.MockRepository)
+	repo.On("Create", mock.Anything, mock.MatchedBy(func(n dao.Notification) bool {
+		return n.ID != "" && n.UserID == "user2" && n.Type == dao.TypeMention && n.MessageID == "msg1" && n.ActorUsername == "alice"
+	})).Return(nil).Once()
+
+	// carol never joined the room and dave is banned from it, so only bob is notified.
+	notifications, err := New(repo, users, rooms).Mention(context.Background(), "stocks", "msg1", "user1", "alice", "@bob @ghost @alice @carol @dave look")
+	assert.NoError(t, err)
+	assert.Len(t, notifications, 1)
+	repo.AssertExpectations(t)
+	users.AssertExpectations(t)
+	rooms.AssertExpectations(t)
+}
+
+func TestService_MarkRead(t *testing.T) {
+	tests := []struct {
+		name       string
+		setup      func(repo *notificationrepomock.MockRepository)
+		wantStatus int
+	}{
+		{
+			name: "Given the user's notification, When MarkRead is called, Then no error is returned",
+			setup: func(repo *notificationrepomock.MockRepository) {
+				repo.On("MarkRead", mock.Anything, "user1", "n1").Return(true, nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name: "Given a notification of another user, When MarkRead is called, Then a not found error is returned",
+			setup: func(repo *notificationrepomock.MockRepository) {
+				repo.On("MarkRead", mock.Anything, "user1", "n1").Return(false, nil)
+			},
+			wantStatus: customerrors.ErrNotFound.Status,
+		},
+		{
+			name: "Given repository error, When MarkRead is called, Then an internal error is returned",
+			setup: func(repo *notificationrepomock.MockRepository) {
+				repo.On("MarkRead", mock.Anything, "user1", "n1").Return(false, assert.AnError)
+			},
+			wantStatus: customerrors.ErrInternal.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(notificationrepomock.MockRepository)
+			tt.setup(mockRepo)
+
+			err := New(mockRepo, new(userrepomock.MockRepository), new(roomrepomock.MockRepository)).MarkRead(context.Background(), "user1", "n1")
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
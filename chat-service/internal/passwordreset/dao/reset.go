@@ -0,0 +1,34 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+)
+
+// PasswordReset is a request to reset a user's password. The token mailed to the user is only
+// stored as a hash, and it works once, until ExpiresAt.
+type PasswordReset struct {
+	entity.Entity
+	UserID    string     `json:"user_id" gorm:"index;not null"`
+	TokenHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+func (r PasswordReset) Build() PasswordReset {
+	now := time.Now()
+	r.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return r
+}
+
+// IsUsable reports whether the reset can still be used at now.
+func (r PasswordReset) IsUsable(now time.Time) bool {
+	return r.UsedAt == nil && now.Before(r.ExpiresAt)
+}
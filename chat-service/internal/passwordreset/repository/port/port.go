@@ -0,0 +1,14 @@
+package port
+
+import (
+	"context"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/passwordreset/dao"
+)
+
+type RepositoryPort interface {
+	Create(ctx context.Context, reset dao.PasswordReset) error
+	FindByTokenHash(ctx context.Context, hash string) (*dao.PasswordReset, error)
+	Use(ctx context.Context, id string) (bool, error)
+	InvalidateAll(ctx context.Context, userID string) error
+}
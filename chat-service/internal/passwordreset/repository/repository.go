@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/passwordreset/dao"
+)
+
+type DB interface {
+	Where(query any, args ...any) *gorm.DB
+	Model(value any) *gorm.DB
+	Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
+}
+
+type Repository struct {
+	db DB
+}
+
+func NewRepository(db DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// Create stores reset and invalidates the user's earlier unused resets, so only the latest
+// link mailed to them works.
+func (r *Repository) Create(_ context.Context, reset dao.PasswordReset) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := invalidateUnused(tx, reset.UserID); err != nil {
+			return err
+		}
+		return tx.Create(&reset).Error
+	})
+}
+
+// InvalidateAll marks every unused reset of userID as used, so no link mailed to them works.
+func (r *Repository) InvalidateAll(_ context.Context, userID string) error {
+	return invalidateUnused(r.db, userID)
+}
+
+func invalidateUnused(db DB, userID string) error {
+	now := time.Now()
+	return db.Model(&dao.PasswordReset{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Updates(map[string]any{"used_at": now, "updated_at": now}).Error
+}
+
+// FindByTokenHash finds the reset whose token hashes to hash, returning nil when there is none.
+func (r *Repository) FindByTokenHash(_ context.Context, hash string) (*dao.PasswordReset, error) {
+	var reset dao.PasswordReset
+
+	tx := r.db.Where("token_hash = ?", hash).First(&reset)
+	if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &reset, nil
+}
+
+// Use marks a reset as used, but only if it is still unused and unexpired, so two concurrent
+// requests with the same token cannot both reset the password.
+func (r *Repository) Use(_ context.Context, id string) (bool, error) {
+	now := time.Now()
+	tx := r.db.Model(&dao.PasswordReset{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Updates(map[string]any{"used_at": now, "updated_at": now})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
@@ -0,0 +1,50 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Now()
+	limiter := NewLimiter(Limit{PerMinute: 60, Burst: 2})
+	limiter.now = func() time.Time { return now }
+
+	assert.True(t, limiter.Allow("alice"), "first token of the burst")
+	assert.True(t, limiter.Allow("alice"), "second token of the burst")
+	assert.False(t, limiter.Allow("alice"), "burst exhausted")
+	assert.True(t, limiter.Allow("bob"), "buckets are per key")
+
+	now = now.Add(time.Second)
+	assert.True(t, limiter.Allow("alice"), "one token refilled after a second")
+	assert.False(t, limiter.Allow("alice"))
+
+	now = now.Add(time.Hour)
+	assert.True(t, limiter.Allow("alice"))
+	assert.True(t, limiter.Allow("alice"))
+	assert.False(t, limiter.Allow("alice"), "refill is capped at the burst")
+}
+
+func TestLimiter_Disabled(t *testing.T) {
+	limiter := NewLimiter(Limit{})
+	for range 100 {
+		assert.True(t, limiter.Allow("alice"))
+	}
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	now := time.Now()
+	limiter := NewLimiter(Limit{PerMinute: 60, Burst: 1})
+	limiter.now = func() time.Time { return now }
+
+	for i := range sweepThreshold {
+		limiter.Allow(strconv.Itoa(i))
+	}
+	now = now.Add(time.Minute)
+	limiter.Allow("new")
+
+	assert.Len(t, limiter.buckets, 1)
+}
@@ -0,0 +1,110 @@
+package retention
+
+import (
+	"context"
+	"log"
+	"time"
+
+	messagerepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/repository/port"
+	notificationrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/repository/port"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	roomrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository/port"
+)
+
+const (
+	DefaultInterval  = time.Hour
+	DefaultBatchSize = 1000
+)
+
+// Job enforces room retention policies: on every run it purges or archives the messages of each
+// room older than the room's retention, batchSize messages per transaction so a large backlog
+// never holds long locks.
+type Job struct {
+	rooms         roomrepo.RepositoryPort
+	messages      messagerepo.RepositoryPort
+	notifications notificationrepo.RepositoryPort
+	interval      time.Duration
+	batchSize     int
+}
+
+func NewJob(rooms roomrepo.RepositoryPort, messages messagerepo.RepositoryPort, notifications notificationrepo.RepositoryPort, interval time.Duration, batchSize int) *Job {
+	return &Job{
+		rooms:         rooms,
+		messages:      messages,
+		notifications: notifications,
+		interval:      interval,
+		batchSize:     batchSize,
+	}
+}
+
+// Run enforces retention right away and then every interval, until ctx is cancelled.
+func (j *Job) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce expires the messages of every room with a retention policy and returns how many were removed.
+func (j *Job) RunOnce(ctx context.Context) int {
+	rooms, err := j.rooms.FindRoomsWithRetention(ctx)
+	if err != nil {
+		log.Printf("retention: error loading rooms: %v", err)
+		return 0
+	}
+
+	total := 0
+	now := time.Now()
+	for _, room := range rooms {
+		if ctx.Err() != nil {
+			break
+		}
+
+		cutoff, ok := room.RetentionCutoff(now)
+		if !ok {
+			continue
+		}
+
+		expired := j.expireRoom(ctx, room, cutoff)
+		if expired > 0 {
+			log.Printf("retention: %s %d messages of room %s older than %s", room.RetentionMode+"d", expired, room.ID, cutoff.Format(time.RFC3339))
+		}
+		total += expired
+	}
+
+	return total
+}
+
+func (j *Job) expireRoom(ctx context.Context, room dao.Room, cutoff time.Time) int {
+	archive := room.RetentionMode == dao.RetentionModeArchive
+
+	expired := 0
+	for ctx.Err() == nil {
+		ids, err := j.messages.ExpireBefore(ctx, room.ID, cutoff, archive, j.batchSize)
+		if err != nil {
+			log.Printf("retention: error expiring messages of room %s: %v", room.ID, err)
+			break
+		}
+
+		if len(ids) > 0 {
+			if err := j.notifications.DeleteByMessageIDs(ctx, ids); err != nil {
+				log.Printf("retention: error deleting notifications of room %s: %v", room.ID, err)
+			}
+		}
+
+		expired += len(ids)
+		if len(ids) < j.batchSize {
+			break
+		}
+	}
+
+	return expired
+}
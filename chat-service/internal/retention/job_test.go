@@ -0,0 +1,47 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	messagerepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/repository/mocks"
+	notificationrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/repository/mocks"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	roomrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository/mocks"
+)
+
+func TestJob_RunOnce(t *testing.T) {
+	thirty, seven := 30, 7
+
+	rooms := new(roomrepomock.MockRepository)
+	rooms.On("FindRoomsWithRetention", mock.Anything).Return([]dao.Room{
+		{ID: "stocks", RetentionDays: &thirty, RetentionMode: dao.RetentionModePurge},
+		{ID: "compliance", RetentionDays: &seven, RetentionMode: dao.RetentionModeArchive},
+	}, nil)
+
+	aroundCutoff := func(days int) any {
+		return mock.MatchedBy(func(cutoff time.Time) bool {
+			return cutoff.Sub(time.Now().AddDate(0, 0, -days)).Abs() < time.Minute
+		})
+	}
+
+	messages := new(messagerepomock.MockRepository)
+	// stocks has a full batch followed by a partial one; compliance has nothing left to expire.
+	messages.On("ExpireBefore", mock.Anything, "stocks", aroundCutoff(30), false, 2).Return([]string{"m1", "m2"}, nil).Once()
+	messages.On("ExpireBefore", mock.Anything, "stocks", aroundCutoff(30), false, 2).Return([]string{"m3"}, nil).Once()
+	messages.On("ExpireBefore", mock.Anything, "compliance", aroundCutoff(7), true, 2).Return([]string{}, nil).Once()
+
+	notifications := new(notificationrepomock.MockRepository)
+	notifications.On("DeleteByMessageIDs", mock.Anything, []string{"m1", "m2"}).Return(nil)
+	notifications.On("DeleteByMessageIDs", mock.Anything, []string{"m3"}).Return(nil)
+
+	expired := NewJob(rooms, messages, notifications, time.Hour, 2).RunOnce(context.Background())
+
+	assert.Equal(t, 3, expired)
+	messages.AssertExpectations(t)
+	notifications.AssertExpectations(t)
+}
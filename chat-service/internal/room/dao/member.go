@@ -0,0 +1,56 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+)
+
+const (
+	// MemberRoleMember is the role every user gets when joining a room.
+	MemberRoleMember = "member"
+	// MemberRoleModerator can mute, kick and ban members of the room.
+	MemberRoleModerator = "moderator"
+)
+
+// Member records that a user has joined a room. Rooms are created on first join, so membership
+// is what scopes access to a room's history. A nil MutedUntil or BannedUntil alongside a set
+// MutedAt or BannedAt means the sanction lasts until a moderator lifts it.
+type Member struct {
+	entity.Entity
+	RoomID      string     `json:"room_id" gorm:"not null;uniqueIndex:idx_room_member"`
+	UserID      string     `json:"user_id" gorm:"not null;uniqueIndex:idx_room_member;index"`
+	Username    string     `json:"username"`
+	Role        string     `json:"role" gorm:"not null;default:member"`
+	MutedAt     *time.Time `json:"muted_at,omitempty"`
+	MutedUntil  *time.Time `json:"muted_until,omitempty"`
+	BannedAt    *time.Time `json:"banned_at,omitempty"`
+	BannedUntil *time.Time `json:"banned_until,omitempty"`
+}
+
+func (Member) TableName() string {
+	return "room_members"
+}
+
+func (m Member) Build() Member {
+	now := time.Now()
+	m.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if m.Role == "" {
+		m.Role = MemberRoleMember
+	}
+	return m
+}
+
+func (m Member) IsMuted(now time.Time) bool {
+	return m.MutedAt != nil && (m.MutedUntil == nil || now.Before(*m.MutedUntil))
+}
+
+func (m Member) IsBanned(now time.Time) bool {
+	return m.BannedAt != nil && (m.BannedUntil == nil || now.Before(*m.BannedUntil))
+}
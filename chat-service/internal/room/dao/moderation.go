@@ -0,0 +1,46 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+)
+
+const (
+	ModerationActionMute   = "mute"
+	ModerationActionUnmute = "unmute"
+	ModerationActionKick   = "kick"
+	ModerationActionBan    = "ban"
+	ModerationActionUnban  = "unban"
+
+	// ModerationActionFilterMask and ModerationActionFilterBlock record content filter hits,
+	// which have no moderator.
+	ModerationActionFilterMask  = "filter_mask"
+	ModerationActionFilterBlock = "filter_block"
+)
+
+// ModerationEvent records an action a moderator, or a content filter, took against a member of a room.
+// ExpiresAt is set for mutes and bans that lift by themselves.
+type ModerationEvent struct {
+	entity.Entity
+	RoomID            string     `json:"room_id" gorm:"not null;index"`
+	Action            string     `json:"action" gorm:"not null"`
+	TargetID          string     `json:"target_id" gorm:"not null"`
+	TargetUsername    string     `json:"target_username"`
+	ModeratorID       string     `json:"moderator_id" gorm:"not null"`
+	ModeratorUsername string     `json:"moderator_username"`
+	Reason            string     `json:"reason,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+}
+
+func (e ModerationEvent) Build() ModerationEvent {
+	now := time.Now()
+	e.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return e
+}
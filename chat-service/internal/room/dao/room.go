@@ -0,0 +1,48 @@
+package dao
+
+import "time"
+
+const (
+	// RetentionModePurge deletes expired messages.
+	RetentionModePurge = "purge"
+	// RetentionModeArchive moves expired messages to the archive table before deleting them.
+	RetentionModeArchive = "archive"
+
+	// DefaultRoomID is the room clients join when they do not name one. It is shared by everyone,
+	// so it has no owner and only global admins administer it.
+	DefaultRoomID = "general"
+)
+
+// Room is created by the first user to join it, who becomes its owner unless the room is
+// DefaultRoomID. Rooms keep their
+// messages forever unless the owner sets RetentionDays. Filters lists the content filters
+// applied to the room's messages, all of them when nil, and FilterMode is "mask" or "block".
+type Room struct {
+	ID            string    `json:"id" gorm:"primaryKey"`
+	OwnerID       string    `json:"owner_id" gorm:"not null"`
+	RetentionDays *int      `json:"retention_days"`
+	RetentionMode string    `json:"retention_mode" gorm:"not null;default:purge"`
+	Filters       []string  `json:"filters" gorm:"type:jsonb;serializer:json"`
+	FilterMode    string    `json:"filter_mode" gorm:"not null;default:mask"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (r Room) Build() Room {
+	now := time.Now()
+	r.CreatedAt = now
+	r.UpdatedAt = now
+	if r.RetentionMode == "" {
+		r.RetentionMode = RetentionModePurge
+	}
+	return r
+}
+
+// RetentionCutoff returns the creation time before which messages of the room have expired,
+// and false when the room keeps its messages forever.
+func (r Room) RetentionCutoff(now time.Time) (time.Time, bool) {
+	if r.RetentionDays == nil {
+		return time.Time{}, false
+	}
+	return now.AddDate(0, 0, -*r.RetentionDays), true
+}
@@ -0,0 +1,8 @@
+package dto
+
+// FiltersDTO selects the content filters of a room. A null Filters enables every filter and an
+// empty list disables them all; Mode is "mask" (the default) or "block".
+type FiltersDTO struct {
+	Filters []string `json:"filters"`
+	Mode    string   `json:"mode"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+import "time"
+
+// ModerationDTO is a moderator's /mute, /unmute, /kick, /ban or /unban command against Username.
+// A zero Duration makes a mute or ban last until it is lifted.
+type ModerationDTO struct {
+	Action   string
+	Username string
+	Duration time.Duration
+	Reason   string
+}
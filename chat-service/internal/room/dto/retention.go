@@ -0,0 +1,8 @@
+package dto
+
+// RetentionDTO sets how long a room keeps its messages. A null Days keeps them forever;
+// Mode is "purge" (the default) or "archive".
+type RetentionDTO struct {
+	Days *int   `json:"days"`
+	Mode string `json:"mode"`
+}
@@ -0,0 +1,6 @@
+package dto
+
+// MemberRoleDTO sets a member's role within a room: "moderator" or "member".
+type MemberRoleDTO struct {
+	Role string `json:"role"`
+}
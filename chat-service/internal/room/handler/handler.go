@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	authhttp "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/http"
+	roomdto "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dto"
+	roomsrv "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/service"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+)
+
+var (
+	ErrInvalidRequestBody = customerrors.AppError{
+		Code:    "BAD_REQUEST",
+		Message: "invalid request body",
+		Status:  http.StatusBadRequest,
+	}
+)
+
+type Handler struct {
+	service roomsrv.Service
+}
+
+func New(service roomsrv.Service) *Handler {
+	return &Handler{
+		service: service,
+	}
+}
+
+// Get serves GET /rooms/{id}: the room's owner and retention policy.
+func (h *Handler) Get(w http.ResponseWriter, r *http.Request) {
+	room, err := h.service.Get(r.Context(), r.PathValue("id"))
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(room)
+}
+
+// SetRetention serves PUT /rooms/{id}/retention.
+func (h *Handler) SetRetention(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+	role, _ := r.Context().Value(authhttp.RoleKey).(string)
+
+	var input roomdto.RetentionDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	room, err := h.service.SetRetention(r.Context(), r.PathValue("id"), userID, role, input)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(room)
+}
+
+// SetFilters serves PUT /rooms/{id}/filters.
+func (h *Handler) SetFilters(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+	role, _ := r.Context().Value(authhttp.RoleKey).(string)
+
+	var input roomdto.FiltersDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	room, err := h.service.SetFilters(r.Context(), r.PathValue("id"), userID, role, input)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(room)
+}
+
+// ModerationHistory serves GET /rooms/{id}/moderation: the room's latest moderation events,
+// visible to its owner and moderators.
+func (h *Handler) ModerationHistory(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+	role, _ := r.Context().Value(authhttp.RoleKey).(string)
+
+	events, err := h.service.ModerationHistory(r.Context(), r.PathValue("id"), userID, role)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(events)
+}
+
+// SetMemberRole serves PUT /rooms/{id}/members/{userID}/role.
+func (h *Handler) SetMemberRole(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+	role, _ := r.Context().Value(authhttp.RoleKey).(string)
+
+	var input roomdto.MemberRoleDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	member, err := h.service.SetMemberRole(r.Context(), r.PathValue("id"), userID, role, r.PathValue("userID"), input)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(member)
+}
@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"database/sql"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type MockDB struct {
+	mock.Mock
+}
+
+func (m *MockDB) Where(query any, args ...any) *gorm.DB {
+	calledArgs := m.Called(append([]any{query}, args...)...)
+	return calledArgs.Get(0).(*gorm.DB)
+}
+
+func (m *MockDB) Clauses(conds ...clause.Expression) *gorm.DB {
+	args := m.Called(conds)
+	return args.Get(0).(*gorm.DB)
+}
+
+func (m *MockDB) Save(value any) *gorm.DB {
+	args := m.Called(value)
+	return args.Get(0).(*gorm.DB)
+}
+
+func (m *MockDB) Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error {
+	args := m.Called(fc)
+	return args.Error(0)
+}
@@ -0,0 +1,91 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+)
+
+type MockRepository struct {
+	mock.Mock
+}
+
+func (m *MockRepository) AddMember(ctx context.Context, member dao.Member) error {
+	args := m.Called(ctx, member)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindMember(ctx context.Context, roomID, userID string) (*dao.Member, error) {
+	args := m.Called(ctx, roomID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dao.Member), args.Error(1)
+}
+
+func (m *MockRepository) FindMemberByUsername(ctx context.Context, roomID, username string) (*dao.Member, error) {
+	args := m.Called(ctx, roomID, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dao.Member), args.Error(1)
+}
+
+func (m *MockRepository) FindRoomIDsByUser(ctx context.Context, userID string) ([]string, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepository) CreateRoom(ctx context.Context, room dao.Room) error {
+	args := m.Called(ctx, room)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindRoom(ctx context.Context, roomID string) (*dao.Room, error) {
+	args := m.Called(ctx, roomID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dao.Room), args.Error(1)
+}
+
+func (m *MockRepository) UpdateRoom(ctx context.Context, room dao.Room) error {
+	args := m.Called(ctx, room)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindRoomsWithRetention(ctx context.Context) ([]dao.Room, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dao.Room), args.Error(1)
+}
+
+func (m *MockRepository) UpdateMember(ctx context.Context, member dao.Member) error {
+	args := m.Called(ctx, member)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DeleteRoom(ctx context.Context, roomID string) error {
+	args := m.Called(ctx, roomID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SaveModeration(ctx context.Context, member *dao.Member, event dao.ModerationEvent) error {
+	args := m.Called(ctx, member, event)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindModerationEvents(ctx context.Context, roomID string, limit int) ([]dao.ModerationEvent, error) {
+	args := m.Called(ctx, roomID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]dao.ModerationEvent), args.Error(1)
+}
@@ -0,0 +1,22 @@
+package port
+
+import (
+	"context"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+)
+
+type RepositoryPort interface {
+	AddMember(ctx context.Context, member dao.Member) error
+	FindMember(ctx context.Context, roomID, userID string) (*dao.Member, error)
+	FindMemberByUsername(ctx context.Context, roomID, username string) (*dao.Member, error)
+	FindRoomIDsByUser(ctx context.Context, userID string) ([]string, error)
+	CreateRoom(ctx context.Context, room dao.Room) error
+	FindRoom(ctx context.Context, roomID string) (*dao.Room, error)
+	UpdateRoom(ctx context.Context, room dao.Room) error
+	FindRoomsWithRetention(ctx context.Context) ([]dao.Room, error)
+	UpdateMember(ctx context.Context, member dao.Member) error
+	DeleteRoom(ctx context.Context, roomID string) error
+	SaveModeration(ctx context.Context, member *dao.Member, event dao.ModerationEvent) error
+	FindModerationEvents(ctx context.Context, roomID string, limit int) ([]dao.ModerationEvent, error)
+}
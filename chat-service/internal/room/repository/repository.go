@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+)
+
+// DisownDefaultRoomSQL clears the owner the default room picked up when its first joiner still
+// became the owner of every room.
+const DisownDefaultRoomSQL = `UPDATE rooms SET owner_id = '' WHERE id = '` + dao.DefaultRoomID + `'`
+
+type DB interface {
+	Where(query any, args ...any) *gorm.DB
+	Clauses(conds ...clause.Expression) *gorm.DB
+	Save(value any) *gorm.DB
+	Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
+}
+
+type Repository struct {
+	db DB
+}
+
+func NewRepository(db DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+// AddMember stores a membership, doing nothing if the user already belongs to the room.
+func (r *Repository) AddMember(_ context.Context, member dao.Member) error {
+	tx := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "room_id"}, {Name: "user_id"}},
+		DoNothing: true,
+	}).Create(&member)
+	return tx.Error
+}
+
+// FindMember returns nil without error when the user is not a member of the room.
+func (r *Repository) FindMember(_ context.Context, roomID, userID string) (*dao.Member, error) {
+	var member dao.Member
+
+	tx := r.db.Where("room_id = ? AND user_id = ?", roomID, userID).First(&member)
+	if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &member, nil
+}
+
+// FindMemberByUsername returns nil without error when no member of the room has that username.
+func (r *Repository) FindMemberByUsername(_ context.Context, roomID, username string) (*dao.Member, error) {
+	var member dao.Member
+
+	tx := r.db.Where("room_id = ? AND username = ?", roomID, username).First(&member)
+	if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &member, nil
+}
+
+// FindRoomIDsByUser returns the rooms userID has joined and is not banned from.
+func (r *Repository) FindRoomIDsByUser(_ context.Context, userID string) ([]string, error) {
+	var members []dao.Member
+
+	tx := r.db.Where("user_id = ? AND (banned_at IS NULL OR banned_until <= ?)", userID, time.Now()).Order("room_id").Find(&members)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	roomIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		roomIDs = append(roomIDs, member.RoomID)
+	}
+	return roomIDs, nil
+}
+
+// CreateRoom stores a room unless one with the same ID exists, so its first owner is kept.
+func (r *Repository) CreateRoom(_ context.Context, room dao.Room) error {
+	tx := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoNothing: true,
+	}).Create(&room)
+	return tx.Error
+}
+
+// FindRoom returns nil without error when the room does not exist.
+func (r *Repository) FindRoom(_ context.Context, roomID string) (*dao.Room, error) {
+	var room dao.Room
+
+	tx := r.db.Where("id = ?", roomID).First(&room)
+	if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &room, nil
+}
+
+func (r *Repository) UpdateRoom(_ context.Context, room dao.Room) error {
+	tx := r.db.Save(&room)
+	return tx.Error
+}
+
+func (r *Repository) FindRoomsWithRetention(_ context.Context) ([]dao.Room, error) {
+	var rooms []dao.Room
+
+	tx := r.db.Where("retention_days IS NOT NULL").Order("id").Find(&rooms)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return rooms, nil
+}
+
+func (r *Repository) UpdateMember(_ context.Context, member dao.Member) error {
+	tx := r.db.Save(&member)
+	return tx.Error
+}
+
+// DeleteRoom removes a room together with its memberships and moderation events.
+func (r *Repository) DeleteRoom(_ context.Context, roomID string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("room_id = ?", roomID).Delete(&dao.ModerationEvent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("room_id = ?", roomID).Delete(&dao.Member{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", roomID).Delete(&dao.Room{}).Error
+	})
+}
+
+// SaveModeration stores a moderation event together with the sanction it put on member.
+// member is nil for actions, such as kicks, that leave nothing to persist on the membership.
+func (r *Repository) SaveModeration(_ context.Context, member *dao.Member, event dao.ModerationEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if member != nil {
+			if err := tx.Save(member).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(&event).Error
+	})
+}
+
+// FindModerationEvents returns the latest moderation events of a room, newest first.
+func (r *Repository) FindModerationEvents(_ context.Context, roomID string, limit int) ([]dao.ModerationEvent, error) {
+	var events []dao.ModerationEvent
+
+	tx := r.db.Where("room_id = ?", roomID).Order("created_at DESC").Limit(limit).Find(&events)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return events, nil
+}
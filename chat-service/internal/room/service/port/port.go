@@ -0,0 +1,18 @@
+package port
+
+import (
+	"context"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dto"
+)
+
+type RoomService interface {
+	Join(ctx context.Context, roomID, userID, username string) (*dao.Member, error)
+	IsMember(ctx context.Context, roomID, userID string) (bool, error)
+	RoomIDs(ctx context.Context, userID string) ([]string, error)
+	Get(ctx context.Context, roomID string) (*dao.Room, error)
+	RecordFilterHit(ctx context.Context, roomID, userID, username, reason string, blocked bool) error
+	Moderate(ctx context.Context, roomID, moderatorID, globalRole string, action dto.ModerationDTO) (*dao.ModerationEvent, error)
+	Delete(ctx context.Context, roomID, userID, globalRole string) error
+}
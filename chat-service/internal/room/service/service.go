@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/filter"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dto"
+	roomrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository/port"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
+)
+
+const (
+	// maxRetentionDays bounds the retention a room owner can set, roughly ten years.
+	maxRetentionDays = 3650
+	// moderationHistoryLimit caps how many events ModerationHistory returns.
+	moderationHistoryLimit = 100
+)
+
+type Service struct {
+	repo roomrepo.RepositoryPort
+}
+
+func New(repo roomrepo.RepositoryPort) *Service {
+	return &Service{
+		repo: repo,
+	}
+}
+
+// Join makes userID a member of roomID and returns the membership, with any mute in force.
+// Joining a room again keeps the existing membership; the first user to join a room creates it
+// and becomes its owner, except for the default room, which has none. Banned users cannot join.
+func (s *Service) Join(ctx context.Context, roomID, userID, username string) (*dao.Member, error) {
+	ownerID := userID
+	if roomID == dao.DefaultRoomID {
+		ownerID = ""
+	}
+	if err := s.repo.CreateRoom(ctx, dao.Room{ID: roomID, OwnerID: ownerID}.Build()); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred creating room"))
+	}
+
+	member := dao.Member{
+		RoomID:   roomID,
+		UserID:   userID,
+		Username: username,
+	}.Build()
+
+	if err := s.repo.AddMember(ctx, member); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred joining room"))
+	}
+
+	joined, err := s.repo.FindMember(ctx, roomID, userID)
+	if err != nil || joined == nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred joining room"))
+	}
+	if joined.IsBanned(time.Now()) {
+		return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("you are banned from this room"))
+	}
+
+	return joined, nil
+}
+
+// IsMember reports whether userID has joined roomID and is not banned from it.
+func (s *Service) IsMember(ctx context.Context, roomID, userID string) (bool, error) {
+	member, err := s.repo.FindMember(ctx, roomID, userID)
+	if err != nil {
+		return false, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room membership"))
+	}
+
+	return member != nil && !member.IsBanned(time.Now()), nil
+}
+
+// RoomIDs returns the rooms userID has joined and is not banned from.
+func (s *Service) RoomIDs(ctx context.Context, userID string) ([]string, error) {
+	roomIDs, err := s.repo.FindRoomIDsByUser(ctx, userID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading rooms"))
+	}
+
+	return roomIDs, nil
+}
+
+func (s *Service) Get(ctx context.Context, roomID string) (*dao.Room, error) {
+	room, err := s.repo.FindRoom(ctx, roomID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room"))
+	}
+	if room == nil {
+		return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("room not found"))
+	}
+
+	return room, nil
+}
+
+// SetRetention changes how long roomID keeps its messages. The room owner and admins can change it.
+func (s *Service) SetRetention(ctx context.Context, roomID, userID, globalRole string, retention dto.RetentionDTO) (*dao.Room, error) {
+	if retention.Days != nil && (*retention.Days < 1 || *retention.Days > maxRetentionDays) {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("days must be between 1 and 3650, or null to keep messages forever"))
+	}
+	if retention.Mode == "" {
+		retention.Mode = dao.RetentionModePurge
+	}
+	if retention.Mode != dao.RetentionModePurge && retention.Mode != dao.RetentionModeArchive {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("mode must be purge or archive"))
+	}
+
+	room, err := s.findAdministrator(ctx, roomID, userID, globalRole)
+	if err != nil {
+		return nil, err
+	}
+
+	room.RetentionDays = retention.Days
+	room.RetentionMode = retention.Mode
+	room.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateRoom(ctx, *room); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating room"))
+	}
+
+	return room, nil
+}
+
+// Moderate applies a moderator's action against another member of roomID and records it.
+// Room moderators can moderate members, the room owner can moderate moderators too, and global
+// admins and moderators can moderate anyone, the owner included.
+func (s *Service) Moderate(ctx context.Context, roomID, moderatorID, globalRole string, action dto.ModerationDTO) (*dao.ModerationEvent, error) {
+	if action.Duration < 0 {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("duration must be positive"))
+	}
+
+	room, moderator, err := s.findModerator(ctx, roomID, moderatorID, globalRole)
+	if err != nil {
+		return nil, err
+	}
+	staff := roles.CanModerateAnyRoom(globalRole)
+	var moderatorUsername string
+	if moderator != nil {
+		moderatorUsername = moderator.Username
+	}
+
+	target, err := s.repo.FindMemberByUsername(ctx, roomID, strings.TrimPrefix(action.Username, "@"))
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room membership"))
+	}
+	if target == nil {
+		return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("user is not a member of this room"))
+	}
+	if target.UserID == moderatorID {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("you cannot moderate yourself"))
+	}
+	if target.UserID == room.OwnerID && !staff {
+		return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("the room owner cannot be moderated"))
+	}
+	if target.Role == dao.MemberRoleModerator && moderatorID != room.OwnerID && !staff {
+		return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("only the room owner can moderate a moderator"))
+	}
+
+	now := time.Now()
+	var expiresAt *time.Time
+	if action.Duration > 0 {
+		until := now.Add(action.Duration)
+		expiresAt = &until
+	}
+
+	event := dao.ModerationEvent{
+		RoomID:            roomID,
+		Action:            action.Action,
+		TargetID:          target.UserID,
+		TargetUsername:    target.Username,
+		ModeratorID:       moderatorID,
+		ModeratorUsername: moderatorUsername,
+		Reason:            strings.TrimSpace(action.Reason),
+	}.Build()
+
+	switch action.Action {
+	case dao.ModerationActionMute:
+		target.MutedAt, target.MutedUntil = &now, expiresAt
+		event.ExpiresAt = expiresAt
+	case dao.ModerationActionUnmute:
+		if !target.IsMuted(now) {
+			return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("user is not muted"))
+		}
+		target.MutedAt, target.MutedUntil = nil, nil
+	case dao.ModerationActionBan:
+		target.BannedAt, target.BannedUntil = &now, expiresAt
+		event.ExpiresAt = expiresAt
+	case dao.ModerationActionUnban:
+		if !target.IsBanned(now) {
+			return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("user is not banned"))
+		}
+		target.BannedAt, target.BannedUntil = nil, nil
+	case dao.ModerationActionKick:
+		target = nil
+	default:
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("unknown moderation action"))
+	}
+
+	if target != nil {
+		target.UpdatedAt = now
+	}
+	if err := s.repo.SaveModeration(ctx, target, event); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred saving moderation"))
+	}
+
+	return &event, nil
+}
+
+// SetFilters changes which content filters run on roomID's messages and whether hits are masked
+// or blocked. Whoever can moderate the room can change them.
+func (s *Service) SetFilters(ctx context.Context, roomID, userID, globalRole string, filters dto.FiltersDTO) (*dao.Room, error) {
+	for _, name := range filters.Filters {
+		if !filter.IsKnown(name) {
+			return nil, customerrors.Wrap(customerrors.ErrBadRequest, fmt.Errorf("unknown filter %q", name))
+		}
+	}
+	if filters.Mode == "" {
+		filters.Mode = filter.ModeMask
+	}
+	if filters.Mode != filter.ModeMask && filters.Mode != filter.ModeBlock {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("mode must be mask or block"))
+	}
+
+	room, _, err := s.findModerator(ctx, roomID, userID, globalRole)
+	if err != nil {
+		return nil, err
+	}
+
+	room.Filters = filters.Filters
+	room.FilterMode = filters.Mode
+	room.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateRoom(ctx, *room); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating room"))
+	}
+
+	return room, nil
+}
+
+// RecordFilterHit logs a content filter hit on a message userID sent to roomID as a moderation event.
+func (s *Service) RecordFilterHit(ctx context.Context, roomID, userID, username, reason string, blocked bool) error {
+	action := dao.ModerationActionFilterMask
+	if blocked {
+		action = dao.ModerationActionFilterBlock
+	}
+
+	event := dao.ModerationEvent{
+		RoomID:         roomID,
+		Action:         action,
+		TargetID:       userID,
+		TargetUsername: username,
+		Reason:         reason,
+	}.Build()
+
+	if err := s.repo.SaveModeration(ctx, nil, event); err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred saving moderation"))
+	}
+
+	return nil
+}
+
+// ModerationHistory returns the latest moderation events of roomID to whoever can moderate it.
+func (s *Service) ModerationHistory(ctx context.Context, roomID, userID, globalRole string) ([]dao.ModerationEvent, error) {
+	if _, _, err := s.findModerator(ctx, roomID, userID, globalRole); err != nil {
+		return nil, err
+	}
+
+	events, err := s.repo.FindModerationEvents(ctx, roomID, moderationHistoryLimit)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading moderation history"))
+	}
+
+	return events, nil
+}
+
+// SetMemberRole makes a member of roomID one of its moderators, or a plain member again.
+// The room owner and admins can change room roles.
+func (s *Service) SetMemberRole(ctx context.Context, roomID, userID, globalRole, memberID string, role dto.MemberRoleDTO) (*dao.Member, error) {
+	if role.Role != dao.MemberRoleModerator && role.Role != dao.MemberRoleMember {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("role must be moderator or member"))
+	}
+
+	room, err := s.findAdministrator(ctx, roomID, userID, globalRole)
+	if err != nil {
+		return nil, err
+	}
+	if memberID == room.OwnerID {
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("the room owner's role cannot be changed"))
+	}
+
+	member, err := s.repo.FindMember(ctx, roomID, memberID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room membership"))
+	}
+	if member == nil {
+		return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("user is not a member of this room"))
+	}
+
+	member.Role = role.Role
+	member.UpdatedAt = time.Now()
+
+	if err := s.repo.UpdateMember(ctx, *member); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating room membership"))
+	}
+
+	return member, nil
+}
+
+// Delete removes roomID with its memberships and moderation history. The room owner and admins
+// can delete it; its messages are left to the caller.
+func (s *Service) Delete(ctx context.Context, roomID, userID, globalRole string) error {
+	if _, err := s.findAdministrator(ctx, roomID, userID, globalRole); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteRoom(ctx, roomID); err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred deleting room"))
+	}
+
+	return nil
+}
+
+// findAdministrator loads roomID, failing unless userID is its owner or a global admin.
+func (s *Service) findAdministrator(ctx context.Context, roomID, userID, globalRole string) (*dao.Room, error) {
+	room, err := s.Get(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if room.OwnerID != userID && globalRole != roles.Admin {
+		return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("only the room owner and admins can administer it"))
+	}
+
+	return room, nil
+}
+
+// findModerator loads roomID and userID's membership of it, failing unless the user is the room
+// owner, one of its moderators or a global admin or moderator. A banned membership counts as
+// none, so the membership is nil for global staff who never joined the room or are banned from it.
+func (s *Service) findModerator(ctx context.Context, roomID, userID, globalRole string) (*dao.Room, *dao.Member, error) {
+	room, err := s.Get(ctx, roomID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	member, err := s.repo.FindMember(ctx, roomID, userID)
+	if err != nil {
+		return nil, nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading room membership"))
+	}
+	if member != nil && member.IsBanned(time.Now()) {
+		member = nil
+	}
+	if roles.CanModerateAnyRoom(globalRole) {
+		return room, member, nil
+	}
+	if member == nil || (room.OwnerID != userID && member.Role != dao.MemberRoleModerator) {
+		return nil, nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("only the room owner and moderators can moderate it"))
+	}
+
+	return room, member, nil
+}
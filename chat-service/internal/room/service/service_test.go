@@ -0,0 +1,514 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/filter"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dto"
+	roomrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/repository/mocks"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
+)
+
+func TestService_Join(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		roomID     string
+		addErr     error
+		member     *dao.Member
+		wantOwner  string
+		wantStatus int
+	}{
+		{
+			name:       "Given a user, When Join is called, Then a membership is stored and returned",
+			member:     &dao.Member{RoomID: "stocks", UserID: "user1", Username: "alice"},
+			wantOwner:  "user1",
+			wantStatus: 0,
+		},
+		{
+			name:       "Given the default room, When Join is called, Then the room is created without an owner",
+			roomID:     dao.DefaultRoomID,
+			member:     &dao.Member{RoomID: dao.DefaultRoomID, UserID: "user1", Username: "alice"},
+			wantOwner:  "",
+			wantStatus: 0,
+		},
+		{
+			name:       "Given repository error, When Join is called, Then an internal error is returned",
+			addErr:     assert.AnError,
+			wantOwner:  "user1",
+			wantStatus: customerrors.ErrInternal.Status,
+		},
+		{
+			name:       "Given a banned member, When Join is called, Then a forbidden error is returned",
+			member:     &dao.Member{RoomID: "stocks", UserID: "user1", BannedAt: &past},
+			wantOwner:  "user1",
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:       "Given a member whose ban expired, When Join is called, Then the membership is returned",
+			member:     &dao.Member{RoomID: "stocks", UserID: "user1", BannedAt: &past, BannedUntil: &past},
+			wantOwner:  "user1",
+			wantStatus: 0,
+		},
+		{
+			name:       "Given a banned member with a ban still running, When Join is called, Then a forbidden error is returned",
+			member:     &dao.Member{RoomID: "stocks", UserID: "user1", BannedAt: &past, BannedUntil: &future},
+			wantOwner:  "user1",
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roomID := tt.roomID
+			if roomID == "" {
+				roomID = "stocks"
+			}
+			mockRepo := new(roomrepomock.MockRepository)
+			mockRepo.On("CreateRoom", mock.Anything, mock.MatchedBy(func(r dao.Room) bool {
+				return r.ID == roomID && r.OwnerID == tt.wantOwner && r.RetentionDays == nil
+			})).Return(nil)
+			mockRepo.On("AddMember", mock.Anything, mock.MatchedBy(func(m dao.Member) bool {
+				return m.ID != "" && m.RoomID == roomID && m.UserID == "user1" && m.Username == "alice" && m.Role == dao.MemberRoleMember
+			})).Return(tt.addErr)
+			if tt.addErr == nil {
+				mockRepo.On("FindMember", mock.Anything, roomID, "user1").Return(tt.member, nil)
+			}
+
+			got, err := New(mockRepo).Join(context.Background(), roomID, "user1", "alice")
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.member, got)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_IsMember(t *testing.T) {
+	bannedAt := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		member *dao.Member
+		want   bool
+	}{
+		{
+			name:   "Given a member, When IsMember is called, Then true is returned",
+			member: &dao.Member{RoomID: "stocks", UserID: "user1"},
+			want:   true,
+		},
+		{
+			name:   "Given a user who never joined, When IsMember is called, Then false is returned",
+			member: nil,
+			want:   false,
+		},
+		{
+			name:   "Given a banned member, When IsMember is called, Then false is returned",
+			member: &dao.Member{RoomID: "stocks", UserID: "user1", BannedAt: &bannedAt},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(roomrepomock.MockRepository)
+			mockRepo.On("FindMember", mock.Anything, "stocks", "user1").Return(tt.member, nil)
+
+			got, err := New(mockRepo).IsMember(context.Background(), "stocks", "user1")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_SetRetention(t *testing.T) {
+	thirty, zero := 30, 0
+
+	tests := []struct {
+		name       string
+		userID     string
+		globalRole string
+		retention  dto.RetentionDTO
+		setup      func(repo *roomrepomock.MockRepository)
+		wantStatus int
+	}{
+		{
+			name:      "Given the owner, When SetRetention is called, Then the policy is saved",
+			userID:    "user1",
+			retention: dto.RetentionDTO{Days: &thirty, Mode: dao.RetentionModeArchive},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindRoom", mock.Anything, "stocks").Return(&dao.Room{ID: "stocks", OwnerID: "user1"}, nil)
+				repo.On("UpdateRoom", mock.Anything, mock.MatchedBy(func(r dao.Room) bool {
+					return *r.RetentionDays == 30 && r.RetentionMode == dao.RetentionModeArchive
+				})).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:      "Given another member, When SetRetention is called, Then a forbidden error is returned",
+			userID:    "user2",
+			retention: dto.RetentionDTO{Days: &thirty},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindRoom", mock.Anything, "stocks").Return(&dao.Room{ID: "stocks", OwnerID: "user1"}, nil)
+			},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:       "Given a global admin, When SetRetention is called, Then the policy is saved",
+			userID:     "user9",
+			globalRole: roles.Admin,
+			retention:  dto.RetentionDTO{Days: &thirty},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindRoom", mock.Anything, "stocks").Return(&dao.Room{ID: "stocks", OwnerID: "user1"}, nil)
+				repo.On("UpdateRoom", mock.Anything, mock.Anything).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:       "Given a global moderator, When SetRetention is called, Then a forbidden error is returned",
+			userID:     "user9",
+			globalRole: roles.Moderator,
+			retention:  dto.RetentionDTO{Days: &thirty},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindRoom", mock.Anything, "stocks").Return(&dao.Room{ID: "stocks", OwnerID: "user1"}, nil)
+			},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:       "Given zero days, When SetRetention is called, Then a bad request error is returned",
+			userID:     "user1",
+			retention:  dto.RetentionDTO{Days: &zero},
+			setup:      func(repo *roomrepomock.MockRepository) {},
+			wantStatus: customerrors.ErrBadRequest.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(roomrepomock.MockRepository)
+			tt.setup(mockRepo)
+
+			_, err := New(mockRepo).SetRetention(context.Background(), "stocks", tt.userID, tt.globalRole, tt.retention)
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Moderate(t *testing.T) {
+	owner := &dao.Member{Entity: entity.Entity{ID: "m1"}, RoomID: "stocks", UserID: "user1", Username: "alice", Role: dao.MemberRoleMember}
+	moderator := &dao.Member{Entity: entity.Entity{ID: "m2"}, RoomID: "stocks", UserID: "user2", Username: "bob", Role: dao.MemberRoleModerator}
+	member := &dao.Member{Entity: entity.Entity{ID: "m3"}, RoomID: "stocks", UserID: "user3", Username: "carol", Role: dao.MemberRoleMember}
+	now := time.Now()
+	mutedMember := &dao.Member{Entity: entity.Entity{ID: "m3"}, RoomID: "stocks", UserID: "user3", Username: "carol", MutedAt: &now}
+
+	tests := []struct {
+		name        string
+		moderatorID string
+		globalRole  string
+		action      dto.ModerationDTO
+		setup       func(repo *roomrepomock.MockRepository)
+		wantStatus  int
+	}{
+		{
+			name:        "Given the owner, When a member is muted for ten minutes, Then the mute and its event are saved",
+			moderatorID: "user1",
+			action:      dto.ModerationDTO{Action: dao.ModerationActionMute, Username: "@carol", Duration: 10 * time.Minute, Reason: "spam"},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user1").Return(owner, nil)
+				repo.On("FindMemberByUsername", mock.Anything, "stocks", "carol").Return(copyMember(member), nil)
+				repo.On("SaveModeration", mock.Anything, mock.MatchedBy(func(m *dao.Member) bool {
+					return m.UserID == "user3" && m.MutedAt != nil && m.MutedUntil != nil && m.IsMuted(time.Now())
+				}), mock.MatchedBy(func(e dao.ModerationEvent) bool {
+					return e.ID != "" && e.Action == dao.ModerationActionMute && e.TargetID == "user3" &&
+						e.ModeratorUsername == "alice" && e.Reason == "spam" && e.ExpiresAt != nil
+				})).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:        "Given a moderator, When a member is banned without a duration, Then the ban never expires",
+			moderatorID: "user2",
+			action:      dto.ModerationDTO{Action: dao.ModerationActionBan, Username: "carol"},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user2").Return(moderator, nil)
+				repo.On("FindMemberByUsername", mock.Anything, "stocks", "carol").Return(copyMember(member), nil)
+				repo.On("SaveModeration", mock.Anything, mock.MatchedBy(func(m *dao.Member) bool {
+					return m.BannedAt != nil && m.BannedUntil == nil
+				}), mock.MatchedBy(func(e dao.ModerationEvent) bool {
+					return e.Action == dao.ModerationActionBan && e.ExpiresAt == nil
+				})).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:        "Given a moderator, When a member is kicked, Then only the event is saved",
+			moderatorID: "user2",
+			action:      dto.ModerationDTO{Action: dao.ModerationActionKick, Username: "carol"},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user2").Return(moderator, nil)
+				repo.On("FindMemberByUsername", mock.Anything, "stocks", "carol").Return(copyMember(member), nil)
+				repo.On("SaveModeration", mock.Anything, (*dao.Member)(nil), mock.MatchedBy(func(e dao.ModerationEvent) bool {
+					return e.Action == dao.ModerationActionKick
+				})).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:        "Given a muted member, When they are unmuted, Then the mute is cleared",
+			moderatorID: "user1",
+			action:      dto.ModerationDTO{Action: dao.ModerationActionUnmute, Username: "carol"},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user1").Return(owner, nil)
+				repo.On("FindMemberByUsername", mock.Anything, "stocks", "carol").Return(copyMember(mutedMember), nil)
+				repo.On("SaveModeration", mock.Anything, mock.MatchedBy(func(m *dao.Member) bool {
+					return m.MutedAt == nil && m.MutedUntil == nil
+				}), mock.Anything).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:        "Given a member who is not muted, When they are unmuted, Then a bad request error is returned",
+			moderatorID: "user1",
+			action:      dto.ModerationDTO{Action: dao.ModerationActionUnmute, Username: "carol"},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user1").Return(owner, nil)
+				repo.On("FindMemberByUsername", mock.Anything, "stocks", "carol").Return(copyMember(member), nil)
+			},
+			wantStatus: customerrors.ErrBadRequest.Status,
+		},
+		{
+			name:        "Given a regular member, When they try to kick someone, Then a forbidden error is returned",
+			moderatorID: "user3",
+			action:      dto.ModerationDTO{Action: dao.ModerationActionKick, Username: "bob"},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user3").Return(member, nil)
+			},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:        "Given a moderator, When they try to ban the owner, Then a forbidden error is returned",
+			moderatorID: "user2",
+			action:      dto.ModerationDTO{Action: dao.ModerationActionBan, Username: "alice"},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user2").Return(moderator, nil)
+				repo.On("FindMemberByUsername", mock.Anything, "stocks", "alice").Return(copyMember(owner), nil)
+			},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:        "Given a global moderator who never joined, When they kick the owner, Then the kick is saved",
+			moderatorID: "user9",
+			globalRole:  roles.Moderator,
+			action:      dto.ModerationDTO{Action: dao.ModerationActionKick, Username: "alice"},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user9").Return(nil, nil)
+				repo.On("FindMemberByUsername", mock.Anything, "stocks", "alice").Return(copyMember(owner), nil)
+				repo.On("SaveModeration", mock.Anything, (*dao.Member)(nil), mock.MatchedBy(func(e dao.ModerationEvent) bool {
+					return e.ModeratorID == "user9" && e.TargetID == "user1"
+				})).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:        "Given the owner, When they target a user outside the room, Then a not found error is returned",
+			moderatorID: "user1",
+			action:      dto.ModerationDTO{Action: dao.ModerationActionKick, Username: "dave"},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user1").Return(owner, nil)
+				repo.On("FindMemberByUsername", mock.Anything, "stocks", "dave").Return(nil, nil)
+			},
+			wantStatus: customerrors.ErrNotFound.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(roomrepomock.MockRepository)
+			mockRepo.On("FindRoom", mock.Anything, "stocks").Return(&dao.Room{ID: "stocks", OwnerID: "user1"}, nil)
+			tt.setup(mockRepo)
+
+			event, err := New(mockRepo).Moderate(context.Background(), "stocks", tt.moderatorID, tt.globalRole, tt.action)
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.action.Action, event.Action)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func copyMember(member *dao.Member) *dao.Member {
+	copied := *member
+	return &copied
+}
+
+func TestService_SetFilters(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     string
+		filters    dto.FiltersDTO
+		setup      func(repo *roomrepomock.MockRepository)
+		wantStatus int
+	}{
+		{
+			name:    "Given a moderator, When SetFilters is called, Then the configuration is saved",
+			userID:  "user2",
+			filters: dto.FiltersDTO{Filters: []string{filter.NameProfanity, filter.NameLinks}, Mode: filter.ModeBlock},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user2").Return(&dao.Member{UserID: "user2", Role: dao.MemberRoleModerator}, nil)
+				repo.On("UpdateRoom", mock.Anything, mock.MatchedBy(func(r dao.Room) bool {
+					return len(r.Filters) == 2 && r.FilterMode == filter.ModeBlock
+				})).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:    "Given no mode, When SetFilters is called, Then hits are masked",
+			userID:  "user1",
+			filters: dto.FiltersDTO{Filters: []string{}},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user1").Return(&dao.Member{UserID: "user1"}, nil)
+				repo.On("UpdateRoom", mock.Anything, mock.MatchedBy(func(r dao.Room) bool {
+					return r.Filters != nil && len(r.Filters) == 0 && r.FilterMode == filter.ModeMask
+				})).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:       "Given an unknown filter, When SetFilters is called, Then a bad request error is returned",
+			userID:     "user1",
+			filters:    dto.FiltersDTO{Filters: []string{"caps"}},
+			setup:      func(repo *roomrepomock.MockRepository) {},
+			wantStatus: customerrors.ErrBadRequest.Status,
+		},
+		{
+			name:    "Given a regular member, When SetFilters is called, Then a forbidden error is returned",
+			userID:  "user3",
+			filters: dto.FiltersDTO{Mode: filter.ModeBlock},
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user3").Return(&dao.Member{UserID: "user3", Role: dao.MemberRoleMember}, nil)
+			},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:    "Given a moderator banned from the room, When SetFilters is called, Then a forbidden error is returned",
+			userID:  "user2",
+			filters: dto.FiltersDTO{Mode: filter.ModeBlock},
+			setup: func(repo *roomrepomock.MockRepository) {
+				bannedAt := time.Now().Add(-time.Hour)
+				repo.On("FindMember", mock.Anything, "stocks", "user2").Return(&dao.Member{UserID: "user2", Role: dao.MemberRoleModerator, BannedAt: &bannedAt}, nil)
+			},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(roomrepomock.MockRepository)
+			mockRepo.On("FindRoom", mock.Anything, "stocks").Return(&dao.Room{ID: "stocks", OwnerID: "user1"}, nil).Maybe()
+			tt.setup(mockRepo)
+
+			_, err := New(mockRepo).SetFilters(context.Background(), "stocks", tt.userID, roles.Member, tt.filters)
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_SetMemberRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     string
+		memberID   string
+		role       string
+		setup      func(repo *roomrepomock.MockRepository)
+		wantStatus int
+	}{
+		{
+			name:     "Given the owner, When a member is made moderator, Then the membership is updated",
+			userID:   "user1",
+			memberID: "user3",
+			role:     dao.MemberRoleModerator,
+			setup: func(repo *roomrepomock.MockRepository) {
+				repo.On("FindMember", mock.Anything, "stocks", "user3").Return(&dao.Member{UserID: "user3", Role: dao.MemberRoleMember}, nil)
+				repo.On("UpdateMember", mock.Anything, mock.MatchedBy(func(m dao.Member) bool {
+					return m.UserID == "user3" && m.Role == dao.MemberRoleModerator
+				})).Return(nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:       "Given a room moderator, When they promote someone, Then a forbidden error is returned",
+			userID:     "user2",
+			memberID:   "user3",
+			role:       dao.MemberRoleModerator,
+			setup:      func(repo *roomrepomock.MockRepository) {},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:       "Given the owner, When they change their own role, Then a bad request error is returned",
+			userID:     "user1",
+			memberID:   "user1",
+			role:       dao.MemberRoleMember,
+			setup:      func(repo *roomrepomock.MockRepository) {},
+			wantStatus: customerrors.ErrBadRequest.Status,
+		},
+		{
+			name:       "Given an unknown role, When SetMemberRole is called, Then a bad request error is returned",
+			userID:     "user1",
+			memberID:   "user3",
+			role:       "owner",
+			setup:      func(repo *roomrepomock.MockRepository) {},
+			wantStatus: customerrors.ErrBadRequest.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(roomrepomock.MockRepository)
+			mockRepo.On("FindRoom", mock.Anything, "stocks").Return(&dao.Room{ID: "stocks", OwnerID: "user1"}, nil).Maybe()
+			tt.setup(mockRepo)
+
+			_, err := New(mockRepo).SetMemberRole(context.Background(), "stocks", tt.userID, roles.Member, tt.memberID, dto.MemberRoleDTO{Role: tt.role})
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
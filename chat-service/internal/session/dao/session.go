@@ -0,0 +1,36 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+)
+
+// Session is a login. Access tokens carry its ID and are rejected once it is revoked; its
+// refresh token rotates on every use and only its hash is stored. PreviousTokenHash keeps the
+// hash of the token it replaced, so a stolen token replayed after rotation can be detected.
+type Session struct {
+	entity.Entity
+	UserID            string     `json:"user_id" gorm:"index;not null"`
+	TokenHash         string     `json:"-" gorm:"uniqueIndex;not null"`
+	PreviousTokenHash string     `json:"-" gorm:"index"`
+	ExpiresAt         time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
+}
+
+func (s Session) Build() Session {
+	now := time.Now()
+	s.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return s
+}
+
+// IsActive reports whether the session can still be used at now.
+func (s Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
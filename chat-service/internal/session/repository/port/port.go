@@ -0,0 +1,17 @@
+package port
+
+import (
+	"context"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/dao"
+)
+
+type RepositoryPort interface {
+	Create(ctx context.Context, session dao.Session) error
+	FindByTokenHash(ctx context.Context, hash string) (*dao.Session, error)
+	Rotate(ctx context.Context, id, oldHash, newHash string, expiresAt time.Time) (bool, error)
+	Revoke(ctx context.Context, id string) error
+	RevokeAll(ctx context.Context, userID, exceptID string) ([]string, error)
+	IsActive(ctx context.Context, id string) (bool, error)
+}
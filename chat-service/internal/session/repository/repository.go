@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/dao"
+)
+
+type DB interface {
+	Create(entity any) *gorm.DB
+	Where(query any, args ...any) *gorm.DB
+	Model(value any) *gorm.DB
+}
+
+type Repository struct {
+	db DB
+}
+
+func NewRepository(db DB) *Repository {
+	return &Repository{
+		db: db,
+	}
+}
+
+func (r *Repository) Create(_ context.Context, session dao.Session) error {
+	tx := r.db.Create(&session)
+	return tx.Error
+}
+
+// FindByTokenHash finds the session whose current or previous refresh token hashes to hash,
+// returning nil when there is none.
+func (r *Repository) FindByTokenHash(_ context.Context, hash string) (*dao.Session, error) {
+	var session dao.Session
+
+	tx := r.db.Where("token_hash = ? OR previous_token_hash = ?", hash, hash).First(&session)
+	if errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &session, nil
+}
+
+// Rotate replaces the refresh token of an active session, but only if oldHash is still its
+// current token, so two concurrent refreshes with the same token cannot both succeed.
+func (r *Repository) Rotate(_ context.Context, id, oldHash, newHash string, expiresAt time.Time) (bool, error) {
+	tx := r.db.Model(&dao.Session{}).
+		Where("id = ? AND token_hash = ? AND revoked_at IS NULL", id, oldHash).
+		Updates(map[string]any{
+			"token_hash":          newHash,
+			"previous_token_hash": oldHash,
+			"expires_at":          expiresAt,
+			"updated_at":          time.Now(),
+		})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+func (r *Repository) Revoke(_ context.Context, id string) error {
+	now := time.Now()
+	tx := r.db.Model(&dao.Session{}).Where("id = ? AND revoked_at IS NULL", id).Updates(map[string]any{
+		"revoked_at": now,
+		"updated_at": now,
+	})
+	return tx.Error
+}
+
+// RevokeAll revokes every session of userID that is not revoked yet, except exceptID,
+// returning the IDs of the sessions it revoked.
+func (r *Repository) RevokeAll(_ context.Context, userID, exceptID string) ([]string, error) {
+	var ids []string
+
+	query := r.db.Model(&dao.Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if exceptID != "" {
+		// IDs are UUIDs, which an empty string cannot be compared with.
+		query = query.Where("id <> ?", exceptID)
+	}
+	tx := query.Pluck("id", &ids)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	tx = r.db.Model(&dao.Session{}).Where("id IN ? AND revoked_at IS NULL", ids).Updates(map[string]any{
+		"revoked_at": now,
+		"updated_at": now,
+	})
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return ids, nil
+}
+
+// IsActive reports whether the session exists and is neither revoked nor expired.
+func (r *Repository) IsActive(_ context.Context, id string) (bool, error) {
+	var count int64
+
+	tx := r.db.Model(&dao.Session{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+		Count(&count)
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return count > 0, nil
+}
@@ -10,7 +10,10 @@ var (
 	ErrNotFound      = NewAppError("NOT_FOUND", "Resource not found", http.StatusNotFound, nil)
 	ErrInternal      = NewAppError("INTERNAL_ERROR", "Internal server error", http.StatusInternalServerError, nil)
 	ErrUnauthorized  = NewAppError("UNAUTHORIZED", "Unauthorized", http.StatusUnauthorized, nil)
+	ErrForbidden     = NewAppError("FORBIDDEN", "Forbidden", http.StatusForbidden, nil)
 	ErrUnprocessable = NewAppError("UNPROCESSABLE", "Unprocessable entity", http.StatusUnprocessableEntity, nil)
+	ErrLocked        = NewAppError("LOCKED", "Resource is locked", http.StatusLocked, nil)
+	ErrTooMany       = NewAppError("TOO_MANY_REQUESTS", "Too many requests", http.StatusTooManyRequests, nil)
 )
 
 func NewAppError(code, message string, status int, err error) *AppError {
@@ -22,6 +25,16 @@ func NewAppError(code, message string, status int, err error) *AppError {
 	}
 }
 
+// NewValidationError reports the fields of a request that failed validation.
+func NewValidationError(details []FieldError) *AppError {
+	return &AppError{
+		Code:    "VALIDATION_ERROR",
+		Message: "request validation failed",
+		Details: details,
+		Status:  http.StatusBadRequest,
+	}
+}
+
 func Wrap(base *AppError, err error) *AppError {
 	if base == nil {
 		return ErrInternal
@@ -3,8 +3,18 @@ package errors
 type AppError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
-	Status  int    `json:"-"`
-	Err     error  `json:"-"`
+	// Details lists the fields that failed validation, if that is why the request was refused.
+	Details []FieldError `json:"details,omitempty"`
+	Status  int          `json:"-"`
+	Err     error        `json:"-"`
+}
+
+// FieldError describes why one request field is invalid. Field is the field's JSON name and
+// Rule the rule it broke, such as "required" or "min".
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 func (e *AppError) Error() string {
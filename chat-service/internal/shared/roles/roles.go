@@ -0,0 +1,22 @@
+package roles
+
+// Global roles carried by every user and in their tokens. Tokens issued before roles existed
+// carry none, which is treated as Member.
+const (
+	// Admin manages user roles and can administer and moderate every room.
+	Admin = "admin"
+	// Moderator can moderate every room.
+	Moderator = "moderator"
+	// Member has no rights beyond the rooms they own or moderate.
+	Member = "member"
+)
+
+func IsValid(role string) bool {
+	return role == Admin || role == Moderator || role == Member
+}
+
+// CanModerateAnyRoom reports whether role grants moderation rights in rooms the user neither
+// owns nor moderates.
+func CanModerateAnyRoom(role string) bool {
+	return role == Admin || role == Moderator
+}
@@ -0,0 +1,165 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	// The service image has no zoneinfo, so the timezone rule uses the database embedded here.
+	_ "time/tzdata"
+	"unicode/utf8"
+
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+)
+
+// usernamePattern keeps usernames to what a @mention can match: ASCII letters, digits, '.',
+// '_' and '-', starting and ending with a letter or digit so a mention followed by
+// punctuation still resolves.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?$`)
+
+// localePattern matches the shape of a BCP 47 language tag: a language, then subtags such as
+// a script or region.
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(?:-[A-Za-z0-9]{2,8})*$`)
+
+// Validate checks the string fields of the struct v points to, or is, against the
+// comma-separated rules of their binding tag. Fields that are nil string pointers were left
+// out of the request and are skipped.
+//   - required: not empty;
+//   - min=N and max=N: at least and at most N characters;
+//   - username: a valid username, see usernamePattern;
+//   - email: a bare email address, without a display name;
+//   - url: an absolute http or https URL;
+//   - timezone: an IANA time zone name, such as "Europe/Lisbon";
+//   - locale: a BCP 47 language tag, such as "pt-BR".
+//
+// Apart from required, rules accept an empty value.
+//
+// Every invalid field is reported, with the first rule it breaks, in a validation AppError.
+func Validate(v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: cannot validate %T", v))
+	}
+
+	var details []customerrors.FieldError
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		tag := field.Tag.Get("binding")
+		if tag == "" {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Pointer && fieldValue.Type().Elem().Kind() == reflect.String {
+			if fieldValue.IsNil() {
+				continue
+			}
+			fieldValue = fieldValue.Elem()
+		}
+		if fieldValue.Kind() != reflect.String {
+			continue
+		}
+
+		name := jsonName(field)
+		if detail, ok := check(name, fieldValue.String(), tag); !ok {
+			details = append(details, detail)
+		}
+	}
+
+	if len(details) > 0 {
+		return customerrors.NewValidationError(details)
+	}
+	return nil
+}
+
+// check applies rules to value in order, returning the first one it breaks.
+func check(name, value, rules string) (customerrors.FieldError, bool) {
+	length := utf8.RuneCountInString(value)
+
+	for _, rule := range strings.Split(rules, ",") {
+		rule, param, _ := strings.Cut(rule, "=")
+
+		var message string
+		switch rule {
+		case "required":
+			if value == "" {
+				message = fmt.Sprintf("%s is required", name)
+			}
+		case "min":
+			if n := atoi(param); value != "" && length < n {
+				message = fmt.Sprintf("%s must be at least %d characters", name, n)
+			}
+		case "max":
+			if n := atoi(param); length > n {
+				message = fmt.Sprintf("%s must be at most %d characters", name, n)
+			}
+		case "username":
+			if value != "" && !usernamePattern.MatchString(value) {
+				message = fmt.Sprintf("%s may only contain letters, digits, '.', '_' and '-', and must start and end with a letter or digit", name)
+			}
+		case "email":
+			if value != "" && !isEmail(value) {
+				message = fmt.Sprintf("%s must be an email address", name)
+			}
+		case "url":
+			if value != "" && !isWebURL(value) {
+				message = fmt.Sprintf("%s must be an http or https URL", name)
+			}
+		case "timezone":
+			if value != "" && !isTimezone(value) {
+				message = fmt.Sprintf("%s must be an IANA time zone, such as Europe/Lisbon", name)
+			}
+		case "locale":
+			if value != "" && !localePattern.MatchString(value) {
+				message = fmt.Sprintf("%s must be a language tag, such as pt-BR", name)
+			}
+		default:
+			panic(fmt.Sprintf("validation: unknown rule %q on %s", rule, name))
+		}
+
+		if message != "" {
+			return customerrors.FieldError{Field: name, Rule: rule, Message: message}, false
+		}
+	}
+	return customerrors.FieldError{}, true
+}
+
+func isEmail(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
+
+func isWebURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// isTimezone reports whether value names a zone. LoadLocation also accepts "" and "Local",
+// which depend on the server and so are not zones a user can pick.
+func isTimezone(value string) bool {
+	if value == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(value)
+	return err == nil
+}
+
+func atoi(param string) int {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		panic(fmt.Sprintf("validation: invalid rule parameter %q", param))
+	}
+	return n
+}
+
+// jsonName is the name a field has in request bodies.
+func jsonName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
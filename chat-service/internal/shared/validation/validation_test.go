@@ -0,0 +1,95 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+)
+
+type input struct {
+	Name     string `json:"name" binding:"required,min=3,max=5,username"`
+	Nickname string `json:"nickname,omitempty" binding:"max=4"`
+	Ignored  string
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       input
+		wantDetails []customerrors.FieldError
+	}{
+		{
+			name:  "Given every field within its rules, When Validate is called, Then no error is returned",
+			input: input{Name: "a.b-c", Nickname: "ünï"},
+		},
+		{
+			name:  "Given multi-byte characters, When lengths are checked, Then characters are counted instead of bytes",
+			input: input{Name: "bob", Nickname: "ééééé"},
+			wantDetails: []customerrors.FieldError{
+				{Field: "nickname", Rule: "max", Message: "nickname must be at most 4 characters"},
+			},
+		},
+		{
+			name:  "Given a field breaking several rules, When Validate is called, Then only the first is reported",
+			input: input{Name: "-"},
+			wantDetails: []customerrors.FieldError{
+				{Field: "name", Rule: "min", Message: "name must be at least 3 characters"},
+			},
+		},
+		{
+			name:  "Given a username ending in punctuation, When Validate is called, Then the username rule is reported",
+			input: input{Name: "bob."},
+			wantDetails: []customerrors.FieldError{
+				{Field: "name", Rule: "username", Message: "name may only contain letters, digits, '.', '_' and '-', and must start and end with a letter or digit"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.input)
+			if tt.wantDetails == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var appErr *customerrors.AppError
+			require.ErrorAs(t, err, &appErr)
+			assert.Equal(t, 400, appErr.Status)
+			assert.Equal(t, tt.wantDetails, appErr.Details)
+		})
+	}
+
+	t.Run("Given optional profile fields, When Validate is called, Then only the fields present are checked", func(t *testing.T) {
+		type profile struct {
+			AvatarURL *string `json:"avatar_url" binding:"url"`
+			Timezone  *string `json:"timezone" binding:"timezone"`
+			Locale    *string `json:"locale" binding:"locale"`
+		}
+		avatar, timezone, locale, empty := "ftp://example.com/a.png", "Mars/Olympus", "pt-BR", ""
+
+		assert.NoError(t, Validate(profile{}))
+		assert.NoError(t, Validate(profile{AvatarURL: &empty, Timezone: &empty, Locale: &empty}))
+
+		var appErr *customerrors.AppError
+		require.ErrorAs(t, Validate(profile{AvatarURL: &avatar, Timezone: &timezone, Locale: &locale}), &appErr)
+		assert.Equal(t, []customerrors.FieldError{
+			{Field: "avatar_url", Rule: "url", Message: "avatar_url must be an http or https URL"},
+			{Field: "timezone", Rule: "timezone", Message: "timezone must be an IANA time zone, such as Europe/Lisbon"},
+		}, appErr.Details)
+
+		timezone = "America/Sao_Paulo"
+		assert.NoError(t, Validate(profile{Timezone: &timezone}))
+	})
+
+	t.Run("Given an unknown rule, When Validate is called, Then it panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			_ = Validate(struct {
+				Name string `binding:"phone"`
+			}{})
+		})
+	})
+}
@@ -0,0 +1,28 @@
+package dao
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+)
+
+// RecoveryCode lets a user with two-factor authentication log in without their authenticator
+// app. Each code works once and only its hash is stored.
+type RecoveryCode struct {
+	entity.Entity
+	UserID   string     `json:"-" gorm:"index;not null"`
+	CodeHash string     `json:"-" gorm:"uniqueIndex;not null"`
+	UsedAt   *time.Time `json:"used_at,omitempty"`
+}
+
+func (c RecoveryCode) Build() RecoveryCode {
+	now := time.Now()
+	c.Entity = entity.Entity{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return c
+}
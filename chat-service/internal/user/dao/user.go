@@ -2,19 +2,49 @@ package dao
 
 import (
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dto"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// DeletedUsername replaces the username on what a deleted account leaves behind.
+const DeletedUsername = "deleted user"
+
+// User is an account. DisplayName, AvatarURL, Timezone and Locale make up the profile the user
+// edits; Timezone is an IANA name such as "America/Sao_Paulo" and Locale a BCP 47 tag such as "pt-BR".
+// Email is optional and stored lowercase; password reset links are sent to it.
+// TOTPSecret is set while the user enrols an authenticator app and TOTPEnabledAt once they
+// confirm it; TOTPLastStep is the time step of the last code accepted, which cannot be reused.
 type User struct {
 	entity.Entity
-	Username string `json:"username" gorm:"unique;not null"`
-	Password string `json:"-" gorm:"not null"`
+	Username    string  `json:"username" gorm:"unique;not null"`
+	Password    string  `json:"-" gorm:"not null"`
+	Role        string  `json:"role" gorm:"not null;default:member"`
+	Email       *string `json:"email,omitempty" gorm:"uniqueIndex"`
+	DisplayName string  `json:"display_name"`
+	AvatarURL   string  `json:"avatar_url"`
+	Timezone    string  `json:"timezone"`
+	Locale      string  `json:"locale"`
+
+	TOTPSecret    string     `json:"-" gorm:"column:totp_secret"`
+	TOTPEnabledAt *time.Time `json:"-" gorm:"column:totp_enabled_at"`
+	TOTPLastStep  int64      `json:"-" gorm:"column:totp_last_step;not null;default:0"`
+}
+
+// MFAEnabled reports whether logging in takes a second factor.
+func (u User) MFAEnabled() bool {
+	return u.TOTPEnabledAt != nil
 }
 
 func (u User) Build(password string) User {
+	role := u.Role
+	if role == "" {
+		role = roles.Member
+	}
+
 	return User{
 		Entity: entity.Entity{
 			ID:        uuid.NewString(),
@@ -23,6 +53,8 @@ func (u User) Build(password string) User {
 		},
 		Username: u.Username,
 		Password: password,
+		Role:     role,
+		Email:    u.Email,
 	}
 }
 
@@ -30,12 +62,61 @@ func (u User) FromRegisterDTO(dto dto.RegisterDTO) User {
 	return User{
 		Username: dto.Username,
 		Password: dto.Password,
+		Email:    NormalizeEmail(dto.Email),
 	}
 }
 
+// NormalizeEmail lowercases email so addresses compare equal whatever their case, returning nil
+// for an empty address.
+func NormalizeEmail(email string) *string {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil
+	}
+	return &email
+}
+
 func (u User) FromLoginDTO(dto dto.LoginDTO) User {
 	return User{
 		Username: dto.Username,
 		Password: dto.Password,
 	}
 }
+
+func (u User) ToProfileDTO() dto.ProfileDTO {
+	profile := dto.ProfileDTO{
+		ID:          u.ID,
+		Username:    u.Username,
+		Role:        u.Role,
+		DisplayName: u.DisplayName,
+		AvatarURL:   u.AvatarURL,
+		Timezone:    u.Timezone,
+		Locale:      u.Locale,
+		MFAEnabled:  u.MFAEnabled(),
+		CreatedAt:   u.CreatedAt,
+	}
+	if u.Email != nil {
+		profile.Email = *u.Email
+	}
+	return profile
+}
+
+// ApplyProfile copies the fields set in update onto the user's profile.
+func (u User) ApplyProfile(update dto.UpdateProfileDTO) User {
+	if update.DisplayName != nil {
+		u.DisplayName = *update.DisplayName
+	}
+	if update.AvatarURL != nil {
+		u.AvatarURL = *update.AvatarURL
+	}
+	if update.Timezone != nil {
+		u.Timezone = *update.Timezone
+	}
+	if update.Locale != nil {
+		u.Locale = *update.Locale
+	}
+	if update.Email != nil {
+		u.Email = NormalizeEmail(*update.Email)
+	}
+	return u
+}
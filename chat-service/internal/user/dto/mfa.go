@@ -0,0 +1,32 @@
+package dto
+
+// TOTPEnrolmentDTO starts enrolling an authenticator app. URI is the otpauth:// URI to show as a
+// QR code; Secret is the same key for apps that take it typed in.
+type TOTPEnrolmentDTO struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// MFACodeDTO carries a code from the user's authenticator app.
+type MFACodeDTO struct {
+	Code string `json:"code" binding:"required,max=32"`
+}
+
+// RecoveryCodesDTO lists new recovery codes. They are only ever shown once.
+type RecoveryCodesDTO struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// DisableMFADTO confirms turning two-factor authentication off with the password and a code
+// from the authenticator app or a recovery code.
+type DisableMFADTO struct {
+	Password string `json:"password" binding:"required,max=72"`
+	Code     string `json:"code" binding:"required,max=32"`
+}
+
+// MFALoginDTO completes a login that needs a second factor. Code is a code from the
+// authenticator app or a recovery code.
+type MFALoginDTO struct {
+	MFAToken string `json:"mfa_token" binding:"required,max=128"`
+	Code     string `json:"code" binding:"required,max=32"`
+}
@@ -0,0 +1,38 @@
+package dto
+
+import "time"
+
+// ProfileDTO is what GET and PATCH /me return about the caller's account.
+type ProfileDTO struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`
+	Email       string    `json:"email,omitempty"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+	Timezone    string    `json:"timezone"`
+	Locale      string    `json:"locale"`
+	MFAEnabled  bool      `json:"mfa_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// UpdateProfileDTO is a PATCH /me body. Only the fields present are changed, and an empty
+// string clears a field. Changing the email needs CurrentPassword.
+type UpdateProfileDTO struct {
+	DisplayName     *string `json:"display_name" binding:"max=50"`
+	AvatarURL       *string `json:"avatar_url" binding:"max=2048,url"`
+	Timezone        *string `json:"timezone" binding:"timezone"`
+	Locale          *string `json:"locale" binding:"max=35,locale"`
+	Email           *string `json:"email" binding:"max=254,email"`
+	CurrentPassword string  `json:"current_password" binding:"max=72"`
+}
+
+type ChangePasswordDTO struct {
+	CurrentPassword string `json:"current_password" binding:"required,max=72"`
+	NewPassword     string `json:"new_password" binding:"required,min=6,max=72"`
+}
+
+// DeleteAccountDTO confirms DELETE /me with the account's password.
+type DeleteAccountDTO struct {
+	Password string `json:"password" binding:"required,max=72"`
+}
@@ -0,0 +1,5 @@
+package dto
+
+type RoleDTO struct {
+	Role string `json:"role"`
+}
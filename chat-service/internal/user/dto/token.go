@@ -1,5 +1,17 @@
 package dto
 
+// TokenDTO is returned by login and refresh. ExpiresIn is the access token's lifetime in
+// seconds; the refresh token outlives it and is single use. When the user has two-factor
+// authentication, login returns only MFARequired and an MFAToken, which POST /login/mfa trades
+// for the tokens together with a code.
 type TokenDTO struct {
-	TokenString string `json:"token"`
+	TokenString  string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
+}
+
+type RefreshDTO struct {
+	RefreshToken string `json:"refresh_token"`
 }
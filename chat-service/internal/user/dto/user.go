@@ -1,11 +1,25 @@
 package dto
 
+// Passwords are capped at 72 characters because bcrypt ignores anything past 72 bytes.
+
 type LoginDTO struct {
 	Username string `json:"username" binding:"required,min=3,max=30"`
-	Password string `json:"password" binding:"required"`
+	Password string `json:"password" binding:"required,max=72"`
 }
 
 type RegisterDTO struct {
-	Username string `json:"username" binding:"required,min=3,max=30"`
-	Password string `json:"password" binding:"required,min=6"`
+	Username string `json:"username" binding:"required,min=3,max=30,username"`
+	Password string `json:"password" binding:"required,min=6,max=72"`
+	// Email is optional, but without it a forgotten password cannot be reset.
+	Email string `json:"email" binding:"max=254,email"`
+}
+
+type ForgotPasswordDTO struct {
+	Email string `json:"email" binding:"required,max=254,email"`
+}
+
+// ResetPasswordDTO sets a new password with the token of a password reset email.
+type ResetPasswordDTO struct {
+	Token       string `json:"token" binding:"required,max=128"`
+	NewPassword string `json:"new_password" binding:"required,min=6,max=72"`
 }
@@ -2,9 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
+	authhttp "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/http"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/model"
 	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/validation"
 	userdto "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dto"
 	usersrv "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/service"
 )
@@ -37,6 +41,11 @@ func (a *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
 	if err := a.service.Register(ctx, input); err != nil {
 		customerrors.HandleError(w, err)
 		return
@@ -55,12 +64,337 @@ func (a *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := a.service.Login(ctx, input)
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	tokens, err := a.service.Login(ctx, input, clientIP(r))
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// clientIP is the address the request came from. Forwarding headers are ignored, since any
+// client can set them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Refresh serves POST /token/refresh, trading a refresh token for a new token pair.
+func (a *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var input userdto.RefreshDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	tokens, err := a.service.Refresh(r.Context(), input)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout serves POST /logout, revoking the session of the access token it is called with.
+func (a *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := a.service.Logout(r.Context(), sessionID(r)); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetRole serves PUT /users/{id}/role, which only admins can reach.
+func (a *Handler) SetRole(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	var input userdto.RoleDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := a.service.SetRole(r.Context(), adminID, r.PathValue("id"), input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Profile serves GET /me.
+func (a *Handler) Profile(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	profile, err := a.service.Profile(r.Context(), userID)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// UpdateProfile serves PATCH /me, changing only the profile fields present in the body.
+func (a *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	var input userdto.UpdateProfileDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	profile, err := a.service.UpdateProfile(r.Context(), userID, input)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// ChangePassword serves POST /me/password. The caller's other sessions are logged out.
+func (a *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	var input userdto.ChangePasswordDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	if err := a.service.ChangePassword(r.Context(), userID, sessionID(r), input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete serves DELETE /me, removing the caller's account once its password is confirmed.
+func (a *Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	var input userdto.DeleteAccountDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	if err := a.service.Delete(r.Context(), userID, input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sessionID is the session of the access token the request was authenticated with.
+func sessionID(r *http.Request) string {
+	if claims, ok := r.Context().Value(authhttp.ClaimsKey).(*model.CustomClaims); ok {
+		return claims.SessionID
+	}
+	return ""
+}
+
+// ForgotPassword serves POST /password/forgot. It answers 202 whether or not the email belongs
+// to an account, so it cannot be used to find out which addresses are registered.
+func (a *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var input userdto.ForgotPasswordDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	if err := a.service.ForgotPassword(r.Context(), input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword serves POST /password/reset, setting a new password with the token of a
+// password reset email.
+func (a *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var input userdto.ResetPasswordDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	if err := a.service.ResetPassword(r.Context(), input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyMFA serves POST /login/mfa, completing a login that answered mfa_required with a code
+// from the authenticator app or a recovery code.
+func (a *Handler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var input userdto.MFALoginDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	tokens, err := a.service.VerifyMFA(r.Context(), input, clientIP(r))
 	if err != nil {
 		customerrors.HandleError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(userdto.TokenDTO{TokenString: token})
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// EnrolTOTP serves POST /me/mfa/totp, returning a new secret and its otpauth:// URI for the
+// caller's authenticator app.
+func (a *Handler) EnrolTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	enrolment, err := a.service.EnrolTOTP(r.Context(), userID)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(enrolment)
+}
+
+// ConfirmTOTP serves POST /me/mfa/totp/confirm, enabling two-factor authentication with a code
+// from the app being enrolled and returning the caller's recovery codes.
+func (a *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	var input userdto.MFACodeDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	codes, err := a.service.ConfirmTOTP(r.Context(), userID, input)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codes)
+}
+
+// DisableTOTP serves DELETE /me/mfa/totp, turning two-factor authentication off once the
+// password and a second factor are confirmed.
+func (a *Handler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	var input userdto.DisableMFADTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	if err := a.service.DisableTOTP(r.Context(), userID, input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes serves POST /me/mfa/recovery-codes, replacing the caller's recovery
+// codes once a second factor is confirmed.
+func (a *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+	var input userdto.MFACodeDTO
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(ErrInvalidRequestBody)
+		return
+	}
+
+	if err := validation.Validate(input); err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	codes, err := a.service.RegenerateRecoveryCodes(r.Context(), userID, input)
+	if err != nil {
+		customerrors.HandleError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codes)
 }
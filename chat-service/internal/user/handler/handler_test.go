@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	sessionrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/repository/mocks"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	userrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/repository/mocks"
+	usersrv "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/service"
+)
+
+func TestHandler_Validation(t *testing.T) {
+	tests := []struct {
+		name        string
+		login       bool
+		body        string
+		wantStatus  int
+		wantDetails []customerrors.FieldError
+	}{
+		{
+			name:       "Given an empty username and a one-character password, When registering, Then both fields are reported",
+			body:       `{"username": "", "password": "x"}`,
+			wantStatus: http.StatusBadRequest,
+			wantDetails: []customerrors.FieldError{
+				{Field: "username", Rule: "required", Message: "username is required"},
+				{Field: "password", Rule: "min", Message: "password must be at least 6 characters"},
+			},
+		},
+		{
+			name:       "Given a username with spaces, When registering, Then the username rule is reported",
+			body:       `{"username": "alice smith", "password": "secret123"}`,
+			wantStatus: http.StatusBadRequest,
+			wantDetails: []customerrors.FieldError{
+				{Field: "username", Rule: "username", Message: "username may only contain letters, digits, '.', '_' and '-', and must start and end with a letter or digit"},
+			},
+		},
+		{
+			name:       "Given a password longer than 72 characters, When registering, Then the max rule is reported",
+			body:       `{"username": "alice", "password": "` + strings.Repeat("a", 73) + `"}`,
+			wantStatus: http.StatusBadRequest,
+			wantDetails: []customerrors.FieldError{
+				{Field: "password", Rule: "max", Message: "password must be at most 72 characters"},
+			},
+		},
+		{
+			name:       "Given a missing password, When logging in, Then the required rule is reported",
+			login:      true,
+			body:       `{"username": "alice"}`,
+			wantStatus: http.StatusBadRequest,
+			wantDetails: []customerrors.FieldError{
+				{Field: "password", Rule: "required", Message: "password is required"},
+			},
+		},
+		{
+			name:       "Given a valid body, When registering, Then the user is created",
+			body:       `{"username": "alice.smith", "password": "secret123"}`,
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(userrepomock.MockRepository)
+			repo.On("FindByUsername", mock.Anything, "alice.smith").Return(nil, nil).Maybe()
+			repo.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+			h := New(*usersrv.New(repo, new(sessionrepomock.MockRepository), nil))
+
+			path, serve := "/register", h.Register
+			if tt.login {
+				path, serve = "/login", h.Login
+			}
+			rec := httptest.NewRecorder()
+			serve(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantDetails == nil {
+				return
+			}
+
+			var got customerrors.AppError
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+			assert.Equal(t, "VALIDATION_ERROR", got.Code)
+			assert.Equal(t, tt.wantDetails, got.Details)
+			repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"database/sql"
+
 	"gorm.io/gorm"
 
 	"github.com/stretchr/testify/mock"
@@ -24,3 +26,13 @@ func (m *MockDB) First(dest any, conds ...any) *gorm.DB {
 	calledArgs := m.Called(append([]any{dest}, conds...)...)
 	return calledArgs.Get(0).(*gorm.DB)
 }
+
+func (m *MockDB) Model(value any) *gorm.DB {
+	args := m.Called(value)
+	return args.Get(0).(*gorm.DB)
+}
+
+func (m *MockDB) Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error {
+	args := m.Called(fc)
+	return args.Error(0)
+}
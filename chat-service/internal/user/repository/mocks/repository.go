@@ -24,3 +24,69 @@ func (m *MockRepository) FindByUsername(ctx context.Context, username string) (*
 	}
 	return args.Get(0).(*dao.User), args.Error(1)
 }
+
+func (m *MockRepository) FindByEmail(ctx context.Context, email string) (*dao.User, error) {
+	args := m.Called(ctx, email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dao.User), args.Error(1)
+}
+
+func (m *MockRepository) FindByID(ctx context.Context, id string) (*dao.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dao.User), args.Error(1)
+}
+
+func (m *MockRepository) UpdateRole(ctx context.Context, id, role string) (bool, error) {
+	args := m.Called(ctx, id, role)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) UpdateProfile(ctx context.Context, user dao.User) (bool, error) {
+	args := m.Called(ctx, user)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) UpdatePassword(ctx context.Context, id, password string) (bool, error) {
+	args := m.Called(ctx, id, password)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SaveTOTPSecret(ctx context.Context, id, secret string) (bool, error) {
+	args := m.Called(ctx, id, secret)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) EnableTOTP(ctx context.Context, id string, step int64, codes []dao.RecoveryCode) (bool, error) {
+	args := m.Called(ctx, id, step, codes)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) DisableTOTP(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UseTOTPStep(ctx context.Context, id string, step int64) (bool, error) {
+	args := m.Called(ctx, id, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codes []dao.RecoveryCode) error {
+	args := m.Called(ctx, userID, codes)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+	args := m.Called(ctx, userID, hash)
+	return args.Bool(0), args.Error(1)
+}
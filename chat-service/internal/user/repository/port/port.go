@@ -8,4 +8,16 @@ import (
 type RepositoryPort interface {
 	Create(ctx context.Context, user dao.User) error
 	FindByUsername(ctx context.Context, username string) (*dao.User, error)
+	FindByEmail(ctx context.Context, email string) (*dao.User, error)
+	FindByID(ctx context.Context, id string) (*dao.User, error)
+	UpdateRole(ctx context.Context, id, role string) (bool, error)
+	UpdateProfile(ctx context.Context, user dao.User) (bool, error)
+	UpdatePassword(ctx context.Context, id, password string) (bool, error)
+	Delete(ctx context.Context, id string) error
+	SaveTOTPSecret(ctx context.Context, id, secret string) (bool, error)
+	EnableTOTP(ctx context.Context, id string, step int64, codes []dao.RecoveryCode) (bool, error)
+	DisableTOTP(ctx context.Context, id string) error
+	UseTOTPStep(ctx context.Context, id string, step int64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codes []dao.RecoveryCode) error
+	UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error)
 }
@@ -2,9 +2,14 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"gorm.io/gorm"
 
+	messagedao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	notificationdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+	roomdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dao"
 )
 
@@ -12,6 +17,8 @@ type DB interface {
 	Create(entity any) *gorm.DB
 	Where(query any, args ...any) *gorm.DB
 	First(dest any, conds ...any) *gorm.DB
+	Model(value any) *gorm.DB
+	Transaction(fc func(tx *gorm.DB) error, opts ...*sql.TxOptions) error
 }
 
 type Repository struct {
@@ -38,3 +45,203 @@ func (r *Repository) FindByUsername(_ context.Context, username string) (*dao.Us
 	}
 	return &user, nil
 }
+
+// FindByEmail finds a user by email address, which is stored lowercase.
+func (r *Repository) FindByEmail(_ context.Context, email string) (*dao.User, error) {
+	var user dao.User
+
+	tx := r.db.Where("email = ?", email).First(&user)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &user, nil
+}
+
+func (r *Repository) FindByID(_ context.Context, id string) (*dao.User, error) {
+	var user dao.User
+
+	tx := r.db.Where("id = ?", id).First(&user)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	return &user, nil
+}
+
+// UpdateRole changes a user's global role, reporting false when no user has that ID.
+func (r *Repository) UpdateRole(_ context.Context, id, role string) (bool, error) {
+	tx := r.db.Model(&dao.User{}).Where("id = ?", id).Updates(map[string]any{
+		"role":       role,
+		"updated_at": time.Now(),
+	})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// UpdateProfile saves the profile fields of user, reporting false when no user has its ID.
+func (r *Repository) UpdateProfile(_ context.Context, user dao.User) (bool, error) {
+	tx := r.db.Model(&dao.User{}).Where("id = ?", user.ID).Updates(map[string]any{
+		"display_name": user.DisplayName,
+		"avatar_url":   user.AvatarURL,
+		"timezone":     user.Timezone,
+		"locale":       user.Locale,
+		"email":        user.Email,
+		"updated_at":   time.Now(),
+	})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// UpdatePassword replaces the password hash of a user, reporting false when no user has that ID.
+func (r *Repository) UpdatePassword(_ context.Context, id, password string) (bool, error) {
+	tx := r.db.Model(&dao.User{}).Where("id = ?", id).Updates(map[string]any{
+		"password":   password,
+		"updated_at": time.Now(),
+	})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// Delete removes a user and their personal data. Messages they sent stay in place, so threads
+// and transcripts keep their shape, but are retracted: their content, revision history and
+// author name are removed. Reactions, read cursors, room memberships and the notifications
+// they received or caused are deleted. Moderation events are kept as the rooms' audit trail.
+func (r *Repository) Delete(_ context.Context, id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		authored := tx.Model(&messagedao.Message{}).Select("id").Where("user_id = ?", id)
+		if err := tx.Where("message_id IN (?)", authored).Delete(&messagedao.MessageRevision{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		retracted := map[string]any{
+			"username":   dao.DeletedUsername,
+			"content":    "",
+			"deleted_at": gorm.Expr("COALESCE(deleted_at, ?)", now),
+			"updated_at": now,
+		}
+		if err := tx.Model(&messagedao.Message{}).Where("user_id = ?", id).Updates(retracted).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&messagedao.ArchivedMessage{}).Where("user_id = ?", id).Updates(retracted).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&messagedao.Reaction{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&messagedao.ReadCursor{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? OR actor_id = ?", id, id).Delete(&notificationdao.Notification{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&roomdao.Member{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&dao.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&dao.User{}).Error
+	})
+}
+
+// SaveTOTPSecret starts enrolling an authenticator app, replacing any enrolment that was not
+// confirmed. It reports false when the user has no pending enrolment to replace because two-factor
+// authentication is already enabled, or does not exist.
+func (r *Repository) SaveTOTPSecret(_ context.Context, id, secret string) (bool, error) {
+	tx := r.db.Model(&dao.User{}).Where("id = ? AND totp_enabled_at IS NULL", id).Updates(map[string]any{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+		"updated_at":     time.Now(),
+	})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// EnableTOTP confirms the pending enrolment of a user, recording step as used, and replaces their
+// recovery codes with codes. It reports false when there is no pending enrolment.
+func (r *Repository) EnableTOTP(_ context.Context, id string, step int64, codes []dao.RecoveryCode) (bool, error) {
+	enabled := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&dao.User{}).
+			Where("id = ? AND totp_enabled_at IS NULL AND totp_secret <> ''", id).
+			Updates(map[string]any{
+				"totp_enabled_at": now,
+				"totp_last_step":  step,
+				"updated_at":      now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		enabled = true
+		return replaceRecoveryCodes(tx, id, codes)
+	})
+	return enabled && err == nil, err
+}
+
+// DisableTOTP turns two-factor authentication off for a user and deletes their recovery codes.
+func (r *Repository) DisableTOTP(_ context.Context, id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&dao.User{}).Where("id = ?", id).Updates(map[string]any{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", id).Delete(&dao.RecoveryCode{}).Error
+	})
+}
+
+// UseTOTPStep records that a code of step was accepted for a user, but only if no code of that
+// step or a later one was accepted before, so a code cannot be replayed.
+func (r *Repository) UseTOTPStep(_ context.Context, id string, step int64) (bool, error) {
+	tx := r.db.Model(&dao.User{}).Where("id = ? AND totp_last_step < ?", id, step).Updates(map[string]any{
+		"totp_last_step": step,
+	})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
+
+// ReplaceRecoveryCodes deletes the recovery codes of a user and stores codes instead.
+func (r *Repository) ReplaceRecoveryCodes(_ context.Context, userID string, codes []dao.RecoveryCode) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string, codes []dao.RecoveryCode) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&dao.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+// UseRecoveryCode marks the unused recovery code of a user with the given hash as used,
+// reporting false when there is none.
+func (r *Repository) UseRecoveryCode(_ context.Context, userID, hash string) (bool, error) {
+	now := time.Now()
+	tx := r.db.Model(&dao.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Updates(map[string]any{"used_at": now, "updated_at": now})
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+	return tx.RowsAffected > 0, nil
+}
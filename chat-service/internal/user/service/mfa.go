@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/utils"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/totp"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dao"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dto"
+)
+
+const (
+	// DefaultMFAIssuer names the service in authenticator apps.
+	DefaultMFAIssuer = "Financial Chat"
+
+	// MFATokenTTL is how long a login waits for its second factor.
+	MFATokenTTL = 5 * time.Minute
+
+	// maxMFAAttempts is how many wrong codes an MFA token takes before it stops working.
+	maxMFAAttempts = 5
+
+	// recoveryCodeCount recovery codes are issued at a time, each recoveryCodeLength characters
+	// of base32, 80 bits, shown in groups of four.
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 16
+)
+
+const recoveryAlphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+// WithMFAIssuer sets the name the service has in authenticator apps.
+func WithMFAIssuer(issuer string) Option {
+	return func(s *Service) {
+		s.mfaIssuer = issuer
+	}
+}
+
+// EnrolTOTP starts enrolling an authenticator app for userID, returning the secret to add to
+// it. Two-factor authentication is only enabled once ConfirmTOTP gets a code generated from it.
+func (s *Service) EnrolTOTP(ctx context.Context, userID string) (*dto.TOTPEnrolmentDTO, error) {
+	user, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	alreadyEnabled := customerrors.Wrap(customerrors.ErrUnprocessable, errors.New("two-factor authentication is already enabled"))
+	if user.MFAEnabled() {
+		return nil, alreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred generating secret"))
+	}
+	saved, err := s.repo.SaveTOTPSecret(ctx, userID, secret)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred saving secret"))
+	}
+	if !saved {
+		return nil, alreadyEnabled
+	}
+
+	return &dto.TOTPEnrolmentDTO{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(s.mfaIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication for userID with a code from the app being
+// enrolled, returning the user's recovery codes.
+func (s *Service) ConfirmTOTP(ctx context.Context, userID string, confirm dto.MFACodeDTO) (*dto.RecoveryCodesDTO, error) {
+	user, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, customerrors.Wrap(customerrors.ErrUnprocessable, errors.New("two-factor authentication is already enabled"))
+	}
+	if user.TOTPSecret == "" {
+		return nil, customerrors.Wrap(customerrors.ErrUnprocessable, errors.New("start enrolling an authenticator app first"))
+	}
+	if err := s.checkCodeAttempts(user); err != nil {
+		return nil, err
+	}
+
+	step, ok := totp.Validate(user.TOTPSecret, strings.TrimSpace(confirm.Code), time.Now())
+	if !ok {
+		s.userAttempts.Fail(user.Username)
+		return nil, customerrors.Wrap(customerrors.ErrBadRequest, errors.New("invalid code"))
+	}
+
+	codes, records, err := generateRecoveryCodes(userID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred generating recovery codes"))
+	}
+	enabled, err := s.repo.EnableTOTP(ctx, userID, step, records)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred enabling two-factor authentication"))
+	}
+	if !enabled {
+		return nil, customerrors.Wrap(customerrors.ErrUnprocessable, errors.New("start enrolling an authenticator app first"))
+	}
+
+	log.Printf("audit: two-factor authentication enabled for account %q (%s)", user.Username, user.ID)
+	return &dto.RecoveryCodesDTO{RecoveryCodes: codes}, nil
+}
+
+// DisableTOTP turns two-factor authentication off for userID, once both the password and a
+// second factor are confirmed.
+func (s *Service) DisableTOTP(ctx context.Context, userID string, disable dto.DisableMFADTO) error {
+	user, err := s.find(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled() {
+		return customerrors.Wrap(customerrors.ErrUnprocessable, errors.New("two-factor authentication is not enabled"))
+	}
+	if err := s.checkCodeAttempts(user); err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(disable.Password, user.Password) {
+		s.userAttempts.Fail(user.Username)
+		return customerrors.Wrap(customerrors.ErrForbidden, errors.New("password is incorrect"))
+	}
+	if err := s.verifySecondFactor(ctx, user, disable.Code); err != nil {
+		return err
+	}
+
+	if err := s.repo.DisableTOTP(ctx, userID); err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred disabling two-factor authentication"))
+	}
+
+	log.Printf("audit: two-factor authentication disabled for account %q (%s)", user.Username, user.ID)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of userID, once a second factor is
+// confirmed. The old codes stop working.
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID string, confirm dto.MFACodeDTO) (*dto.RecoveryCodesDTO, error) {
+	user, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MFAEnabled() {
+		return nil, customerrors.Wrap(customerrors.ErrUnprocessable, errors.New("two-factor authentication is not enabled"))
+	}
+	if err := s.checkCodeAttempts(user); err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(ctx, user, confirm.Code); err != nil {
+		return nil, err
+	}
+
+	codes, records, err := generateRecoveryCodes(userID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred generating recovery codes"))
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, records); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred saving recovery codes"))
+	}
+
+	return &dto.RecoveryCodesDTO{RecoveryCodes: codes}, nil
+}
+
+// VerifyMFA completes a login that needs a second factor: it trades the MFA token Login
+// returned and a code from the authenticator app, or a recovery code, for the session's tokens.
+// Wrong codes count as failed logins, and an MFA token stops working after maxMFAAttempts of them.
+func (s *Service) VerifyMFA(ctx context.Context, verify dto.MFALoginDTO, clientIP string) (*dto.TokenDTO, error) {
+	invalid := customerrors.Wrap(customerrors.ErrUnauthorized, errors.New("invalid or expired MFA token"))
+
+	userID, ok := s.challenges.lookup(verify.MFAToken)
+	if !ok {
+		return nil, invalid
+	}
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil || user == nil || !user.MFAEnabled() {
+		return nil, invalid
+	}
+	if err := s.checkAttempts(user.Username, clientIP); err != nil {
+		return nil, err
+	}
+
+	matched, err := s.matchSecondFactor(ctx, user, verify.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !matched {
+		s.challenges.fail(verify.MFAToken)
+		s.failAttempt(user.Username, clientIP)
+		return nil, customerrors.Wrap(customerrors.ErrUnauthorized, errors.New("invalid code"))
+	}
+
+	if !s.challenges.consume(verify.MFAToken) {
+		// Another request completed the login with the same token first.
+		return nil, invalid
+	}
+	s.userAttempts.Reset(user.Username)
+
+	return s.startSession(ctx, user)
+}
+
+// checkCodeAttempts refuses to check codes for user while they are throttled after failed
+// logins, so account settings cannot be used to guess codes either.
+func (s *Service) checkCodeAttempts(user *dao.User) error {
+	if wait, _ := s.userAttempts.Check(user.Username); wait > 0 {
+		return customerrors.Wrap(customerrors.ErrTooMany, fmt.Errorf("too many failed attempts, try again in %s", roundUp(wait)))
+	}
+	return nil
+}
+
+// verifySecondFactor checks code for a signed-in user, counting a wrong code as a failed login.
+func (s *Service) verifySecondFactor(ctx context.Context, user *dao.User, code string) error {
+	matched, err := s.matchSecondFactor(ctx, user, code)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		s.userAttempts.Fail(user.Username)
+		return customerrors.Wrap(customerrors.ErrForbidden, errors.New("invalid code"))
+	}
+	return nil
+}
+
+// matchSecondFactor reports whether code is a current code of the user's authenticator app or
+// one of their unused recovery codes, and uses it up: an app code cannot be replayed, nor can a
+// recovery code be used twice.
+func (s *Service) matchSecondFactor(ctx context.Context, user *dao.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		used, err := s.repo.UseTOTPStep(ctx, user.ID, step)
+		if err != nil {
+			return false, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred checking code"))
+		}
+		return used, nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, user.ID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return false, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred checking code"))
+	}
+	if used {
+		log.Printf("audit: recovery code used for account %q (%s)", user.Username, user.ID)
+	}
+	return used, nil
+}
+
+// generateRecoveryCodes returns new recovery codes for userID, as shown to the user and as
+// stored.
+func generateRecoveryCodes(userID string) ([]string, []dao.RecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]dao.RecoveryCode, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		raw := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		var code strings.Builder
+		for i, b := range raw {
+			if i > 0 && i%4 == 0 {
+				code.WriteByte('-')
+			}
+			// 256 is a multiple of the alphabet's 32 characters, so every character is equally likely.
+			code.WriteByte(recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+		}
+
+		codes = append(codes, code.String())
+		records = append(records, dao.RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(normalizeRecoveryCode(code.String())),
+		}.Build())
+	}
+	return codes, records, nil
+}
+
+// normalizeRecoveryCode drops the separators and case a user may type a recovery code with.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// mfaChallenges holds the logins waiting for a second factor, by the hash of their MFA token.
+// Like WebSocket tickets they only live in memory: a restart makes users enter their password again.
+type mfaChallenges struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*mfaChallenge
+}
+
+type mfaChallenge struct {
+	userID    string
+	expiresAt time.Time
+	attempts  int
+}
+
+func newMFAChallenges(ttl time.Duration) *mfaChallenges {
+	return &mfaChallenges{
+		ttl:     ttl,
+		entries: make(map[string]*mfaChallenge),
+	}
+}
+
+// issue creates an MFA token for userID.
+func (c *mfaChallenges) issue(userID string) (string, error) {
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	// Abandoned logins are dropped here, so the map only holds the last TTL's worth.
+	for key, challenge := range c.entries {
+		if !now.Before(challenge.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[hash] = &mfaChallenge{userID: userID, expiresAt: now.Add(c.ttl)}
+
+	return token, nil
+}
+
+// lookup returns the user an unexpired MFA token was issued for.
+func (c *mfaChallenges) lookup(token string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	challenge, ok := c.entries[utils.HashToken(token)]
+	if !ok || !time.Now().Before(challenge.expiresAt) {
+		return "", false
+	}
+	return challenge.userID, true
+}
+
+// fail records a wrong code for token, dropping it after maxMFAAttempts.
+func (c *mfaChallenges) fail(token string) {
+	hash := utils.HashToken(token)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if challenge, ok := c.entries[hash]; ok {
+		challenge.attempts++
+		if challenge.attempts >= maxMFAAttempts {
+			delete(c.entries, hash)
+		}
+	}
+}
+
+// consume removes token, reporting whether it was still there.
+func (c *mfaChallenges) consume(token string) bool {
+	hash := utils.HashToken(token)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.entries[hash]
+	delete(c.entries, hash)
+	return ok
+}
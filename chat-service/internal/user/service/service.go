@@ -3,25 +3,132 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	jwtport "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/port"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/utils"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/lockout"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/mail"
+	resetdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/passwordreset/dao"
+	resetrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/passwordreset/repository/port"
+	sessiondao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/dao"
+	sessionrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/repository/port"
 	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dao"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dto"
 	userrepo "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/repository/port"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// DefaultRefreshTokenTTL is how long a session lasts without being refreshed.
+const DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+// DefaultResetTokenTTL is how long a password reset link works.
+const DefaultResetTokenTTL = time.Hour
+
+// resetMailTimeout bounds looking up and mailing the account of a password reset request, and
+// sending an email change notice, both of which happen after the request was answered.
+const resetMailTimeout = 30 * time.Second
+
+// resetMailPolicy throttles password reset emails per address: one a minute and three an hour,
+// so the endpoint cannot be used to flood someone's inbox.
+var resetMailPolicy = lockout.Policy{
+	MaxFailures: 3,
+	Window:      time.Hour,
+	BaseDelay:   time.Minute,
+	MaxDelay:    time.Minute,
+	Lockout:     time.Hour,
+}
+
 type Service struct {
 	repo       userrepo.RepositoryPort
+	sessions   sessionrepo.RepositoryPort
 	jwtService jwtport.TokenService
+	refreshTTL time.Duration
+	onRevoke   func(sessionID string)
+
+	// userAttempts and ipAttempts count failed logins per username and per client address.
+	userAttempts *lockout.Tracker
+	ipAttempts   *lockout.Tracker
+
+	// resets, mailer and resetURL send password reset links; resetMails throttles them per address
+	// and mailing tracks the emails still being sent.
+	resets     resetrepo.RepositoryPort
+	mailer     mail.Mailer
+	resetURL   string
+	resetTTL   time.Duration
+	resetMails *lockout.Tracker
+	mailing    *sync.WaitGroup
+
+	// challenges are the logins waiting for a second factor; mfaIssuer names the service in
+	// authenticator apps.
+	challenges *mfaChallenges
+	mfaIssuer  string
+}
+
+type Option func(*Service)
+
+// WithRefreshTokenTTL sets how long a session lasts without being refreshed. Each refresh
+// extends it by ttl again.
+func WithRefreshTokenTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.refreshTTL = ttl
+	}
 }
 
-func New(repo userrepo.RepositoryPort, jwtService jwtport.TokenService) *Service {
-	return &Service{
+// WithLoginLockout sets how failed logins are throttled per username and per client address.
+func WithLoginLockout(user, ip lockout.Policy) Option {
+	return func(s *Service) {
+		s.userAttempts = lockout.NewTracker(user)
+		s.ipAttempts = lockout.NewTracker(ip)
+	}
+}
+
+// WithSessionRevoked registers fn to be called with the ID of every session the service
+// revokes, so connections opened with it can be closed.
+func WithSessionRevoked(fn func(sessionID string)) Option {
+	return func(s *Service) {
+		s.onRevoke = fn
+	}
+}
+
+// WithPasswordReset enables password resets: links are mailed through mailer and point to
+// resetURL, with the token in its reset_token query parameter, and work for ttl.
+func WithPasswordReset(resets resetrepo.RepositoryPort, mailer mail.Mailer, resetURL string, ttl time.Duration) Option {
+	return func(s *Service) {
+		s.resets = resets
+		s.mailer = mailer
+		s.resetURL = resetURL
+		s.resetTTL = ttl
+	}
+}
+
+func New(repo userrepo.RepositoryPort, sessions sessionrepo.RepositoryPort, jwtService jwtport.TokenService, opts ...Option) *Service {
+	s := &Service{
 		repo:       repo,
+		sessions:   sessions,
 		jwtService: jwtService,
+		refreshTTL: DefaultRefreshTokenTTL,
+		onRevoke:   func(string) {},
+
+		userAttempts: lockout.NewTracker(lockout.DefaultUserPolicy),
+		ipAttempts:   lockout.NewTracker(lockout.DefaultIPPolicy),
+		resetTTL:     DefaultResetTokenTTL,
+		resetMails:   lockout.NewTracker(resetMailPolicy),
+		mailing:      new(sync.WaitGroup),
+		challenges:   newMFAChallenges(MFATokenTTL),
+		mfaIssuer:    DefaultMFAIssuer,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Service) Register(ctx context.Context, userDTO dto.RegisterDTO) error {
@@ -32,8 +139,11 @@ func (s *Service) Register(ctx context.Context, userDTO dto.RegisterDTO) error {
 	if err == nil && exists != nil {
 		return customerrors.Wrap(customerrors.ErrUnprocessable, errors.New("user already exists"))
 	}
+	if err := s.checkEmailFree(ctx, user.Email, ""); err != nil {
+		return err
+	}
 
-	hashedPassword, hashErr := utils.HashPassword(user.Password)
+	hashedPassword, hashErr := hashPassword(user.Password)
 	if hashErr != nil {
 		return hashErr
 	}
@@ -46,18 +156,551 @@ func (s *Service) Register(ctx context.Context, userDTO dto.RegisterDTO) error {
 	return nil
 }
 
-func (s *Service) Login(ctx context.Context, loginDTO dto.LoginDTO) (string, error) {
+// PromoteAdmins makes admins of the existing users among usernames and returns the names that
+// have no account. It runs once at startup to appoint a deployment's first admins, so a name
+// registered later is not promoted; demoting a listed admin lasts until the next restart unless
+// the name is also dropped from the list.
+func (s *Service) PromoteAdmins(ctx context.Context, usernames []string) ([]string, error) {
+	var missing []string
+	for _, username := range usernames {
+		if username = strings.TrimSpace(username); username == "" {
+			continue
+		}
+
+		user, err := s.repo.FindByUsername(ctx, username)
+		if err != nil || user == nil {
+			missing = append(missing, username)
+			continue
+		}
+		if user.Role == roles.Admin {
+			continue
+		}
+		if _, err := s.repo.UpdateRole(ctx, user.ID, roles.Admin); err != nil {
+			return missing, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating user role"))
+		}
+	}
+
+	return missing, nil
+}
+
+// Login starts a session for the user, returning a short-lived access token and the refresh
+// token that renews it. Failed logins are throttled per username and per clientIP, and throttled
+// attempts are refused before the password is hashed, so they cost no CPU. Users with two-factor
+// authentication get an MFA token instead, which VerifyMFA trades for the session's tokens.
+func (s *Service) Login(ctx context.Context, loginDTO dto.LoginDTO, clientIP string) (*dto.TokenDTO, error) {
 	var user dao.User
 	user = user.FromLoginDTO(loginDTO)
 
+	if err := s.checkAttempts(user.Username, clientIP); err != nil {
+		return nil, err
+	}
+
 	saved, err := s.repo.FindByUsername(ctx, user.Username)
 	if err != nil || saved == nil {
-		return "", customerrors.Wrap(customerrors.ErrUnauthorized, errors.New("invalid credentials"))
+		s.failAttempt(user.Username, clientIP)
+		return nil, customerrors.Wrap(customerrors.ErrUnauthorized, errors.New("invalid credentials"))
 	}
 
 	if !utils.CheckPasswordHash(loginDTO.Password, saved.Password) {
-		return "", customerrors.Wrap(customerrors.ErrUnauthorized, errors.New("invalid credentials"))
+		s.failAttempt(user.Username, clientIP)
+		return nil, customerrors.Wrap(customerrors.ErrUnauthorized, errors.New("invalid credentials"))
+	}
+
+	if saved.MFAEnabled() {
+		// Failed logins are only forgotten once the second factor matches too, or guessing
+		// codes would be unthrottled for whoever knows the password.
+		mfaToken, err := s.challenges.issue(saved.ID)
+		if err != nil {
+			return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred creating session"))
+		}
+		return &dto.TokenDTO{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+	s.userAttempts.Reset(user.Username)
+
+	return s.startSession(ctx, saved)
+}
+
+// startSession creates a session for a user who has proven who they are and returns its tokens.
+func (s *Service) startSession(ctx context.Context, saved *dao.User) (*dto.TokenDTO, error) {
+	refreshToken, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred creating session"))
+	}
+
+	session := sessiondao.Session{
+		UserID:    saved.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}.Build()
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred creating session"))
+	}
+
+	return s.issueTokens(saved, session.ID, refreshToken)
+}
+
+// checkAttempts refuses a login for username from clientIP while either is locked out or has
+// to wait after a failed login.
+func (s *Service) checkAttempts(username, clientIP string) error {
+	if wait, _ := s.ipAttempts.Check(clientIP); wait > 0 {
+		return customerrors.Wrap(customerrors.ErrTooMany, fmt.Errorf("too many failed logins from this address, try again in %s", roundUp(wait)))
+	}
+
+	wait, locked := s.userAttempts.Check(username)
+	if locked {
+		return customerrors.Wrap(customerrors.ErrLocked, fmt.Errorf("account is locked after too many failed logins, try again in %s", roundUp(wait)))
+	}
+	if wait > 0 {
+		return customerrors.Wrap(customerrors.ErrTooMany, fmt.Errorf("too many failed logins, try again in %s", roundUp(wait)))
+	}
+	return nil
+}
+
+// failAttempt records a failed login, writing an audit log line for every lockout it causes.
+// Unknown usernames are tracked too, so lockouts do not reveal which accounts exist.
+func (s *Service) failAttempt(username, clientIP string) {
+	if s.userAttempts.Fail(username) {
+		log.Printf("audit: account %q locked after repeated failed logins, last from %s", username, clientIP)
+	}
+	if s.ipAttempts.Fail(clientIP) {
+		log.Printf("audit: logins from %s blocked after repeated failures, last for account %q", clientIP, username)
+	}
+}
+
+// roundUp rounds wait up to the second, so a client never retries a moment too early.
+func roundUp(wait time.Duration) time.Duration {
+	return (wait + time.Second - 1).Truncate(time.Second)
+}
+
+// Refresh rotates refreshToken: it returns a new access token and a new refresh token, and the
+// one presented stops working. Presenting a token that was already rotated means it was copied,
+// so the whole session is revoked.
+func (s *Service) Refresh(ctx context.Context, refreshDTO dto.RefreshDTO) (*dto.TokenDTO, error) {
+	invalid := customerrors.Wrap(customerrors.ErrUnauthorized, errors.New("invalid refresh token"))
+	if refreshDTO.RefreshToken == "" {
+		return nil, invalid
+	}
+
+	hash := utils.HashToken(refreshDTO.RefreshToken)
+	session, err := s.sessions.FindByTokenHash(ctx, hash)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading session"))
+	}
+	if session == nil || !session.IsActive(time.Now()) {
+		return nil, invalid
+	}
+	if session.TokenHash != hash {
+		if err := s.revoke(ctx, session.ID); err != nil {
+			return nil, err
+		}
+		return nil, customerrors.Wrap(customerrors.ErrUnauthorized, errors.New("refresh token was already used, the session has been revoked"))
+	}
+
+	user, err := s.repo.FindByID(ctx, session.UserID)
+	if err != nil || user == nil {
+		return nil, invalid
+	}
+
+	refreshToken, newHash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred refreshing session"))
+	}
+
+	rotated, err := s.sessions.Rotate(ctx, session.ID, hash, newHash, time.Now().Add(s.refreshTTL))
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred refreshing session"))
+	}
+	if !rotated {
+		// Another request rotated the same token first.
+		return nil, invalid
+	}
+
+	return s.issueTokens(user, session.ID, refreshToken)
+}
+
+// Logout revokes sessionID: its refresh token stops working and its access tokens are rejected.
+func (s *Service) Logout(ctx context.Context, sessionID string) error {
+	if sessionID == "" {
+		return customerrors.Wrap(customerrors.ErrUnauthorized, errors.New("token has no session"))
+	}
+
+	return s.revoke(ctx, sessionID)
+}
+
+func (s *Service) revoke(ctx context.Context, sessionID string) error {
+	if err := s.sessions.Revoke(ctx, sessionID); err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred revoking session"))
+	}
+
+	s.onRevoke(sessionID)
+	return nil
+}
+
+// issueTokens signs an access token for user in sessionID and pairs it with refreshToken.
+// Roles are read from user, so role changes apply from the next refresh.
+func (s *Service) issueTokens(user *dao.User, sessionID, refreshToken string) (*dto.TokenDTO, error) {
+	role := user.Role
+	if role == "" {
+		role = roles.Member
+	}
+
+	accessToken, err := s.jwtService.GenerateToken(user.ID, user.Username, role, sessionID)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred generating token"))
+	}
+
+	return &dto.TokenDTO{
+		TokenString:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.jwtService.Expiry().Seconds()),
+	}, nil
+}
+
+// SetRole changes the global role of userID. Admins cannot change their own role, so a
+// deployment cannot lose its last admin by accident. The new role takes effect at the user's
+// next token refresh.
+func (s *Service) SetRole(ctx context.Context, adminID, userID string, roleDTO dto.RoleDTO) error {
+	if !roles.IsValid(roleDTO.Role) {
+		return customerrors.Wrap(customerrors.ErrBadRequest, errors.New("role must be admin, moderator or member"))
+	}
+	if adminID == userID {
+		return customerrors.Wrap(customerrors.ErrForbidden, errors.New("you cannot change your own role"))
+	}
+
+	updated, err := s.repo.UpdateRole(ctx, userID, roleDTO.Role)
+	if err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating user role"))
+	}
+	if !updated {
+		return customerrors.Wrap(customerrors.ErrNotFound, errors.New("user not found"))
+	}
+
+	return nil
+}
+
+// hashPassword hashes a password that is about to be stored.
+func hashPassword(password string) (string, error) {
+	hashed, err := utils.HashPassword(password)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		// 72 characters can exceed bcrypt's 72 bytes when some are not ASCII.
+		return "", customerrors.Wrap(customerrors.ErrBadRequest, errors.New("password must be at most 72 bytes"))
+	}
+	if err != nil {
+		return "", customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred hashing password"))
+	}
+	return hashed, nil
+}
+
+// Profile returns the account of userID.
+func (s *Service) Profile(ctx context.Context, userID string) (*dto.ProfileDTO, error) {
+	user, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := user.ToProfileDTO()
+	return &profile, nil
+}
+
+// UpdateProfile changes the profile fields present in update and returns the updated account.
+func (s *Service) UpdateProfile(ctx context.Context, userID string, update dto.UpdateProfileDTO) (*dto.ProfileDTO, error) {
+	user, err := s.find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := user.ApplyProfile(update)
+	emailChanged := !sameEmail(user.Email, updated.Email)
+	if emailChanged && !utils.CheckPasswordHash(update.CurrentPassword, user.Password) {
+		return nil, customerrors.Wrap(customerrors.ErrForbidden, errors.New("current password is incorrect"))
+	}
+	if err := s.checkEmailFree(ctx, updated.Email, userID); err != nil {
+		return nil, err
+	}
+	if emailChanged && s.resets != nil {
+		// Reset links went to the old address, which may no longer be the user's.
+		if err := s.resets.InvalidateAll(ctx, userID); err != nil {
+			return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred invalidating password resets"))
+		}
+	}
+	found, err := s.repo.UpdateProfile(ctx, updated)
+	if err != nil {
+		return nil, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating profile"))
+	}
+	if !found {
+		return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("user not found"))
+	}
+
+	if emailChanged {
+		log.Printf("audit: email of account %q (%s) changed", user.Username, user.ID)
+		if user.Email != nil {
+			s.mailEmailChanged(ctx, *user.Email, user.Username)
+		}
+	}
+
+	profile := updated.ToProfileDTO()
+	return &profile, nil
+}
+
+// sameEmail reports whether two normalized emails, nil when there is none, are the same.
+func sameEmail(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// mailEmailChanged tells the address an account used to have that its email was changed, after
+// returning, so the owner hears of a takeover even once the new address is not theirs.
+func (s *Service) mailEmailChanged(ctx context.Context, oldEmail, username string) {
+	if s.mailer == nil {
+		return
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	s.mailing.Go(func() {
+		ctx, cancel := context.WithTimeout(ctx, resetMailTimeout)
+		defer cancel()
+
+		if err := s.mailer.Send(ctx, mail.Message{
+			To:      oldEmail,
+			Subject: "Your Financial Chat email was changed",
+			Body: fmt.Sprintf("Hi %s,\n\nThe email address of your account was changed and this address no longer receives its emails.\n\n"+
+				"If you did not make this change, change your password and contact an administrator.\n", username),
+		}); err != nil {
+			log.Printf("failed to send email change notice: %v", err)
+		}
+	})
+}
+
+// ChangePassword replaces the password of userID once the current one is confirmed. Every
+// other session of the user is revoked, so whoever may know the old password is logged out;
+// sessionID, the session making the change, stays signed in.
+func (s *Service) ChangePassword(ctx context.Context, userID, sessionID string, change dto.ChangePasswordDTO) error {
+	user, err := s.find(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(change.CurrentPassword, user.Password) {
+		return customerrors.Wrap(customerrors.ErrForbidden, errors.New("current password is incorrect"))
+	}
+
+	hashedPassword, err := hashPassword(change.NewPassword)
+	if err != nil {
+		return err
+	}
+	found, err := s.repo.UpdatePassword(ctx, userID, hashedPassword)
+	if err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating password"))
+	}
+	if !found {
+		return customerrors.Wrap(customerrors.ErrNotFound, errors.New("user not found"))
+	}
+
+	return s.revokeAll(ctx, userID, sessionID)
+}
+
+// Delete removes the account of userID once its password is confirmed. Every session is
+// revoked first, closing the user's connections, then the account and its personal data are
+// removed; see the repository's Delete for what is kept.
+func (s *Service) Delete(ctx context.Context, userID string, confirm dto.DeleteAccountDTO) error {
+	user, err := s.find(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(confirm.Password, user.Password) {
+		return customerrors.Wrap(customerrors.ErrForbidden, errors.New("password is incorrect"))
+	}
+
+	if err := s.revokeAll(ctx, userID, ""); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, userID); err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred deleting account"))
+	}
+
+	log.Printf("audit: account %q (%s) deleted by its owner", user.Username, user.ID)
+	return nil
+}
+
+// revokeAll revokes every session of userID except exceptID.
+func (s *Service) revokeAll(ctx context.Context, userID, exceptID string) error {
+	revoked, err := s.sessions.RevokeAll(ctx, userID, exceptID)
+	if err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred revoking sessions"))
+	}
+
+	for _, sessionID := range revoked {
+		s.onRevoke(sessionID)
+	}
+	return nil
+}
+
+func (s *Service) find(ctx context.Context, userID string) (*dao.User, error) {
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil || user == nil {
+		return nil, customerrors.Wrap(customerrors.ErrNotFound, errors.New("user not found"))
+	}
+	return user, nil
+}
+
+// checkEmailFree refuses email when another account than userID already uses it.
+func (s *Service) checkEmailFree(ctx context.Context, email *string, userID string) error {
+	if email == nil {
+		return nil
+	}
+
+	owner, err := s.repo.FindByEmail(ctx, *email)
+	if err == nil && owner != nil && owner.ID != userID {
+		return customerrors.Wrap(customerrors.ErrUnprocessable, errors.New("email is already in use"))
+	}
+	return nil
+}
+
+// ForgotPassword mails a password reset link to the account with the given email. It succeeds
+// whether or not such an account exists, so it cannot be used to find out which addresses are
+// registered: the account is looked up and mailed after returning, so both cases answer equally
+// fast, and failures are only logged.
+func (s *Service) ForgotPassword(ctx context.Context, forgot dto.ForgotPasswordDTO) error {
+	if s.resets == nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("password reset is not configured"))
+	}
+
+	email := dao.NormalizeEmail(forgot.Email)
+	if email == nil {
+		return nil
+	}
+	if wait, _ := s.resetMails.Check(*email); wait > 0 {
+		return nil
+	}
+	s.resetMails.Fail(*email)
+
+	ctx = context.WithoutCancel(ctx)
+	s.mailing.Go(func() {
+		ctx, cancel := context.WithTimeout(ctx, resetMailTimeout)
+		defer cancel()
+
+		if err := s.mailResetLink(ctx, *email); err != nil {
+			log.Printf("failed to send password reset email: %v", err)
+		}
+	})
+
+	return nil
+}
+
+// mailResetLink creates a password reset for the account with email, if there is one, and mails
+// it the link.
+func (s *Service) mailResetLink(ctx context.Context, email string) error {
+	user, err := s.repo.FindByEmail(ctx, email)
+	if err != nil || user == nil {
+		return nil
+	}
+
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("generating token for user %s: %w", user.ID, err)
+	}
+
+	reset := resetdao.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.resetTTL),
+	}.Build()
+	if err := s.resets.Create(ctx, reset); err != nil {
+		return fmt.Errorf("saving reset for user %s: %w", user.ID, err)
+	}
+
+	link, err := resetLink(s.resetURL, token)
+	if err != nil {
+		return fmt.Errorf("password reset URL is invalid: %w", err)
+	}
+
+	if err := s.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Reset your Financial Chat password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your account. Open this link to choose a new one:\n\n%s\n\n"+
+			"The link works once and expires in %s. If you did not ask for it, you can ignore this email.\n",
+			user.Username, link, s.resetTTL),
+	}); err != nil {
+		return fmt.Errorf("mailing user %s: %w", user.ID, err)
+	}
+
+	return nil
+}
+
+// Drain waits for the emails still being sent, or for ctx to end.
+func (s *Service) Drain(ctx context.Context) error {
+	finished := make(chan struct{})
+	go func() {
+		s.mailing.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resetLink adds token to base as its reset_token query parameter.
+func resetLink(base, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	query := link.Query()
+	query.Set("reset_token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// ResetPassword sets a new password with the token of a password reset email. The token stops
+// working, every session of the user is revoked and any login lockout on the account is lifted.
+func (s *Service) ResetPassword(ctx context.Context, reset dto.ResetPasswordDTO) error {
+	invalid := customerrors.Wrap(customerrors.ErrBadRequest, errors.New("invalid or expired password reset token"))
+	if s.resets == nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("password reset is not configured"))
+	}
+
+	saved, err := s.resets.FindByTokenHash(ctx, utils.HashToken(reset.Token))
+	if err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading password reset"))
+	}
+	if saved == nil || !saved.IsUsable(time.Now()) {
+		return invalid
+	}
+
+	user, err := s.repo.FindByID(ctx, saved.UserID)
+	if err != nil || user == nil {
+		return invalid
+	}
+
+	hashedPassword, err := hashPassword(reset.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	used, err := s.resets.Use(ctx, saved.ID)
+	if err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred loading password reset"))
+	}
+	if !used {
+		// Another request used the same token first.
+		return invalid
+	}
+
+	found, err := s.repo.UpdatePassword(ctx, user.ID, hashedPassword)
+	if err != nil {
+		return customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred updating password"))
+	}
+	if !found {
+		return invalid
 	}
 
-	return s.jwtService.GenerateToken(saved.ID, saved.Username)
+	s.userAttempts.Reset(user.Username)
+	log.Printf("audit: password of account %q (%s) reset by email", user.Username, user.ID)
+	return s.revokeAll(ctx, user.ID, "")
 }
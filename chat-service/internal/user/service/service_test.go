@@ -2,6 +2,9 @@ package service
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +13,17 @@ import (
 
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/utils"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/lockout"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/totp"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/mail"
+	mailmocks "github.com/Lucas-Onofre/financial-chat/chat-service/internal/mail/mocks"
+	resetdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/passwordreset/dao"
+	resetmocks "github.com/Lucas-Onofre/financial-chat/chat-service/internal/passwordreset/repository/mocks"
+	sessiondao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/dao"
+	sessionmocks "github.com/Lucas-Onofre/financial-chat/chat-service/internal/session/repository/mocks"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dao"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dto"
 	userrepomock "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/repository/mocks"
@@ -83,10 +97,13 @@ func TestService_Login(t *testing.T) {
 			mockRepo := new(userrepomock.MockRepository)
 			tt.setup(mockRepo)
 
-			jwtService := jwt.NewJWTService("secret", time.Minute*5)
-			service := New(mockRepo, jwtService)
+			sessions := new(sessionmocks.MockRepository)
+			sessions.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
 
-			_, err := service.Login(tt.args.ctx, tt.args.loginDTO)
+			jwtService := jwt.NewJWTService(testKeys(t), time.Minute*5)
+			service := New(mockRepo, sessions, jwtService)
+
+			_, err := service.Login(tt.args.ctx, tt.args.loginDTO, "127.0.0.1")
 			assert.Equal(t, tt.wantErr, err != nil)
 		})
 	}
@@ -146,7 +163,7 @@ func TestService_Register(t *testing.T) {
 			mockRepo := new(userrepomock.MockRepository)
 			tt.setup(mockRepo)
 
-			service := New(mockRepo, nil)
+			service := New(mockRepo, nil, nil)
 
 			err := service.Register(tt.args.ctx, tt.args.registerDTO)
 			assert.Equal(t, tt.wantErr, err != nil)
@@ -154,3 +171,804 @@ func TestService_Register(t *testing.T) {
 		})
 	}
 }
+
+func TestService_SetRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminID    string
+		userID     string
+		role       string
+		setup      func(repo *userrepomock.MockRepository)
+		wantStatus int
+	}{
+		{
+			name:    "Given an existing user, When SetRole is called, Then the role is updated",
+			adminID: "admin1",
+			userID:  "user2",
+			role:    roles.Moderator,
+			setup: func(repo *userrepomock.MockRepository) {
+				repo.On("UpdateRole", mock.Anything, "user2", roles.Moderator).Return(true, nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:    "Given an unknown user, When SetRole is called, Then a not found error is returned",
+			adminID: "admin1",
+			userID:  "user404",
+			role:    roles.Moderator,
+			setup: func(repo *userrepomock.MockRepository) {
+				repo.On("UpdateRole", mock.Anything, "user404", roles.Moderator).Return(false, nil)
+			},
+			wantStatus: customerrors.ErrNotFound.Status,
+		},
+		{
+			name:       "Given an admin, When they change their own role, Then a forbidden error is returned",
+			adminID:    "admin1",
+			userID:     "admin1",
+			role:       roles.Member,
+			setup:      func(repo *userrepomock.MockRepository) {},
+			wantStatus: customerrors.ErrForbidden.Status,
+		},
+		{
+			name:       "Given an unknown role, When SetRole is called, Then a bad request error is returned",
+			adminID:    "admin1",
+			userID:     "user2",
+			role:       "owner",
+			setup:      func(repo *userrepomock.MockRepository) {},
+			wantStatus: customerrors.ErrBadRequest.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(userrepomock.MockRepository)
+			tt.setup(mockRepo)
+
+			err := New(mockRepo, nil, nil).SetRole(context.Background(), tt.adminID, tt.userID, dto.RoleDTO{Role: tt.role})
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_PromoteAdmins(t *testing.T) {
+	mockRepo := new(userrepomock.MockRepository)
+	mockRepo.On("FindByUsername", mock.Anything, "root").Return(&dao.User{Entity: entity.Entity{ID: "user1"}, Username: "root", Role: roles.Member}, nil)
+	mockRepo.On("FindByUsername", mock.Anything, "boss").Return(&dao.User{Entity: entity.Entity{ID: "user2"}, Username: "boss", Role: roles.Admin}, nil)
+	mockRepo.On("FindByUsername", mock.Anything, "ghost").Return(nil, assert.AnError)
+	mockRepo.On("UpdateRole", mock.Anything, "user1", roles.Admin).Return(true, nil).Once()
+
+	missing, err := New(mockRepo, nil, nil).PromoteAdmins(context.Background(), []string{" root ", "", "boss", "ghost"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ghost"}, missing)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_Register_AdminUsernameNotPromoted(t *testing.T) {
+	mockRepo := new(userrepomock.MockRepository)
+	mockRepo.On("FindByUsername", mock.Anything, "root").Return(nil, assert.AnError)
+	mockRepo.On("FindByEmail", mock.Anything, mock.Anything).Return(nil, nil)
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(u dao.User) bool {
+		return u.Username == "root" && u.Role != roles.Admin
+	})).Return(nil)
+
+	err := New(mockRepo, nil, nil).Register(context.Background(), dto.RegisterDTO{Username: "root", Email: "root@example.com", Password: "password123"})
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_Refresh(t *testing.T) {
+	const (
+		current  = "current-token"
+		previous = "previous-token"
+	)
+	active := func() *sessiondao.Session {
+		return &sessiondao.Session{
+			Entity:            entity.Entity{ID: "session1"},
+			UserID:            "user1",
+			TokenHash:         utils.HashToken(current),
+			PreviousTokenHash: utils.HashToken(previous),
+			ExpiresAt:         time.Now().Add(time.Hour),
+		}
+	}
+
+	tests := []struct {
+		name        string
+		token       string
+		setup       func(users *userrepomock.MockRepository, sessions *sessionmocks.MockRepository)
+		wantStatus  int
+		wantRevoked bool
+	}{
+		{
+			name:  "Given the current refresh token, When Refresh is called, Then it is rotated and a new pair is returned",
+			token: current,
+			setup: func(users *userrepomock.MockRepository, sessions *sessionmocks.MockRepository) {
+				sessions.On("FindByTokenHash", mock.Anything, utils.HashToken(current)).Return(active(), nil)
+				users.On("FindByID", mock.Anything, "user1").Return(&dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice", Role: roles.Moderator}, nil)
+				sessions.On("Rotate", mock.Anything, "session1", utils.HashToken(current), mock.Anything, mock.Anything).Return(true, nil)
+			},
+			wantStatus: 0,
+		},
+		{
+			name:  "Given a refresh token that was already rotated, When Refresh is called, Then the session is revoked",
+			token: previous,
+			setup: func(users *userrepomock.MockRepository, sessions *sessionmocks.MockRepository) {
+				sessions.On("FindByTokenHash", mock.Anything, utils.HashToken(previous)).Return(active(), nil)
+				sessions.On("Revoke", mock.Anything, "session1").Return(nil)
+			},
+			wantStatus:  customerrors.ErrUnauthorized.Status,
+			wantRevoked: true,
+		},
+		{
+			name:  "Given the refresh token of a revoked session, When Refresh is called, Then an unauthorized error is returned",
+			token: current,
+			setup: func(users *userrepomock.MockRepository, sessions *sessionmocks.MockRepository) {
+				session := active()
+				revokedAt := time.Now()
+				session.RevokedAt = &revokedAt
+				sessions.On("FindByTokenHash", mock.Anything, utils.HashToken(current)).Return(session, nil)
+			},
+			wantStatus: customerrors.ErrUnauthorized.Status,
+		},
+		{
+			name:  "Given an expired session, When Refresh is called, Then an unauthorized error is returned",
+			token: current,
+			setup: func(users *userrepomock.MockRepository, sessions *sessionmocks.MockRepository) {
+				session := active()
+				session.ExpiresAt = time.Now().Add(-time.Minute)
+				sessions.On("FindByTokenHash", mock.Anything, utils.HashToken(current)).Return(session, nil)
+			},
+			wantStatus: customerrors.ErrUnauthorized.Status,
+		},
+		{
+			name:  "Given a concurrent refresh won the rotation, When Refresh is called, Then an unauthorized error is returned",
+			token: current,
+			setup: func(users *userrepomock.MockRepository, sessions *sessionmocks.MockRepository) {
+				sessions.On("FindByTokenHash", mock.Anything, utils.HashToken(current)).Return(active(), nil)
+				users.On("FindByID", mock.Anything, "user1").Return(&dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice"}, nil)
+				sessions.On("Rotate", mock.Anything, "session1", utils.HashToken(current), mock.Anything, mock.Anything).Return(false, nil)
+			},
+			wantStatus: customerrors.ErrUnauthorized.Status,
+		},
+		{
+			name:  "Given an unknown refresh token, When Refresh is called, Then an unauthorized error is returned",
+			token: "unknown",
+			setup: func(users *userrepomock.MockRepository, sessions *sessionmocks.MockRepository) {
+				sessions.On("FindByTokenHash", mock.Anything, utils.HashToken("unknown")).Return(nil, nil)
+			},
+			wantStatus: customerrors.ErrUnauthorized.Status,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := new(userrepomock.MockRepository)
+			sessions := new(sessionmocks.MockRepository)
+			tt.setup(users, sessions)
+
+			var revoked []string
+			jwtService := jwt.NewJWTService(testKeys(t), time.Minute)
+			service := New(users, sessions, jwtService, WithSessionRevoked(func(sessionID string) {
+				revoked = append(revoked, sessionID)
+			}))
+
+			tokens, err := service.Refresh(context.Background(), dto.RefreshDTO{RefreshToken: tt.token})
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				assert.NotEqual(t, tt.token, tokens.RefreshToken)
+				assert.Equal(t, 60, tokens.ExpiresIn)
+
+				claims, err := jwtService.ValidateToken(tokens.TokenString)
+				assert.NoError(t, err)
+				assert.Equal(t, "session1", claims.SessionID)
+				assert.Equal(t, roles.Moderator, claims.Role)
+			} else {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+			}
+			if tt.wantRevoked {
+				assert.Equal(t, []string{"session1"}, revoked)
+			} else {
+				assert.Empty(t, revoked)
+			}
+			users.AssertExpectations(t)
+			sessions.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_Logout(t *testing.T) {
+	sessions := new(sessionmocks.MockRepository)
+	sessions.On("Revoke", mock.Anything, "session1").Return(nil)
+
+	var revoked []string
+	service := New(nil, sessions, nil, WithSessionRevoked(func(sessionID string) {
+		revoked = append(revoked, sessionID)
+	}))
+
+	assert.NoError(t, service.Logout(context.Background(), "session1"))
+	assert.Equal(t, []string{"session1"}, revoked)
+
+	var appErr *customerrors.AppError
+	assert.ErrorAs(t, service.Logout(context.Background(), ""), &appErr)
+	assert.Equal(t, customerrors.ErrUnauthorized.Status, appErr.Status)
+	sessions.AssertExpectations(t)
+}
+
+func TestService_Login_Lockout(t *testing.T) {
+	wrong := dto.LoginDTO{Username: "alice", Password: "wrong"}
+	policy := lockout.Policy{MaxFailures: 2, Window: time.Minute, Lockout: time.Minute}
+
+	t.Run("Given repeated failed logins, When the account locks, Then later logins are refused before the user is loaded", func(t *testing.T) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByUsername", mock.Anything, "alice").Return(&dao.User{Username: "alice", Password: "not-a-hash"}, nil).Times(2)
+		service := New(mockRepo, nil, nil, WithLoginLockout(policy, lockout.Policy{}))
+
+		for range 2 {
+			_, err := service.Login(context.Background(), wrong, "10.0.0.1")
+			var appErr *customerrors.AppError
+			assert.ErrorAs(t, err, &appErr)
+			assert.Equal(t, customerrors.ErrUnauthorized.Status, appErr.Status)
+		}
+
+		_, err := service.Login(context.Background(), wrong, "10.0.0.2")
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, http.StatusLocked, appErr.Status)
+		assert.Equal(t, "account is locked after too many failed logins, try again in 1m0s", appErr.Message)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Given repeated failed logins from one address, When another account is tried from it, Then it is refused", func(t *testing.T) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByUsername", mock.Anything, mock.Anything).Return(nil, errors.New("record not found")).Times(2)
+		service := New(mockRepo, nil, nil, WithLoginLockout(lockout.Policy{}, policy))
+
+		for _, username := range []string{"ghost1", "ghost2"} {
+			_, err := service.Login(context.Background(), dto.LoginDTO{Username: username, Password: "wrong"}, "10.0.0.1")
+			assert.Error(t, err)
+		}
+
+		_, err := service.Login(context.Background(), dto.LoginDTO{Username: "alice", Password: "wrong"}, "10.0.0.1")
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, http.StatusTooManyRequests, appErr.Status)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Given a failed login, When the next attempt comes before the delay, Then it is refused", func(t *testing.T) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByUsername", mock.Anything, "alice").Return(&dao.User{Username: "alice", Password: "not-a-hash"}, nil).Once()
+		delayed := lockout.Policy{MaxFailures: 5, Window: time.Minute, BaseDelay: time.Minute, Lockout: time.Minute}
+		service := New(mockRepo, nil, nil, WithLoginLockout(delayed, lockout.Policy{}))
+
+		_, err := service.Login(context.Background(), wrong, "10.0.0.1")
+		assert.Error(t, err)
+
+		_, err = service.Login(context.Background(), wrong, "10.0.0.1")
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, http.StatusTooManyRequests, appErr.Status)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestService_UpdateProfile(t *testing.T) {
+	saved := &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice", DisplayName: "Alice", Timezone: "UTC"}
+	displayName, locale := "Alice Liddell", ""
+
+	mockRepo := new(userrepomock.MockRepository)
+	mockRepo.On("FindByID", mock.Anything, "user1").Return(saved, nil)
+	mockRepo.On("UpdateProfile", mock.Anything, mock.MatchedBy(func(user dao.User) bool {
+		return user.DisplayName == displayName && user.Timezone == "UTC" && user.Locale == ""
+	})).Return(true, nil)
+	service := New(mockRepo, nil, nil)
+
+	profile, err := service.UpdateProfile(context.Background(), "user1", dto.UpdateProfileDTO{DisplayName: &displayName, Locale: &locale})
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice Liddell", profile.DisplayName)
+	assert.Equal(t, "UTC", profile.Timezone)
+	assert.Equal(t, "alice", profile.Username)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestService_UpdateProfile_Email(t *testing.T) {
+	hashed, _ := utils.HashPassword("password123")
+	oldEmail, newEmail := "alice@example.com", "Alice@Elsewhere.com"
+	saved := &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice", Password: hashed, Email: &oldEmail}
+
+	t.Run("Given a wrong current password, When the email is changed, Then it is refused", func(t *testing.T) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByID", mock.Anything, "user1").Return(saved, nil)
+		resets := new(resetmocks.MockRepository)
+		service := New(mockRepo, nil, nil, WithPasswordReset(resets, new(mailmocks.MockMailer), "http://localhost:3000/", time.Hour))
+
+		_, err := service.UpdateProfile(context.Background(), "user1", dto.UpdateProfileDTO{Email: &newEmail, CurrentPassword: "wrong"})
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, http.StatusForbidden, appErr.Status)
+		mockRepo.AssertNotCalled(t, "UpdateProfile", mock.Anything, mock.Anything)
+		resets.AssertNotCalled(t, "InvalidateAll", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Given the current password, When the email is changed, Then pending resets are invalidated and the old address is told", func(t *testing.T) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByID", mock.Anything, "user1").Return(saved, nil)
+		mockRepo.On("FindByEmail", mock.Anything, "alice@elsewhere.com").Return(nil, errors.New("record not found"))
+		mockRepo.On("UpdateProfile", mock.Anything, mock.MatchedBy(func(user dao.User) bool {
+			return user.Email != nil && *user.Email == "alice@elsewhere.com"
+		})).Return(true, nil)
+		resets := new(resetmocks.MockRepository)
+		resets.On("InvalidateAll", mock.Anything, "user1").Return(nil)
+		mailer := new(mailmocks.MockMailer)
+		var sent mail.Message
+		mailer.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(mail.Message)
+		}).Return(nil)
+		service := New(mockRepo, nil, nil, WithPasswordReset(resets, mailer, "http://localhost:3000/", time.Hour))
+
+		profile, err := service.UpdateProfile(context.Background(), "user1", dto.UpdateProfileDTO{Email: &newEmail, CurrentPassword: "password123"})
+		assert.NoError(t, err)
+		assert.Equal(t, "alice@elsewhere.com", profile.Email)
+		assert.NoError(t, service.Drain(context.Background()))
+		assert.Equal(t, "alice@example.com", sent.To)
+		mockRepo.AssertExpectations(t)
+		resets.AssertExpectations(t)
+	})
+
+	t.Run("Given the same email, When the profile is updated, Then no password is needed", func(t *testing.T) {
+		same := "ALICE@example.com"
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByID", mock.Anything, "user1").Return(saved, nil)
+		mockRepo.On("FindByEmail", mock.Anything, "alice@example.com").Return(saved, nil)
+		mockRepo.On("UpdateProfile", mock.Anything, mock.Anything).Return(true, nil)
+		mailer := new(mailmocks.MockMailer)
+		service := New(mockRepo, nil, nil, WithPasswordReset(new(resetmocks.MockRepository), mailer, "http://localhost:3000/", time.Hour))
+
+		_, err := service.UpdateProfile(context.Background(), "user1", dto.UpdateProfileDTO{Email: &same})
+		assert.NoError(t, err)
+		assert.NoError(t, service.Drain(context.Background()))
+		mailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+}
+
+func TestService_ChangePassword(t *testing.T) {
+	hashed, _ := utils.HashPassword("password123")
+	saved := &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice", Password: hashed}
+
+	tests := []struct {
+		name        string
+		current     string
+		wantStatus  int
+		wantRevoked []string
+	}{
+		{
+			name:       "Given a wrong current password, When ChangePassword is called, Then it is refused and no session is revoked",
+			current:    "wrong",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:        "Given the current password, When ChangePassword is called, Then the password is replaced and the other sessions are revoked",
+			current:     "password123",
+			wantRevoked: []string{"session2", "session3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(userrepomock.MockRepository)
+			mockRepo.On("FindByID", mock.Anything, "user1").Return(saved, nil)
+			mockRepo.On("UpdatePassword", mock.Anything, "user1", mock.MatchedBy(func(hash string) bool {
+				return utils.CheckPasswordHash("new-password", hash)
+			})).Return(true, nil).Maybe()
+			sessions := new(sessionmocks.MockRepository)
+			sessions.On("RevokeAll", mock.Anything, "user1", "session1").Return([]string{"session2", "session3"}, nil).Maybe()
+
+			var revoked []string
+			service := New(mockRepo, sessions, nil, WithSessionRevoked(func(sessionID string) {
+				revoked = append(revoked, sessionID)
+			}))
+
+			err := service.ChangePassword(context.Background(), "user1", "session1", dto.ChangePasswordDTO{CurrentPassword: tt.current, NewPassword: "new-password"})
+			if tt.wantStatus != 0 {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+				mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				mockRepo.AssertExpectations(t)
+			}
+			assert.Equal(t, tt.wantRevoked, revoked)
+		})
+	}
+}
+
+func TestService_Delete(t *testing.T) {
+	hashed, _ := utils.HashPassword("password123")
+	saved := &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice", Password: hashed}
+
+	tests := []struct {
+		name        string
+		password    string
+		wantStatus  int
+		wantRevoked []string
+	}{
+		{
+			name:       "Given a wrong password, When Delete is called, Then the account is kept",
+			password:   "wrong",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:        "Given the account's password, When Delete is called, Then every session is revoked and the account is deleted",
+			password:    "password123",
+			wantRevoked: []string{"session1", "session2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(userrepomock.MockRepository)
+			mockRepo.On("FindByID", mock.Anything, "user1").Return(saved, nil)
+			mockRepo.On("Delete", mock.Anything, "user1").Return(nil).Maybe()
+			sessions := new(sessionmocks.MockRepository)
+			sessions.On("RevokeAll", mock.Anything, "user1", "").Return([]string{"session1", "session2"}, nil).Maybe()
+
+			var revoked []string
+			service := New(mockRepo, sessions, nil, WithSessionRevoked(func(sessionID string) {
+				revoked = append(revoked, sessionID)
+			}))
+
+			err := service.Delete(context.Background(), "user1", dto.DeleteAccountDTO{Password: tt.password})
+			if tt.wantStatus != 0 {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+				mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				mockRepo.AssertExpectations(t)
+				sessions.AssertExpectations(t)
+			}
+			assert.Equal(t, tt.wantRevoked, revoked)
+		})
+	}
+
+	t.Run("Given an unknown user, When Delete is called, Then not found is returned", func(t *testing.T) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByID", mock.Anything, "ghost").Return(nil, errors.New("record not found"))
+
+		err := New(mockRepo, nil, nil).Delete(context.Background(), "ghost", dto.DeleteAccountDTO{Password: "password123"})
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, http.StatusNotFound, appErr.Status)
+	})
+}
+
+func TestService_ForgotPassword(t *testing.T) {
+	email := "alice@example.com"
+	alice := &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice", Email: &email}
+
+	t.Run("Given an account with the email, When ForgotPassword is called, Then a single reset link is mailed and only its hash is stored", func(t *testing.T) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "alice@example.com").Return(alice, nil)
+		resets := new(resetmocks.MockRepository)
+		var stored resetdao.PasswordReset
+		resets.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			stored = args.Get(1).(resetdao.PasswordReset)
+		}).Return(nil)
+		mailer := new(mailmocks.MockMailer)
+		var sent mail.Message
+		mailer.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			sent = args.Get(1).(mail.Message)
+		}).Return(nil)
+		service := New(mockRepo, nil, nil, WithPasswordReset(resets, mailer, "http://localhost:3000/", time.Hour))
+
+		err := service.ForgotPassword(context.Background(), dto.ForgotPasswordDTO{Email: "Alice@Example.com"})
+		assert.NoError(t, err)
+		assert.NoError(t, service.Drain(context.Background()))
+		assert.Equal(t, "alice@example.com", sent.To)
+
+		_, token, found := strings.Cut(sent.Body, "reset_token=")
+		assert.True(t, found)
+		token = strings.Fields(token)[0]
+		assert.Equal(t, utils.HashToken(token), stored.TokenHash)
+		assert.Equal(t, "user1", stored.UserID)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), stored.ExpiresAt, time.Minute)
+
+		assert.NoError(t, service.ForgotPassword(context.Background(), dto.ForgotPasswordDTO{Email: "alice@example.com"}))
+		assert.NoError(t, service.Drain(context.Background()))
+		mailer.AssertNumberOfCalls(t, "Send", 1)
+	})
+
+	t.Run("Given a slow mail server, When ForgotPassword is called, Then it returns before the email is sent", func(t *testing.T) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "alice@example.com").Return(alice, nil)
+		resets := new(resetmocks.MockRepository)
+		resets.On("Create", mock.Anything, mock.Anything).Return(nil)
+		release := make(chan struct{})
+		mailer := new(mailmocks.MockMailer)
+		mailer.On("Send", mock.Anything, mock.Anything).Run(func(mock.Arguments) { <-release }).Return(nil)
+		service := New(mockRepo, nil, nil, WithPasswordReset(resets, mailer, "http://localhost:3000/", time.Hour))
+
+		assert.NoError(t, service.ForgotPassword(context.Background(), dto.ForgotPasswordDTO{Email: "alice@example.com"}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, context.DeadlineExceeded, service.Drain(ctx))
+
+		close(release)
+		assert.NoError(t, service.Drain(context.Background()))
+		mailer.AssertNumberOfCalls(t, "Send", 1)
+	})
+
+	t.Run("Given no account with the email, When ForgotPassword is called, Then it succeeds without sending anything", func(t *testing.T) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "ghost@example.com").Return(nil, errors.New("record not found"))
+		mailer := new(mailmocks.MockMailer)
+		service := New(mockRepo, nil, nil, WithPasswordReset(new(resetmocks.MockRepository), mailer, "http://localhost:3000/", time.Hour))
+
+		assert.NoError(t, service.ForgotPassword(context.Background(), dto.ForgotPasswordDTO{Email: "ghost@example.com"}))
+		assert.NoError(t, service.Drain(context.Background()))
+		mailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything)
+	})
+}
+
+func TestService_ResetPassword(t *testing.T) {
+	token, hash, _ := utils.GenerateOpaqueToken()
+	usedAt := time.Now().Add(-time.Minute)
+	alice := &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice"}
+
+	tests := []struct {
+		name        string
+		reset       *resetdao.PasswordReset
+		used        bool
+		wantStatus  int
+		wantRevoked []string
+	}{
+		{
+			name:        "Given a valid token, When ResetPassword is called, Then the password is replaced and every session is revoked",
+			reset:       &resetdao.PasswordReset{Entity: entity.Entity{ID: "reset1"}, UserID: "user1", TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)},
+			used:        true,
+			wantRevoked: []string{"session1"},
+		},
+		{
+			name:       "Given an unknown token, When ResetPassword is called, Then it is refused",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Given an expired token, When ResetPassword is called, Then it is refused",
+			reset:      &resetdao.PasswordReset{Entity: entity.Entity{ID: "reset1"}, UserID: "user1", TokenHash: hash, ExpiresAt: time.Now().Add(-time.Second)},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Given a token that was already used, When ResetPassword is called, Then it is refused",
+			reset:      &resetdao.PasswordReset{Entity: entity.Entity{ID: "reset1"}, UserID: "user1", TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Given a token used concurrently by another request, When ResetPassword is called, Then it is refused",
+			reset:      &resetdao.PasswordReset{Entity: entity.Entity{ID: "reset1"}, UserID: "user1", TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour)},
+			used:       false,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(userrepomock.MockRepository)
+			mockRepo.On("FindByID", mock.Anything, "user1").Return(alice, nil).Maybe()
+			mockRepo.On("UpdatePassword", mock.Anything, "user1", mock.Anything).Return(true, nil).Maybe()
+			resets := new(resetmocks.MockRepository)
+			if tt.reset != nil {
+				resets.On("FindByTokenHash", mock.Anything, hash).Return(tt.reset, nil)
+			} else {
+				resets.On("FindByTokenHash", mock.Anything, hash).Return(nil, nil)
+			}
+			resets.On("Use", mock.Anything, "reset1").Return(tt.used, nil).Maybe()
+			sessions := new(sessionmocks.MockRepository)
+			sessions.On("RevokeAll", mock.Anything, "user1", "").Return([]string{"session1"}, nil).Maybe()
+
+			var revoked []string
+			service := New(mockRepo, sessions, nil,
+				WithPasswordReset(resets, new(mailmocks.MockMailer), "http://localhost:3000/", time.Hour),
+				WithSessionRevoked(func(sessionID string) { revoked = append(revoked, sessionID) }),
+			)
+
+			err := service.ResetPassword(context.Background(), dto.ResetPasswordDTO{Token: token, NewPassword: "new-password"})
+			if tt.wantStatus != 0 {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+				mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				mockRepo.AssertExpectations(t)
+			}
+			assert.Equal(t, tt.wantRevoked, revoked)
+		})
+	}
+}
+
+func TestService_Register_Email(t *testing.T) {
+	email := "alice@example.com"
+	mockRepo := new(userrepomock.MockRepository)
+	mockRepo.On("FindByUsername", mock.Anything, "bob").Return(nil, errors.New("record not found"))
+	mockRepo.On("FindByEmail", mock.Anything, "alice@example.com").Return(&dao.User{Entity: entity.Entity{ID: "user1"}, Email: &email}, nil)
+
+	err := New(mockRepo, nil, nil).Register(context.Background(), dto.RegisterDTO{Username: "bob", Password: "password123", Email: "ALICE@example.com"})
+	var appErr *customerrors.AppError
+	assert.ErrorAs(t, err, &appErr)
+	assert.Equal(t, "email is already in use", appErr.Message)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestService_Login_MFA(t *testing.T) {
+	password, _ := utils.HashPassword("password123")
+	secret, _ := totp.GenerateSecret()
+	enabledAt := time.Now().Add(-time.Hour)
+	alice := &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice", Password: password, TOTPSecret: secret, TOTPEnabledAt: &enabledAt}
+	recoveryCode := "abcd-efgh-ijkl-mnop"
+
+	login := func(t *testing.T, opts ...Option) (*Service, *userrepomock.MockRepository, string) {
+		mockRepo := new(userrepomock.MockRepository)
+		mockRepo.On("FindByUsername", mock.Anything, "alice").Return(alice, nil)
+		mockRepo.On("FindByID", mock.Anything, "user1").Return(alice, nil).Maybe()
+		sessions := new(sessionmocks.MockRepository)
+		sessions.On("Create", mock.Anything, mock.Anything).Return(nil).Maybe()
+		service := New(mockRepo, sessions, jwt.NewJWTService(testKeys(t), time.Minute*5), opts...)
+
+		tokens, err := service.Login(context.Background(), dto.LoginDTO{Username: "alice", Password: "password123"}, "10.0.0.1")
+		assert.NoError(t, err)
+		assert.True(t, tokens.MFARequired)
+		assert.NotEmpty(t, tokens.MFAToken)
+		assert.Empty(t, tokens.TokenString)
+		sessions.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		return service, mockRepo, tokens.MFAToken
+	}
+
+	t.Run("Given a current code from the app, When VerifyMFA is called, Then the session starts and the MFA token is used up", func(t *testing.T) {
+		service, mockRepo, mfaToken := login(t)
+		step := totp.Step(time.Now())
+		mockRepo.On("UseTOTPStep", mock.Anything, "user1", step).Return(true, nil).Once()
+
+		tokens, err := service.VerifyMFA(context.Background(), dto.MFALoginDTO{MFAToken: mfaToken, Code: totpCode(t, secret, time.Now())}, "10.0.0.1")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tokens.TokenString)
+		assert.NotEmpty(t, tokens.RefreshToken)
+
+		_, err = service.VerifyMFA(context.Background(), dto.MFALoginDTO{MFAToken: mfaToken, Code: totpCode(t, secret, time.Now())}, "10.0.0.1")
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, "invalid or expired MFA token", appErr.Message)
+	})
+
+	t.Run("Given a code whose time step was already used, When VerifyMFA is called, Then it is refused", func(t *testing.T) {
+		service, mockRepo, mfaToken := login(t)
+		mockRepo.On("UseTOTPStep", mock.Anything, "user1", mock.Anything).Return(false, nil)
+
+		_, err := service.VerifyMFA(context.Background(), dto.MFALoginDTO{MFAToken: mfaToken, Code: totpCode(t, secret, time.Now())}, "10.0.0.1")
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, http.StatusUnauthorized, appErr.Status)
+		assert.Equal(t, "invalid code", appErr.Message)
+	})
+
+	t.Run("Given a recovery code typed in upper case, When VerifyMFA is called, Then its hash is used up and the session starts", func(t *testing.T) {
+		service, mockRepo, mfaToken := login(t)
+		mockRepo.On("UseRecoveryCode", mock.Anything, "user1", utils.HashToken("abcdefghijklmnop")).Return(true, nil).Once()
+
+		tokens, err := service.VerifyMFA(context.Background(), dto.MFALoginDTO{MFAToken: mfaToken, Code: strings.ToUpper(recoveryCode)}, "10.0.0.1")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tokens.TokenString)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Given repeated wrong codes, When the attempts run out, Then the MFA token stops working", func(t *testing.T) {
+		service, mockRepo, mfaToken := login(t, WithLoginLockout(lockout.Policy{}, lockout.Policy{}))
+		mockRepo.On("UseRecoveryCode", mock.Anything, "user1", mock.Anything).Return(false, nil)
+
+		for range maxMFAAttempts {
+			_, err := service.VerifyMFA(context.Background(), dto.MFALoginDTO{MFAToken: mfaToken, Code: recoveryCode}, "10.0.0.1")
+			var appErr *customerrors.AppError
+			assert.ErrorAs(t, err, &appErr)
+			assert.Equal(t, "invalid code", appErr.Message)
+		}
+
+		_, err := service.VerifyMFA(context.Background(), dto.MFALoginDTO{MFAToken: mfaToken, Code: recoveryCode}, "10.0.0.1")
+		var appErr *customerrors.AppError
+		assert.ErrorAs(t, err, &appErr)
+		assert.Equal(t, "invalid or expired MFA token", appErr.Message)
+	})
+}
+
+func TestService_ConfirmTOTP(t *testing.T) {
+	secret, _ := totp.GenerateSecret()
+
+	tests := []struct {
+		name       string
+		user       *dao.User
+		code       string
+		wantStatus int
+	}{
+		{
+			name: "Given a code from the app being enrolled, When ConfirmTOTP is called, Then two-factor authentication is enabled with ten recovery codes",
+			user: &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice", TOTPSecret: secret},
+			code: totpCode(t, secret, time.Now()),
+		},
+		{
+			name:       "Given a wrong code, When ConfirmTOTP is called, Then it is refused",
+			user:       &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice", TOTPSecret: secret},
+			code:       totpCode(t, secret, time.Now().Add(-time.Hour)),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "Given no enrolment was started, When ConfirmTOTP is called, Then it is refused",
+			user:       &dao.User{Entity: entity.Entity{ID: "user1"}, Username: "alice"},
+			code:       totpCode(t, secret, time.Now()),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(userrepomock.MockRepository)
+			mockRepo.On("FindByID", mock.Anything, "user1").Return(tt.user, nil)
+			var saved []dao.RecoveryCode
+			mockRepo.On("EnableTOTP", mock.Anything, "user1", mock.Anything, mock.Anything).
+				Run(func(args mock.Arguments) { saved = args.Get(3).([]dao.RecoveryCode) }).
+				Return(true, nil).Maybe()
+			service := New(mockRepo, nil, nil)
+
+			got, err := service.ConfirmTOTP(context.Background(), "user1", dto.MFACodeDTO{Code: tt.code})
+			if tt.wantStatus != 0 {
+				var appErr *customerrors.AppError
+				assert.ErrorAs(t, err, &appErr)
+				assert.Equal(t, tt.wantStatus, appErr.Status)
+				mockRepo.AssertNotCalled(t, "EnableTOTP", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, got.RecoveryCodes, recoveryCodeCount)
+			assert.Len(t, saved, recoveryCodeCount)
+			for i, code := range got.RecoveryCodes {
+				assert.Regexp(t, `^[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}-[a-z2-7]{4}$`, code)
+				assert.Equal(t, utils.HashToken(normalizeRecoveryCode(code)), saved[i].CodeHash)
+				assert.Equal(t, "user1", saved[i].UserID)
+			}
+		})
+	}
+}
+
+// totpCode is the code an authenticator app with secret shows at now.
+func totpCode(t *testing.T, secret string, now time.Time) string {
+	t.Helper()
+	code, err := totp.Code(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// testKeys returns a key set holding a single Ed25519 key, active now.
+func testKeys(t *testing.T) *jwt.KeySet {
+	t.Helper()
+
+	key, err := jwt.GenerateKey("test", jwt.AlgorithmEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	keys, err := jwt.NewKeySet(key)
+	if err != nil {
+		t.Fatalf("failed to build key set: %v", err)
+	}
+	return keys
+}
@@ -1,16 +1,24 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 
+	messagedao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
 	shared "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/properties"
 )
 
+// typingThrottle is the minimum interval between two typing events relayed for the same client.
+const typingThrottle = 2 * time.Second
+
 type Client struct {
 	Hub      *Hub
 	Conn     *websocket.Conn
@@ -18,17 +26,42 @@ type Client struct {
 	UserID   string
 	RoomID   string
 	Username string
+	// Role is the user's global role, taken from their token.
+	Role string
+	// SessionID is the login the connection was opened with; revoking it closes the connection.
+	SessionID string
+
+	// lastTypingAt is only touched by ReadPump.
+	lastTypingAt time.Time
 }
 
+// Message is the frame exchanged with clients. NotificationID is only set on mention frames,
+// so the client can mark the stored notification as read; Action and TargetUsername are only
+// set on moderation frames.
 type Message struct {
-	Type      string `json:"type"`
-	UserID    string `json:"user_id"`
-	Username  string `json:"username"`
-	RoomID    string `json:"room_id"`
-	Content   string `json:"content"`
-	Timestamp int64  `json:"timestamp"`
+	ID             string                     `json:"id,omitempty"`
+	Type           string                     `json:"type"`
+	UserID         string                     `json:"user_id"`
+	Username       string                     `json:"username"`
+	RoomID         string                     `json:"room_id"`
+	Content        string                     `json:"content"`
+	ReplyTo        string                     `json:"reply_to,omitempty"`
+	NotificationID string                     `json:"notification_id,omitempty"`
+	Action         string                     `json:"action,omitempty"`
+	TargetUsername string                     `json:"target_username,omitempty"`
+	Members        []Member                   `json:"members,omitempty"`
+	Reactions      []messagedao.ReactionCount `json:"reactions,omitempty"`
+	Timestamp      int64                      `json:"timestamp"`
+}
+
+type Member struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
 }
 
+// BotUsername is the display name of messages produced by the financial bot.
+const BotUsername = "Financial Bot"
+
 var AvailableCommands = []string{
 	"/stock",
 }
@@ -49,13 +82,33 @@ func NewBotMessage(roomID string, messageType MessageType, content string) Messa
 	return Message{
 		Type:      messageType.ToString(),
 		UserID:    "",
-		Username:  "Financial Bot",
+		Username:  BotUsername,
+		RoomID:    roomID,
+		Content:   content,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// NewErrorMessage builds an error frame meant only for the client whose action failed.
+func NewErrorMessage(roomID string, content string) Message {
+	return Message{
+		Type:      MessageTypeError.ToString(),
 		RoomID:    roomID,
 		Content:   content,
 		Timestamp: time.Now().Unix(),
 	}
 }
 
+// NewRateLimitedMessage builds the frame sent back to a client whose frame was dropped for exceeding its rate limit.
+func NewRateLimitedMessage(roomID string) Message {
+	return Message{
+		Type:      MessageTypeRateLimited.ToString(),
+		RoomID:    roomID,
+		Content:   "You are sending messages too quickly. Please slow down.",
+		Timestamp: time.Now().Unix(),
+	}
+}
+
 type MessageType string
 
 const (
@@ -66,6 +119,27 @@ const (
 	MessageTypeBot        MessageType = "bot"
 	MessageTypeError      MessageType = "error"
 	MessageTypeInvalid    MessageType = "invalid"
+
+	MessageTypePresence      MessageType = "presence"
+	MessageTypeTyping        MessageType = "typing"
+	MessageTypeStoppedTyping MessageType = "stopped_typing"
+
+	MessageTypeEdit           MessageType = "edit"
+	MessageTypeDelete         MessageType = "delete"
+	MessageTypeMessageEdited  MessageType = "message_edited"
+	MessageTypeMessageDeleted MessageType = "message_deleted"
+
+	MessageTypeReaction        MessageType = "reaction"
+	MessageTypeReactionUpdated MessageType = "reaction_updated"
+
+	MessageTypeMention MessageType = "mention"
+
+	MessageTypeRead        MessageType = "read"
+	MessageTypeReadReceipt MessageType = "read_receipt"
+
+	MessageTypeRateLimited MessageType = "rate_limited"
+
+	MessageTypeModeration MessageType = "moderation"
 )
 
 func (mt MessageType) ToString() string {
@@ -74,10 +148,14 @@ func (mt MessageType) ToString() string {
 
 func (c *Client) ReadPump() {
 	defer func() {
-		c.Hub.Unregister <- c
+		select {
+		case c.Hub.Unregister <- c:
+		case <-c.Hub.done:
+		}
 		c.Conn.Close()
 	}()
 
+	c.Conn.SetReadLimit(c.Hub.maxFrameSize)
 	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.Conn.SetPongHandler(func(string) error {
 		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -93,6 +171,12 @@ func (c *Client) ReadPump() {
 			break
 		}
 
+		// encoding/json would silently replace invalid UTF-8 with U+FFFD, so check the raw frame.
+		if !utf8.Valid(messageBytes) {
+			c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, rejectedText(ErrInvalidContent)))
+			continue
+		}
+
 		var message Message
 		if err := json.Unmarshal(messageBytes, &message); err != nil {
 			log.Printf("error unmarshaling message: %v", err)
@@ -102,27 +186,332 @@ func (c *Client) ReadPump() {
 		message.UserID = c.UserID
 		message.Username = c.Username
 		message.RoomID = c.RoomID
+		message.Members = nil
+		message.Reactions = nil
+		message.Action = ""
+		message.TargetUsername = ""
 
-		if strings.ToLower(message.Type) == strings.ToLower(MessageTypeCommand.ToString()) {
-			if !message.IsCommandValid() {
-				log.Printf("error publishing command message to broker: %v", err)
-				botMessage := NewBotMessage(c.RoomID, MessageTypeInvalid, "Invalid command. Verify and try again.")
-				c.Hub.broadcastToRoom(c.RoomID, botMessage)
-			}
+		if !c.allow(message) {
+			c.Hub.sendToClient(c, NewRateLimitedMessage(c.RoomID))
+			continue
+		}
 
-			updatedBytes, _ := json.Marshal(message)
-			if err := c.Hub.Broker.Publish(shared.BrokerChatCommandsQueueName, string(updatedBytes)); err != nil {
-				log.Printf("error publishing command message to broker: %v", err)
-				botMessage := NewBotMessage(c.RoomID, MessageTypeError, "Failed to process command. Please try again later.")
-				c.Hub.broadcastToRoom(c.RoomID, botMessage)
+		if until, muted := c.Hub.mutedUntil(c.RoomID, c.UserID); muted && isSilencedByMute(message) {
+			if !isTyping(message) {
+				c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, mutedText(until)))
 			}
 			continue
 		}
 
-		c.Hub.Broadcast <- message
+		if needsContent(message) {
+			content, err := sanitizeContent(message.Content, c.Hub.maxContentLength)
+			if err != nil {
+				c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, rejectedText(err)))
+				continue
+			}
+			message.Content = content
+		}
+
+		switch MessageType(strings.ToLower(message.Type)) {
+		case MessageTypeCommand:
+			c.handleCommand(message)
+		case MessageTypeTyping, MessageTypeStoppedTyping:
+			c.handleTyping(message)
+		case MessageTypeEdit:
+			c.handleEdit(message)
+		case MessageTypeDelete:
+			c.handleDelete(message)
+		case MessageTypeReaction:
+			c.handleReaction(message)
+		case MessageTypeRead:
+			c.handleRead(message)
+		default:
+			if !c.handleChat(message) {
+				return
+			}
+		}
+	}
+}
+
+// allow takes a token from the sender's bucket for the frame's kind. Buckets are keyed by user,
+// so opening more connections does not raise the limit. Typing and read frames are not limited.
+func (c *Client) allow(message Message) bool {
+	switch MessageType(strings.ToLower(message.Type)) {
+	case MessageTypeCommand:
+		return c.Hub.CommandLimiter.Allow(c.UserID)
+	case MessageTypeTyping, MessageTypeStoppedTyping, MessageTypeRead:
+		return true
+	default:
+		return c.Hub.MessageLimiter.Allow(c.UserID)
+	}
+}
+
+// needsContent reports whether message is a chat message, edit or command, whose content is validated
+// and cleaned before it is stored or broadcast.
+func needsContent(message Message) bool {
+	switch MessageType(strings.ToLower(message.Type)) {
+	case MessageTypeTyping, MessageTypeStoppedTyping, MessageTypeDelete, MessageTypeReaction, MessageTypeRead:
+		return false
+	default:
+		return true
+	}
+}
+
+// isSilencedByMute reports whether message is dropped while its sender is muted: everything that
+// reaches the room except moderation commands, deletions of their own messages and read receipts.
+// Typing events are dropped without telling the sender.
+func isSilencedByMute(message Message) bool {
+	switch MessageType(strings.ToLower(message.Type)) {
+	case MessageTypeDelete, MessageTypeRead:
+		return false
+	case MessageTypeCommand:
+		return !isModerationCommand(message.Content)
+	default:
+		return true
 	}
 }
 
+func isTyping(message Message) bool {
+	messageType := MessageType(strings.ToLower(message.Type))
+	return messageType == MessageTypeTyping || messageType == MessageTypeStoppedTyping
+}
+
+// rejectedText turns a content validation error into the text of the sender's error frame.
+func rejectedText(err error) string {
+	return "Message rejected: " + err.Error() + "."
+}
+
+// handleChat persists a chat message so it gets an ID and hands it to the hub for broadcasting.
+// A message with reply_to set is posted to that message's thread. It returns false once the hub has stopped.
+func (c *Client) handleChat(message Message) bool {
+	content, ok := c.filterContent(message.Content, false)
+	if !ok {
+		return true
+	}
+	message.Content = content
+
+	toSave := messagedao.Message{
+		RoomID:   c.RoomID,
+		UserID:   c.UserID,
+		Username: c.Username,
+		Type:     MessageTypeChat.ToString(),
+		Content:  message.Content,
+	}
+	if message.ReplyTo != "" {
+		toSave.ReplyTo = &message.ReplyTo
+	}
+
+	saved, err := c.Hub.Messages.Save(context.Background(), toSave)
+	if err != nil {
+		log.Printf("error saving message: %v", err)
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, errorText(err, "Failed to send message. Please try again later.")))
+		return true
+	}
+
+	message.ID = saved.ID
+	message.Type = saved.Type
+	message.Timestamp = saved.CreatedAt.Unix()
+	if saved.ReplyTo != nil {
+		message.ReplyTo = *saved.ReplyTo
+	}
+
+	select {
+	case c.Hub.Broadcast <- message:
+	case <-c.Hub.done:
+		return false
+	}
+
+	c.notifyMentions(message)
+	return true
+}
+
+// notifyMentions stores a notification for each user @mentioned in message and pushes it
+// to their open connections, in any room. Users who are offline find it in GET /notifications.
+func (c *Client) notifyMentions(message Message) {
+	notifications, err := c.Hub.Notifications.Mention(context.Background(), message.RoomID, message.ID, c.UserID, c.Username, message.Content)
+	if err != nil {
+		log.Printf("error saving mentions: %v", err)
+	}
+
+	for _, notification := range notifications {
+		c.Hub.sendToUser(notification.UserID, Message{
+			ID:             message.ID,
+			Type:           MessageTypeMention.ToString(),
+			UserID:         c.UserID,
+			Username:       c.Username,
+			RoomID:         message.RoomID,
+			Content:        message.Content,
+			NotificationID: notification.ID,
+			Timestamp:      notification.CreatedAt.Unix(),
+		})
+	}
+}
+
+func (c *Client) handleEdit(message Message) {
+	if message.ID == "" || strings.TrimSpace(message.Content) == "" {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, "An edit needs a message id and new content."))
+		return
+	}
+
+	content, ok := c.filterContent(message.Content, true)
+	if !ok {
+		return
+	}
+	message.Content = content
+
+	edited, err := c.Hub.Messages.Edit(context.Background(), c.RoomID, message.ID, c.UserID, message.Content)
+	if err != nil {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, errorText(err, "Failed to edit message. Please try again later.")))
+		return
+	}
+
+	// Mention notifications keep a copy of the message, which must not outlive the correction.
+	if err := c.Hub.Notifications.Revise(context.Background(), edited.ID, edited.Content); err != nil {
+		log.Printf("error updating notifications of message %s: %v", edited.ID, err)
+	}
+
+	c.Hub.broadcastToRoom(c.RoomID, Message{
+		ID:        edited.ID,
+		Type:      MessageTypeMessageEdited.ToString(),
+		UserID:    edited.UserID,
+		Username:  edited.Username,
+		RoomID:    edited.RoomID,
+		Content:   edited.Content,
+		Timestamp: edited.EditedAt.Unix(),
+	})
+}
+
+func (c *Client) handleDelete(message Message) {
+	if message.ID == "" {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, "A delete needs a message id."))
+		return
+	}
+
+	deleted, err := c.Hub.Messages.Delete(context.Background(), c.RoomID, message.ID, c.UserID)
+	if err != nil {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, errorText(err, "Failed to delete message. Please try again later.")))
+		return
+	}
+
+	if err := c.Hub.Notifications.Forget(context.Background(), []string{deleted.ID}); err != nil {
+		log.Printf("error deleting notifications of message %s: %v", deleted.ID, err)
+	}
+
+	c.Hub.broadcastToRoom(c.RoomID, Message{
+		ID:        deleted.ID,
+		Type:      MessageTypeMessageDeleted.ToString(),
+		UserID:    deleted.UserID,
+		Username:  deleted.Username,
+		RoomID:    deleted.RoomID,
+		Timestamp: deleted.DeletedAt.Unix(),
+	})
+}
+
+// handleReaction toggles the sender's emoji, carried in content, on the message with the given id
+// and broadcasts the message's new reaction counts to the room.
+func (c *Client) handleReaction(message Message) {
+	if message.ID == "" {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, "A reaction needs a message id."))
+		return
+	}
+
+	counts, err := c.Hub.Messages.React(context.Background(), c.RoomID, message.ID, c.UserID, message.Content)
+	if err != nil {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, errorText(err, "Failed to react to message. Please try again later.")))
+		return
+	}
+
+	c.Hub.broadcastToRoom(c.RoomID, Message{
+		ID:        message.ID,
+		Type:      MessageTypeReactionUpdated.ToString(),
+		UserID:    c.UserID,
+		Username:  c.Username,
+		RoomID:    c.RoomID,
+		Content:   strings.TrimSpace(message.Content),
+		Reactions: counts,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// handleRead moves the sender's read cursor up to the message with the given id and lets
+// the rest of the room know how far they have read.
+func (c *Client) handleRead(message Message) {
+	if message.ID == "" {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, "A read receipt needs a message id."))
+		return
+	}
+
+	cursor, err := c.Hub.Messages.MarkRead(context.Background(), c.RoomID, message.ID, c.UserID)
+	if err != nil {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, errorText(err, "Failed to save read receipt. Please try again later.")))
+		return
+	}
+
+	c.Hub.broadcastToRoomExcept(c.RoomID, Message{
+		ID:        cursor.LastReadMessageID,
+		Type:      MessageTypeReadReceipt.ToString(),
+		UserID:    c.UserID,
+		Username:  c.Username,
+		RoomID:    c.RoomID,
+		Timestamp: cursor.UpdatedAt.Unix(),
+	}, c)
+}
+
+// errorText returns the message of client-facing AppErrors and fallback for anything else,
+// so internal failures are not leaked to the room.
+func errorText(err error, fallback string) string {
+	var appErr *customerrors.AppError
+	if errors.As(err, &appErr) && appErr.Status < 500 {
+		return appErr.Message
+	}
+	return fallback
+}
+
+func (c *Client) handleCommand(message Message) {
+	if isModerationCommand(message.Content) {
+		c.handleModeration(message)
+		return
+	}
+
+	if !message.IsCommandValid() {
+		botMessage := NewBotMessage(c.RoomID, MessageTypeInvalid, "Invalid command. Verify and try again.")
+		c.Hub.broadcastToRoom(c.RoomID, botMessage)
+		return
+	}
+
+	updatedBytes, _ := json.Marshal(message)
+	if err := c.Hub.Broker.Publish(shared.BrokerChatCommandsQueueName, string(updatedBytes)); err != nil {
+		log.Printf("error publishing command message to broker: %v", err)
+		botMessage := NewBotMessage(c.RoomID, MessageTypeError, "Failed to process command. Please try again later.")
+		c.Hub.broadcastToRoom(c.RoomID, botMessage)
+	}
+}
+
+// handleTyping relays typing events to the rest of the room. Repeated "typing" events are throttled
+// so a client sending one per keystroke reaches the room at most once per typingThrottle. Only
+// who is typing is relayed: nothing else the client put in the frame reaches the room.
+func (c *Client) handleTyping(frame Message) {
+	now := time.Now()
+	message := Message{
+		Type:      strings.ToLower(frame.Type),
+		RoomID:    c.RoomID,
+		UserID:    c.UserID,
+		Username:  c.Username,
+		Timestamp: now.Unix(),
+	}
+
+	if message.Type == MessageTypeTyping.ToString() {
+		if now.Sub(c.lastTypingAt) < typingThrottle {
+			return
+		}
+		c.lastTypingAt = now
+	} else {
+		c.lastTypingAt = time.Time{}
+	}
+
+	c.Hub.broadcastToRoomExcept(c.RoomID, message, c)
+}
+
 func (c *Client) WritePump() {
 	defer c.Conn.Close()
 
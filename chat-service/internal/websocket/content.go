@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/filter"
+)
+
+var (
+	ErrEmptyContent   = errors.New("content is empty")
+	ErrInvalidContent = errors.New("content is not valid UTF-8")
+)
+
+// sanitizeContent strips control characters other than newlines and tabs, trims surrounding
+// whitespace and checks that what remains is non-empty and at most maxLength runes long.
+func sanitizeContent(content string, maxLength int) (string, error) {
+	if !utf8.ValidString(content) {
+		return "", ErrInvalidContent
+	}
+
+	cleaned := strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, content))
+
+	if cleaned == "" {
+		return "", ErrEmptyContent
+	}
+	if utf8.RuneCountInString(cleaned) > maxLength {
+		return "", fmt.Errorf("content is longer than %d characters", maxLength)
+	}
+
+	return cleaned, nil
+}
+
+// filterContent runs content through the filters the room has enabled and logs any hit as a
+// moderation event. It returns the content to store, possibly masked, and false once the sender
+// has been told their message was blocked. Rooms that cannot be loaded get every filter, masking.
+// edit is set when content replaces a message already sent.
+func (c *Client) filterContent(content string, edit bool) (string, bool) {
+	cfg := filter.Config{Mode: filter.ModeMask}
+	if room, err := c.Hub.Memberships.Get(context.Background(), c.RoomID); err != nil {
+		log.Printf("error loading filters of room %s: %v", c.RoomID, err)
+	} else {
+		cfg = filter.Config{Filters: room.Filters, Mode: room.FilterMode}
+	}
+
+	outcome := c.Hub.Filters.Apply(cfg, filter.Input{RoomID: c.RoomID, UserID: c.UserID, Content: content, Edit: edit})
+	if len(outcome.Hits) == 0 {
+		return outcome.Content, true
+	}
+
+	reasons := make([]string, 0, len(outcome.Hits))
+	for _, hit := range outcome.Hits {
+		reasons = append(reasons, hit.Filter+": "+hit.Reason)
+	}
+	if err := c.Hub.Memberships.RecordFilterHit(context.Background(), c.RoomID, c.UserID, c.Username, strings.Join(reasons, "; "), outcome.Blocked); err != nil {
+		log.Printf("error recording filter hit: %v", err)
+	}
+
+	if outcome.Blocked {
+		last := outcome.Hits[len(outcome.Hits)-1]
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, "Message blocked: it "+last.Reason+"."))
+		return "", false
+	}
+	return outcome.Content, true
+}
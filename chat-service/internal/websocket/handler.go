@@ -1,25 +1,49 @@
 package websocket
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 
+	authhttp "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/http"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt"
+	roomdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
+)
+
+const (
+	// Subprotocol is the WebSocket subprotocol of the chat. Clients authenticating through
+	// Sec-WebSocket-Protocol offer it next to their token, and the server selects it, so the
+	// token is never echoed back.
+	Subprotocol = "chat"
+	// bearerProtocolPrefix marks the offered subprotocol that carries the access token.
+	bearerProtocolPrefix = "bearer."
 )
 
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
+	Subprotocols: []string{Subprotocol},
 }
 
+// WsHandler upgrades authenticated requests to a chat connection in the room of the room query
+// parameter. Clients authenticate with, in order of preference:
+//   - ?ticket=, a single-use ticket from POST /ws/ticket;
+//   - Sec-WebSocket-Protocol: chat, bearer.<access token>;
+//   - ?token=<access token>, only while the deprecated query token auth is enabled.
 func WsHandler(hub *Hub, jwtService *jwt.JWTService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		token := r.URL.Query().Get("token")
-		if token == "" {
-			http.Error(w, "token required", http.StatusUnauthorized)
+		token, err := hub.handshakeToken(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
@@ -30,13 +54,31 @@ func WsHandler(hub *Hub, jwtService *jwt.JWTService) http.HandlerFunc {
 		}
 
 		username := claims.Username
+		role := claims.Role
+		if role == "" {
+			role = roles.Member
+		}
 		roomID := r.URL.Query().Get("room")
 		if roomID == "" {
-			roomID = "general"
+			roomID = roomdao.DefaultRoomID
 		}
 
 		fmt.Printf("websocket connection for user %s (%s) joining room %s\n", claims.UserID, username, roomID)
 
+		member, err := hub.Memberships.Join(r.Context(), roomID, claims.UserID, username)
+		if err != nil {
+			var appErr *customerrors.AppError
+			if errors.As(err, &appErr) && appErr.Status == http.StatusForbidden {
+				http.Error(w, appErr.Message, http.StatusForbidden)
+				return
+			}
+			http.Error(w, "could not join room", http.StatusInternalServerError)
+			return
+		}
+		if member.IsMuted(time.Now()) {
+			hub.mute(roomID, claims.UserID, member.MutedUntil)
+		}
+
 		conn, err := upgrader.Upgrade(w, r, nil)
 		if err != nil {
 			http.Error(w, "could not open websocket connection", http.StatusBadRequest)
@@ -44,17 +86,124 @@ func WsHandler(hub *Hub, jwtService *jwt.JWTService) http.HandlerFunc {
 		}
 
 		client := &Client{
-			Hub:      hub,
-			Conn:     conn,
-			Send:     make(chan []byte, 256),
-			UserID:   claims.UserID,
-			RoomID:   roomID,
-			Username: username,
+			Hub:       hub,
+			Conn:      conn,
+			Send:      make(chan []byte, 256),
+			UserID:    claims.UserID,
+			RoomID:    roomID,
+			Username:  username,
+			Role:      role,
+			SessionID: claims.SessionID,
 		}
 
-		client.Hub.Register <- client
+		select {
+		case client.Hub.Register <- client:
+		case <-client.Hub.done:
+			conn.Close()
+			return
+		}
 
 		go client.WritePump()
 		go client.ReadPump()
 	}
 }
+
+// handshakeToken returns the access token the handshake authenticates with.
+func (h *Hub) handshakeToken(r *http.Request) (string, error) {
+	if value := r.URL.Query().Get("ticket"); value != "" {
+		token, ok := h.tickets.redeem(value)
+		if !ok {
+			return "", errors.New("invalid or expired ticket")
+		}
+		return token, nil
+	}
+
+	for _, protocol := range websocket.Subprotocols(r) {
+		if token, ok := strings.CutPrefix(protocol, bearerProtocolPrefix); ok {
+			return token, nil
+		}
+	}
+
+	if token := r.URL.Query().Get("token"); token != "" {
+		if !h.queryTokenAuth {
+			return "", errors.New("tokens in the URL are not accepted, use a ticket from POST /ws/ticket")
+		}
+		log.Printf("deprecated: websocket authenticated with a token in the URL from %s", r.RemoteAddr)
+		return token, nil
+	}
+
+	return "", errors.New("token required")
+}
+
+type TicketResponse struct {
+	Ticket    string `json:"ticket"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// TicketHandler serves POST /ws/ticket: it trades the request's access token for a single-use
+// ticket to put in the WebSocket URL. It must be wrapped by AuthMiddleware.
+func TicketHandler(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := authhttp.BearerToken(r)
+
+		ticket, err := hub.tickets.issue(token)
+		if err != nil {
+			customerrors.HandleError(w, customerrors.Wrap(customerrors.ErrInternal, errors.New("an error ocurred issuing ticket")))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(TicketResponse{
+			Ticket:    ticket,
+			ExpiresIn: int(TicketTTL.Seconds()),
+		})
+	}
+}
+
+type MembersResponse struct {
+	RoomID  string   `json:"room_id"`
+	Members []Member `json:"members"`
+}
+
+// MembersHandler lists the users connected to the room in the {id} path segment. Only members
+// of the room who are not banned from it can see who is online.
+func MembersHandler(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roomID := r.PathValue("id")
+		userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+
+		member, err := hub.Memberships.IsMember(r.Context(), roomID, userID)
+		if err != nil {
+			customerrors.HandleError(w, err)
+			return
+		}
+		if !member {
+			customerrors.HandleError(w, customerrors.Wrap(customerrors.ErrForbidden, errors.New("you are not a member of this room")))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(MembersResponse{
+			RoomID:  roomID,
+			Members: hub.Members(roomID),
+		})
+	}
+}
+
+// DeleteRoomHandler serves DELETE /rooms/{id}: it deletes the room on behalf of its owner or an
+// admin and disconnects everyone in it.
+func DeleteRoomHandler(hub *Hub) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(authhttp.UserIDKey).(string)
+		role, _ := r.Context().Value(authhttp.RoleKey).(string)
+
+		if err := hub.DeleteRoom(r.Context(), r.PathValue("id"), userID, role); err != nil {
+			customerrors.HandleError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
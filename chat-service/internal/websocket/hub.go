@@ -1,32 +1,92 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/broker"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/filter"
+	messagedao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	messageport "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/service/port"
+	notificationport "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/service/port"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/ratelimit"
+	roomport "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/service/port"
 	"log"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
+var ErrHubStopped = errors.New("hub is stopped")
+
+// memberKey identifies a user within a room.
+type memberKey struct {
+	roomID string
+	userID string
+}
+
 // Hub maintains the set of active clients and broadcasts messages to the clients;
-// Rooms: Map of RoomID to set of Clients;
+// Rooms: Map of RoomID to set of Clients, guarded by mu so HTTP handlers can read it;
 // Broadcast: Channel responsible for broadcasting messages to rooms;
 // Register: Responsible for registering new clients and adding them to rooms;
-// Unregister: Responsible for unregistering clients and removing them from rooms.
+// Unregister: Responsible for unregistering clients and removing them from rooms;
+// Messages: Persists chat and bot messages and applies edits and deletions;
+// Notifications: Stores notifications, such as mentions, for users who may be in another room or offline;
+// Memberships: Records the rooms each user has joined, which scopes access to their history;
+// MessageLimiter and CommandLimiter: Per-user token buckets that protect the hub and the broker from floods;
+// Filters: Content filters run on chat messages and edits before they are stored and broadcast.
 type Hub struct {
-	Rooms      map[string]map[*Client]bool
-	Broadcast  chan Message
-	Register   chan *Client
-	Unregister chan *Client
-	Broker     broker.Producer
+	Rooms         map[string]map[*Client]bool
+	Broadcast     chan Message
+	Register      chan *Client
+	Unregister    chan *Client
+	Broker        broker.Producer
+	Messages      messageport.MessageService
+	Notifications notificationport.NotificationService
+	Memberships   roomport.RoomService
+
+	MessageLimiter *ratelimit.Limiter
+	CommandLimiter *ratelimit.Limiter
+	Filters        *filter.Chain
+
+	maxFrameSize     int64
+	maxContentLength int
+	queryTokenAuth   bool
+	tickets          *ticketStore
+
+	// muted holds when each mute in force ends, keyed by room and user; a zero time never ends.
+	muted map[memberKey]time.Time
+
+	mu   sync.RWMutex
+	stop chan chan struct{}
+	done chan struct{}
 }
 
-func NewHub(rb broker.Producer) *Hub {
+func NewHub(rb broker.Producer, messages messageport.MessageService, notifications notificationport.NotificationService, memberships roomport.RoomService, opts ...Option) *Hub {
+	o := newOptions(opts)
+
 	return &Hub{
-		Rooms:      make(map[string]map[*Client]bool),
-		Broadcast:  make(chan Message),
-		Register:   make(chan *Client),
-		Unregister: make(chan *Client),
-		Broker:     rb,
+		Rooms:            make(map[string]map[*Client]bool),
+		Broadcast:        make(chan Message),
+		Register:         make(chan *Client),
+		Unregister:       make(chan *Client),
+		Broker:           rb,
+		Messages:         messages,
+		Notifications:    notifications,
+		Memberships:      memberships,
+		MessageLimiter:   ratelimit.NewLimiter(o.messageLimit),
+		CommandLimiter:   ratelimit.NewLimiter(o.commandLimit),
+		Filters:          o.filters,
+		maxFrameSize:     o.maxFrameSize,
+		maxContentLength: o.maxContentLength,
+		queryTokenAuth:   o.queryTokenAuth,
+		tickets:          newTicketStore(TicketTTL),
+		muted:            make(map[memberKey]time.Time),
+		stop:             make(chan chan struct{}),
+		done:             make(chan struct{}),
 	}
 }
 
@@ -34,6 +94,7 @@ func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.Register:
+			h.mu.Lock()
 			if h.Rooms[client.RoomID] == nil {
 				h.Rooms[client.RoomID] = make(map[*Client]bool)
 			}
@@ -47,6 +108,7 @@ func (h *Hub) Run() {
 			}
 
 			h.Rooms[client.RoomID][client] = true
+			h.mu.Unlock()
 			log.Printf("client %s joined room %s", client.UserID, client.RoomID)
 
 			joinMessage := Message{
@@ -59,38 +121,116 @@ func (h *Hub) Run() {
 			}
 			h.broadcastToRoom(client.RoomID, joinMessage)
 
+			presenceMessage := Message{
+				Type:      MessageTypePresence.ToString(),
+				RoomID:    client.RoomID,
+				Members:   h.Members(client.RoomID),
+				Timestamp: time.Now().Unix(),
+			}
+			h.broadcastToRoom(client.RoomID, presenceMessage)
+
 		case client := <-h.Unregister:
-			if room, exists := h.Rooms[client.RoomID]; exists {
-				if _, ok := room[client]; ok {
-					delete(room, client)
-					close(client.Send)
-
-					log.Printf("client %s left room %s", client.UserID, client.RoomID)
-
-					leaveMessage := Message{
-						Type:      MessageTypeUserLeft.ToString(),
-						UserID:    client.UserID,
-						Username:  client.Username,
-						RoomID:    client.RoomID,
-						Content:   client.Username + " left the room",
-						Timestamp: time.Now().Unix(),
-					}
-					h.broadcastToRoom(client.RoomID, leaveMessage)
-
-					if len(room) == 0 {
-						delete(h.Rooms, client.RoomID)
-					}
+			h.mu.Lock()
+			room, exists := h.Rooms[client.RoomID]
+			_, registered := room[client]
+			if exists && registered {
+				delete(room, client)
+				close(client.Send)
+				if len(room) == 0 {
+					delete(h.Rooms, client.RoomID)
 				}
 			}
+			h.mu.Unlock()
+
+			if exists && registered {
+				log.Printf("client %s left room %s", client.UserID, client.RoomID)
+
+				leaveMessage := Message{
+					Type:      MessageTypeUserLeft.ToString(),
+					UserID:    client.UserID,
+					Username:  client.Username,
+					RoomID:    client.RoomID,
+					Content:   client.Username + " left the room",
+					Timestamp: time.Now().Unix(),
+				}
+				h.broadcastToRoom(client.RoomID, leaveMessage)
+			}
 
 		case message := <-h.Broadcast:
-			log.Printf("Broadcast to room %s, clients: %d", message.RoomID, len(h.Rooms[message.RoomID]))
+			log.Printf("Broadcast to room %s", message.RoomID)
 			h.broadcastToRoom(message.RoomID, message)
+
+		case stopped := <-h.stop:
+			h.closeAll()
+			close(h.done)
+			close(stopped)
+			return
 		}
 	}
 }
 
+// Stop sends a "server restarting" close frame to every client, closes their connections
+// and makes Run return. Clients and messages sent to the hub afterwards are dropped.
+func (h *Hub) Stop() {
+	stopped := make(chan struct{})
+	select {
+	case h.stop <- stopped:
+		<-stopped
+	case <-h.done:
+	}
+}
+
+// Members returns the users currently connected to a room, sorted by username.
+// A user with several connections is listed once.
+func (h *Hub) Members(roomID string) []Member {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	members := make([]Member, 0, len(h.Rooms[roomID]))
+	for client := range h.Rooms[roomID] {
+		if seen[client.UserID] {
+			continue
+		}
+		seen[client.UserID] = true
+		members = append(members, Member{UserID: client.UserID, Username: client.Username})
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Username < members[j].Username
+	})
+	return members
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting")
+	deadline := time.Now().Add(time.Second)
+
+	for roomID, room := range h.Rooms {
+		for client := range room {
+			if err := client.Conn.WriteControl(websocket.CloseMessage, closeMessage, deadline); err != nil {
+				log.Printf("error sending close frame to client %s: %v", client.UserID, err)
+			}
+			client.Conn.Close()
+			close(client.Send)
+		}
+		delete(h.Rooms, roomID)
+	}
+}
+
 func (h *Hub) broadcastToRoom(roomID string, message Message) {
+	h.broadcastToRoomExcept(roomID, message, nil)
+}
+
+// broadcastToRoomExcept sends message to every client in the room other than skip.
+// Clients whose send buffer is full are dropped from the room.
+func (h *Hub) broadcastToRoomExcept(roomID string, message Message, skip *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
 	if room, exists := h.Rooms[roomID]; exists {
 		messageBytes, err := json.Marshal(message)
 		if err != nil {
@@ -99,6 +239,10 @@ func (h *Hub) broadcastToRoom(roomID string, message Message) {
 		}
 
 		for client := range room {
+			if client == skip {
+				continue
+			}
+
 			select {
 			case client.Send <- messageBytes:
 			default:
@@ -109,12 +253,202 @@ func (h *Hub) broadcastToRoom(roomID string, message Message) {
 	}
 }
 
-func (h *Hub) HandleBotMessage(message string) error {
+// mute drops the user's messages in the room until until, or until unmute when until is nil.
+func (h *Hub) mute(roomID, userID string, until *time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var end time.Time
+	if until != nil {
+		end = *until
+	}
+	h.muted[memberKey{roomID: roomID, userID: userID}] = end
+}
+
+func (h *Hub) unmute(roomID, userID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.muted, memberKey{roomID: roomID, userID: userID})
+}
+
+// mutedUntil reports whether the user is muted in the room and when the mute ends,
+// a zero time meaning it lasts until it is lifted. Expired mutes are forgotten.
+func (h *Hub) mutedUntil(roomID, userID string) (time.Time, bool) {
+	key := memberKey{roomID: roomID, userID: userID}
+
+	h.mu.RLock()
+	end, muted := h.muted[key]
+	h.mu.RUnlock()
+
+	if muted && !end.IsZero() && !time.Now().Before(end) {
+		h.unmute(roomID, userID)
+		return time.Time{}, false
+	}
+	return end, muted
+}
+
+// disconnect closes every connection the user has to the room with a policy violation close frame
+// carrying reason. ReadPump then unregisters each client as usual.
+func (h *Hub) disconnect(roomID, userID, reason string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	// Close frame payloads are limited to 125 bytes, two of which hold the code.
+	if len(reason) > 123 {
+		reason = strings.ToValidUTF8(reason[:123], "")
+	}
+	closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+	deadline := time.Now().Add(time.Second)
+
+	for client := range h.Rooms[roomID] {
+		if client.UserID != userID {
+			continue
+		}
+		if err := client.Conn.WriteControl(websocket.CloseMessage, closeMessage, deadline); err != nil {
+			log.Printf("error sending close frame to client %s: %v", client.UserID, err)
+		}
+		client.Conn.Close()
+	}
+}
+
+// closeRoom closes every connection to the room with a going away close frame carrying reason.
+func (h *Hub) closeRoom(roomID, reason string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, reason)
+	deadline := time.Now().Add(time.Second)
+
+	for client := range h.Rooms[roomID] {
+		if err := client.Conn.WriteControl(websocket.CloseMessage, closeMessage, deadline); err != nil {
+			log.Printf("error sending close frame to client %s: %v", client.UserID, err)
+		}
+		client.Conn.Close()
+	}
+}
+
+// CloseSession closes every connection opened with sessionID, in any room, once the session has
+// been revoked.
+func (h *Hub) CloseSession(sessionID string) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session was revoked")
+	deadline := time.Now().Add(time.Second)
+
+	for _, room := range h.Rooms {
+		for client := range room {
+			if client.SessionID != sessionID {
+				continue
+			}
+			if err := client.Conn.WriteControl(websocket.CloseMessage, closeMessage, deadline); err != nil {
+				log.Printf("error sending close frame to client %s: %v", client.UserID, err)
+			}
+			client.Conn.Close()
+		}
+	}
+}
+
+// DeleteRoom deletes roomID on behalf of userID, closes every connection to it and then purges
+// its messages and the notifications about them.
+func (h *Hub) DeleteRoom(ctx context.Context, roomID, userID, globalRole string) error {
+	if err := h.Memberships.Delete(ctx, roomID, userID, globalRole); err != nil {
+		return err
+	}
+
+	h.closeRoom(roomID, "room was deleted")
+
+	purged, err := h.Messages.PurgeRoom(ctx, roomID)
+	if err != nil {
+		return err
+	}
+	return h.Notifications.Forget(ctx, purged)
+}
+
+// sendToClient delivers message to a single client, if it is still connected.
+func (h *Hub) sendToClient(client *Client, message Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	room := h.Rooms[client.RoomID]
+	if !room[client] {
+		return
+	}
+
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("error marshaling message: %v", err)
+		return
+	}
+
+	select {
+	case client.Send <- messageBytes:
+	default:
+		close(client.Send)
+		delete(room, client)
+	}
+}
+
+// sendToUser delivers message to every connection of a user, whichever room it is in.
+func (h *Hub) sendToUser(userID string, message Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	messageBytes, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("error marshaling message: %v", err)
+		return
+	}
+
+	for _, room := range h.Rooms {
+		for client := range room {
+			if client.UserID != userID {
+				continue
+			}
+
+			select {
+			case client.Send <- messageBytes:
+			default:
+				close(client.Send)
+				delete(room, client)
+			}
+		}
+	}
+}
+
+// HandleBotMessage broadcasts a reply from bot-service to its room. Quotes are persisted
+// so they show up in the room history; failure notices are not.
+func (h *Hub) HandleBotMessage(ctx context.Context, message string) error {
 	var msg Message
 	if err := json.Unmarshal([]byte(message), &msg); err != nil {
 		return err
 	}
 
-	h.broadcastToRoom(msg.RoomID, msg)
-	return nil
+	if msg.Username == "" {
+		msg.Username = BotUsername
+	}
+
+	if msg.Type == MessageTypeBot.ToString() {
+		saved, err := h.Messages.Save(ctx, messagedao.Message{
+			RoomID:   msg.RoomID,
+			Username: msg.Username,
+			Type:     msg.Type,
+			Content:  msg.Content,
+		})
+		if err != nil {
+			log.Printf("error saving bot message: %v", err)
+		} else {
+			msg.ID = saved.ID
+		}
+	}
+
+	select {
+	case h.Broadcast <- msg:
+		return nil
+	case <-h.done:
+		return ErrHubStopped
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
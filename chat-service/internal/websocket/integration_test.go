@@ -0,0 +1,1315 @@
+package websocket_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	authhttp "github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/http"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/broker"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/filter"
+	messagedao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dao"
+	messagedto "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/dto"
+	messageservice "github.com/Lucas-Onofre/financial-chat/chat-service/internal/message/service"
+	notificationdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/dao"
+	notificationservice "github.com/Lucas-Onofre/financial-chat/chat-service/internal/notification/service"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/ratelimit"
+	roomdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	roomdto "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dto"
+	roomservice "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/service"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/entity"
+	customerrors "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/errors"
+	shared "github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/properties"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/shared/roles"
+	userdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/dao"
+	usermocks "github.com/Lucas-Onofre/financial-chat/chat-service/internal/user/repository/mocks"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/websocket"
+)
+
+const botQuote = "AAPL.US quote is $262.82 per share"
+
+// memoryMessageRepository keeps messages in a map so the hub can be exercised without Postgres.
+type memoryMessageRepository struct {
+	rooms *memoryRoomRepository
+
+	mu        sync.Mutex
+	messages  map[string]messagedao.Message
+	revisions []messagedao.MessageRevision
+	reactions []messagedao.Reaction
+	cursors   map[string]messagedao.ReadCursor
+}
+
+func newMemoryMessageRepository(rooms *memoryRoomRepository) *memoryMessageRepository {
+	return &memoryMessageRepository{
+		rooms:    rooms,
+		messages: make(map[string]messagedao.Message),
+		cursors:  make(map[string]messagedao.ReadCursor),
+	}
+}
+
+func (r *memoryMessageRepository) Create(_ context.Context, message messagedao.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages[message.ID] = message
+	return nil
+}
+
+func (r *memoryMessageRepository) FindByID(_ context.Context, id string) (*messagedao.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	message, ok := r.messages[id]
+	if !ok {
+		return nil, errors.New("record not found")
+	}
+	return &message, nil
+}
+
+func (r *memoryMessageRepository) Update(_ context.Context, message messagedao.Message, revision messagedao.MessageRevision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages[message.ID] = message
+	r.revisions = append(r.revisions, revision)
+	return nil
+}
+
+func (r *memoryMessageRepository) FindRevisions(_ context.Context, messageID string) ([]messagedao.MessageRevision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var revisions []messagedao.MessageRevision
+	for _, revision := range r.revisions {
+		if revision.MessageID == messageID {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+func (r *memoryMessageRepository) CreateReply(_ context.Context, reply messagedao.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages[reply.ID] = reply
+	root := r.messages[*reply.ReplyTo]
+	root.ReplyCount++
+	r.messages[root.ID] = root
+	return nil
+}
+
+func (r *memoryMessageRepository) FindReplies(_ context.Context, rootID string) ([]messagedao.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var replies []messagedao.Message
+	for _, message := range r.messages {
+		if message.ReplyTo != nil && *message.ReplyTo == rootID {
+			replies = append(replies, message)
+		}
+	}
+	return replies, nil
+}
+
+func (r *memoryMessageRepository) ToggleReaction(_ context.Context, reaction messagedao.Reaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, existing := range r.reactions {
+		if existing.MessageID == reaction.MessageID && existing.UserID == reaction.UserID && existing.Emoji == reaction.Emoji {
+			r.reactions = append(r.reactions[:i], r.reactions[i+1:]...)
+			return nil
+		}
+	}
+	r.reactions = append(r.reactions, reaction)
+	return nil
+}
+
+func (r *memoryMessageRepository) CountReactions(_ context.Context, messageIDs []string) ([]messagedao.ReactionCount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var counts []messagedao.ReactionCount
+	for _, id := range messageIDs {
+		for _, reaction := range r.reactions {
+			if reaction.MessageID != id {
+				continue
+			}
+			found := false
+			for i := range counts {
+				if counts[i].MessageID == id && counts[i].Emoji == reaction.Emoji {
+					counts[i].Count++
+					found = true
+				}
+			}
+			if !found {
+				counts = append(counts, messagedao.ReactionCount{MessageID: id, Emoji: reaction.Emoji, Count: 1})
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (r *memoryMessageRepository) SaveReadCursor(_ context.Context, cursor messagedao.ReadCursor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := cursor.UserID + "/" + cursor.RoomID
+	if existing, ok := r.cursors[key]; !ok || cursor.LastReadAt.After(existing.LastReadAt) {
+		r.cursors[key] = cursor
+	}
+	return nil
+}
+
+func (r *memoryMessageRepository) CountUnread(ctx context.Context, userID string) ([]messagedao.UnreadCount, error) {
+	roomIDs, err := r.rooms.FindRoomIDsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var counts []messagedao.UnreadCount
+	for _, roomID := range roomIDs {
+		cursor := r.cursors[userID+"/"+roomID]
+		count := messagedao.UnreadCount{RoomID: roomID, LastReadMessageID: cursor.LastReadMessageID}
+		for _, message := range r.messages {
+			if message.RoomID == roomID && message.UserID != userID && !message.IsDeleted() && message.CreatedAt.After(cursor.LastReadAt) {
+				count.Unread++
+			}
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+// Search stands in for Postgres full-text search with a case-insensitive substring match.
+func (r *memoryMessageRepository) Search(_ context.Context, filter messagedto.SearchDTO, roomIDs []string, limit int) ([]messagedao.SearchHit, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var hits []messagedao.SearchHit
+	for _, message := range r.messages {
+		index := strings.Index(strings.ToLower(message.Content), strings.ToLower(filter.Query))
+		if index < 0 || message.IsDeleted() || !slices.Contains(roomIDs, message.RoomID) || len(hits) == limit {
+			continue
+		}
+		end := index + len(filter.Query)
+		snippet := message.Content[:index] + "<mark>" + message.Content[index:end] + "</mark>" + message.Content[end:]
+		hits = append(hits, messagedao.SearchHit{Message: message, Snippet: snippet})
+	}
+	return hits, nil
+}
+
+func (r *memoryMessageRepository) ExpireBefore(_ context.Context, roomID string, cutoff time.Time, _ bool, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []string
+	for id, message := range r.messages {
+		if message.RoomID == roomID && message.CreatedAt.Before(cutoff) && len(ids) < limit {
+			ids = append(ids, id)
+			delete(r.messages, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memoryMessageRepository) FindPage(_ context.Context, filter messagedto.ExportDTO, after *messagedao.Message, limit int) ([]messagedao.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var page []messagedao.Message
+	for _, message := range r.messages {
+		if message.RoomID == filter.RoomID && (after == nil || message.CreatedAt.After(after.CreatedAt)) {
+			page = append(page, message)
+		}
+	}
+	slices.SortFunc(page, func(a, b messagedao.Message) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page, nil
+}
+
+// memoryNotificationRepository keeps notifications in a slice, newest last.
+type memoryNotificationRepository struct {
+	mu            sync.Mutex
+	notifications []notificationdao.Notification
+}
+
+func (r *memoryNotificationRepository) Create(_ context.Context, notification notificationdao.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, notification)
+	return nil
+}
+
+func (r *memoryNotificationRepository) FindByUser(_ context.Context, userID string, unreadOnly bool, limit int) ([]notificationdao.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var notifications []notificationdao.Notification
+	for i := len(r.notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+		n := r.notifications[i]
+		if n.UserID == userID && (!unreadOnly || n.ReadAt == nil) {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+func (r *memoryNotificationRepository) MarkRead(_ context.Context, userID, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.notifications {
+		if r.notifications[i].ID == id && r.notifications[i].UserID == userID {
+			now := time.Now()
+			r.notifications[i].ReadAt = &now
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryNotificationRepository) DeleteByMessageIDs(_ context.Context, messageIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = slices.DeleteFunc(r.notifications, func(n notificationdao.Notification) bool {
+		return slices.Contains(messageIDs, n.MessageID)
+	})
+	return nil
+}
+
+func (r *memoryNotificationRepository) UpdateContent(_ context.Context, messageID, content string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.notifications {
+		if r.notifications[i].MessageID == messageID {
+			r.notifications[i].Content = content
+		}
+	}
+	return nil
+}
+
+func (r *memoryNotificationRepository) MarkAllRead(_ context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.notifications {
+		if r.notifications[i].UserID == userID && r.notifications[i].ReadAt == nil {
+			now := time.Now()
+			r.notifications[i].ReadAt = &now
+		}
+	}
+	return nil
+}
+
+// memoryRoomRepository keeps rooms, memberships and moderation events in slices.
+type memoryRoomRepository struct {
+	mu      sync.Mutex
+	rooms   []roomdao.Room
+	members []roomdao.Member
+	events  []roomdao.ModerationEvent
+}
+
+func (r *memoryRoomRepository) AddMember(ctx context.Context, member roomdao.Member) error {
+	if existing, _ := r.FindMember(ctx, member.RoomID, member.UserID); existing != nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.members = append(r.members, member)
+	return nil
+}
+
+func (r *memoryRoomRepository) FindMember(_ context.Context, roomID, userID string) (*roomdao.Member, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, member := range r.members {
+		if member.RoomID == roomID && member.UserID == userID {
+			return &member, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRoomRepository) FindMemberByUsername(_ context.Context, roomID, username string) (*roomdao.Member, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, member := range r.members {
+		if member.RoomID == roomID && member.Username == username {
+			return &member, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRoomRepository) SaveModeration(_ context.Context, member *roomdao.Member, event roomdao.ModerationEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if member != nil {
+		for i := range r.members {
+			if r.members[i].ID == member.ID {
+				r.members[i] = *member
+			}
+		}
+	}
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *memoryRoomRepository) FindModerationEvents(_ context.Context, roomID string, limit int) ([]roomdao.ModerationEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []roomdao.ModerationEvent
+	for i := len(r.events) - 1; i >= 0 && len(events) < limit; i-- {
+		if r.events[i].RoomID == roomID {
+			events = append(events, r.events[i])
+		}
+	}
+	return events, nil
+}
+
+func (r *memoryRoomRepository) FindRoomIDsByUser(_ context.Context, userID string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var roomIDs []string
+	for _, member := range r.members {
+		if member.UserID == userID && !member.IsBanned(time.Now()) {
+			roomIDs = append(roomIDs, member.RoomID)
+		}
+	}
+	slices.Sort(roomIDs)
+	return roomIDs, nil
+}
+
+func (r *memoryRoomRepository) CreateRoom(_ context.Context, room roomdao.Room) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.rooms {
+		if existing.ID == room.ID {
+			return nil
+		}
+	}
+	r.rooms = append(r.rooms, room)
+	return nil
+}
+
+func (r *memoryRoomRepository) FindRoom(_ context.Context, roomID string) (*roomdao.Room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, room := range r.rooms {
+		if room.ID == roomID {
+			return &room, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRoomRepository) UpdateRoom(_ context.Context, room roomdao.Room) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.rooms {
+		if r.rooms[i].ID == room.ID {
+			r.rooms[i] = room
+		}
+	}
+	return nil
+}
+
+func (r *memoryRoomRepository) UpdateMember(_ context.Context, member roomdao.Member) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.members {
+		if r.members[i].RoomID == member.RoomID && r.members[i].UserID == member.UserID {
+			r.members[i] = member
+		}
+	}
+	return nil
+}
+
+func (r *memoryRoomRepository) DeleteRoom(_ context.Context, roomID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rooms = slices.DeleteFunc(r.rooms, func(room roomdao.Room) bool { return room.ID == roomID })
+	r.members = slices.DeleteFunc(r.members, func(member roomdao.Member) bool { return member.RoomID == roomID })
+	r.events = slices.DeleteFunc(r.events, func(event roomdao.ModerationEvent) bool { return event.RoomID == roomID })
+	return nil
+}
+
+func (r *memoryRoomRepository) FindRoomsWithRetention(_ context.Context) ([]roomdao.Room, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var rooms []roomdao.Room
+	for _, room := range r.rooms {
+		if room.RetentionDays != nil {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+// registeredUsers resolves the usernames the integration tests connect with.
+func registeredUsers() *usermocks.MockRepository {
+	users := new(usermocks.MockRepository)
+	for id, username := range map[string]string{"user1": "alice", "user2": "bob", "user3": "carol", "user4": "dave"} {
+		users.On("FindByUsername", mock.Anything, username).
+			Return(&userdao.User{Entity: entity.Entity{ID: id}, Username: username}, nil).Maybe()
+	}
+	users.On("FindByUsername", mock.Anything, mock.Anything).Return(nil, errors.New("record not found")).Maybe()
+	return users
+}
+
+// startStack wires a Hub, the WebSocket handler and a stand-in for bot-service
+// through an in-memory broker. The stand-in answers every command the same way
+// bot-service does: a "bot" message published to the responses queue.
+func startStack(t *testing.T, opts ...websocket.Option) (*httptest.Server, *jwt.JWTService, *websocket.Hub) {
+	t.Helper()
+
+	mb := broker.NewMemoryBroker()
+	t.Cleanup(func() { mb.Close() })
+
+	rooms := &memoryRoomRepository{}
+	hub := websocket.NewHub(mb,
+		messageservice.New(newMemoryMessageRepository(rooms), rooms),
+		notificationservice.New(&memoryNotificationRepository{}, registeredUsers(), rooms),
+		roomservice.New(rooms),
+		opts...,
+	)
+	go hub.Run()
+
+	require.NoError(t, mb.Subscribe(shared.BrokerChatResponsesQueueName, hub.HandleBotMessage))
+	require.NoError(t, mb.Subscribe(shared.BrokerChatCommandsQueueName, func(_ context.Context, message string) error {
+		var command websocket.Message
+		if err := json.Unmarshal([]byte(message), &command); err != nil {
+			return err
+		}
+
+		reply, err := json.Marshal(websocket.NewBotMessage(command.RoomID, websocket.MessageTypeBot, botQuote))
+		if err != nil {
+			return err
+		}
+		return mb.Publish(shared.BrokerChatResponsesQueueName, string(reply))
+	}))
+
+	jwtService := jwt.NewJWTService(testKeys(t), time.Minute*5)
+	mux := http.NewServeMux()
+	mux.Handle("/ws/ticket", authhttp.AuthMiddleware(jwtService)(websocket.TicketHandler(hub)))
+	mux.Handle("/", websocket.WsHandler(hub, jwtService))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server, jwtService, hub
+}
+
+func dial(t *testing.T, server *httptest.Server, jwtService *jwt.JWTService, userID, username, room string) *gorillaws.Conn {
+	t.Helper()
+
+	token, err := jwtService.GenerateToken(userID, username, roles.Member, "session-"+userID)
+	require.NoError(t, err)
+
+	conn, _, err := bearerDialer(token).Dial(wsURL(server, url.Values{"room": {room}}), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	readUntil(t, conn, websocket.MessageTypeUserJoined)
+	return conn
+}
+
+// bearerDialer authenticates the handshake with token through Sec-WebSocket-Protocol.
+func bearerDialer(token string) *gorillaws.Dialer {
+	return &gorillaws.Dialer{Subprotocols: []string{websocket.Subprotocol, "bearer." + token}}
+}
+
+func wsURL(server *httptest.Server, query url.Values) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "?" + query.Encode()
+}
+
+func readUntil(t *testing.T, conn *gorillaws.Conn, messageType websocket.MessageType) websocket.Message {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg websocket.Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("waiting for %q message: %v", messageType, err)
+		}
+		if msg.Type == messageType.ToString() {
+			return msg
+		}
+	}
+}
+
+func TestIntegration_WebSocketFlows(t *testing.T) {
+	tests := []struct {
+		name     string
+		send     websocket.Message
+		wantType websocket.MessageType
+		want     func(t *testing.T, got websocket.Message)
+	}{
+		{
+			name:     "Given a connected client, When it sends a stock command, Then the bot reply is delivered to the room",
+			send:     websocket.Message{Type: websocket.MessageTypeCommand.ToString(), Content: "/stock=AAPL.US"},
+			wantType: websocket.MessageTypeBot,
+			want: func(t *testing.T, got websocket.Message) {
+				assert.Equal(t, "stocks", got.RoomID)
+				assert.Equal(t, botQuote, got.Content)
+			},
+		},
+		{
+			name:     "Given a connected client, When it sends a chat message, Then the message is broadcast to the room",
+			send:     websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "hello"},
+			wantType: websocket.MessageTypeChat,
+			want: func(t *testing.T, got websocket.Message) {
+				assert.Equal(t, "user1", got.UserID)
+				assert.Equal(t, "alice", got.Username)
+				assert.Equal(t, "hello", got.Content)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, jwtService, _ := startStack(t)
+			sender := dial(t, server, jwtService, "user1", "alice", "stocks")
+			listener := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+			require.NoError(t, sender.WriteJSON(tt.send))
+
+			tt.want(t, readUntil(t, listener, tt.wantType))
+		})
+	}
+}
+
+func TestIntegration_HubStop(t *testing.T) {
+	server, jwtService, hub := startStack(t)
+	conn := dial(t, server, jwtService, "user1", "alice", "stocks")
+
+	hub.Stop()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if err == nil {
+			continue
+		}
+
+		var closeErr *gorillaws.CloseError
+		require.ErrorAs(t, err, &closeErr)
+		assert.Equal(t, gorillaws.CloseServiceRestart, closeErr.Code)
+		assert.Equal(t, "server restarting", closeErr.Text)
+		break
+	}
+
+	assert.ErrorIs(t, hub.HandleBotMessage(context.Background(), `{"type":"bot","room_id":"stocks"}`), websocket.ErrHubStopped)
+}
+
+func TestIntegration_Presence(t *testing.T) {
+	server, jwtService, hub := startStack(t)
+	alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+	dial(t, server, jwtService, "user2", "bob", "stocks")
+
+	presence := readUntil(t, alice, websocket.MessageTypePresence)
+	for len(presence.Members) < 2 {
+		presence = readUntil(t, alice, websocket.MessageTypePresence)
+	}
+
+	want := []websocket.Member{
+		{UserID: "user1", Username: "alice"},
+		{UserID: "user2", Username: "bob"},
+	}
+	assert.Equal(t, want, presence.Members)
+
+	members := func(userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/rooms/stocks/members", nil)
+		req.SetPathValue("id", "stocks")
+		req = req.WithContext(context.WithValue(req.Context(), authhttp.UserIDKey, userID))
+		rr := httptest.NewRecorder()
+		websocket.MembersHandler(hub).ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := members("user1")
+	var got websocket.MembersResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, websocket.MembersResponse{RoomID: "stocks", Members: want}, got)
+
+	assert.Equal(t, http.StatusForbidden, members("user3").Code, "carol never joined the room")
+
+	require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeCommand.ToString(), Content: "/ban bob"}))
+	readUntil(t, alice, websocket.MessageTypeModeration)
+	assert.Equal(t, http.StatusForbidden, members("user2").Code, "bob was banned from the room")
+
+	unread, err := hub.Messages.(*messageservice.Service).Unread(context.Background(), "user2")
+	require.NoError(t, err)
+	assert.Empty(t, unread, "bob no longer sees the room he was banned from")
+}
+
+func TestIntegration_TypingIsThrottled(t *testing.T) {
+	server, jwtService, _ := startStack(t)
+	sender := dial(t, server, jwtService, "user1", "alice", "stocks")
+	listener := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+	for _, messageType := range []websocket.MessageType{
+		websocket.MessageTypeTyping,
+		websocket.MessageTypeTyping,
+		websocket.MessageTypeTyping,
+		websocket.MessageTypeStoppedTyping,
+	} {
+		require.NoError(t, sender.WriteJSON(websocket.Message{Type: messageType.ToString(), ID: "forged", ReplyTo: "forged", NotificationID: "forged"}))
+	}
+
+	var relayed []string
+	listener.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg websocket.Message
+		require.NoError(t, listener.ReadJSON(&msg))
+		if msg.Type != websocket.MessageTypeTyping.ToString() && msg.Type != websocket.MessageTypeStoppedTyping.ToString() {
+			continue
+		}
+
+		assert.Equal(t, websocket.Message{Type: msg.Type, RoomID: "stocks", UserID: "user1", Username: "alice", Timestamp: msg.Timestamp}, msg,
+			"only who is typing is relayed")
+		relayed = append(relayed, msg.Type)
+		if msg.Type == websocket.MessageTypeStoppedTyping.ToString() {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"typing", "stopped_typing"}, relayed)
+}
+
+func TestIntegration_EditAndDelete(t *testing.T) {
+	tests := []struct {
+		name     string
+		editor   string
+		frame    func(id string) websocket.Message
+		wantType websocket.MessageType
+		want     func(t *testing.T, got websocket.Message, id string)
+	}{
+		{
+			name:   "Given the author edits their message, When the edit frame is sent, Then the room receives message_edited",
+			editor: "alice",
+			frame: func(id string) websocket.Message {
+				return websocket.Message{Type: websocket.MessageTypeEdit.ToString(), ID: id, Content: "hello, fixed"}
+			},
+			wantType: websocket.MessageTypeMessageEdited,
+			want: func(t *testing.T, got websocket.Message, id string) {
+				assert.Equal(t, id, got.ID)
+				assert.Equal(t, "hello, fixed", got.Content)
+				assert.Equal(t, "user1", got.UserID)
+			},
+		},
+		{
+			name:   "Given the author deletes their message, When the delete frame is sent, Then the room receives message_deleted",
+			editor: "alice",
+			frame: func(id string) websocket.Message {
+				return websocket.Message{Type: websocket.MessageTypeDelete.ToString(), ID: id}
+			},
+			wantType: websocket.MessageTypeMessageDeleted,
+			want: func(t *testing.T, got websocket.Message, id string) {
+				assert.Equal(t, id, got.ID)
+				assert.Empty(t, got.Content)
+			},
+		},
+		{
+			name:   "Given another user edits the message, When the edit frame is sent, Then only they receive an error",
+			editor: "bob",
+			frame: func(id string) websocket.Message {
+				return websocket.Message{Type: websocket.MessageTypeEdit.ToString(), ID: id, Content: "not mine"}
+			},
+			wantType: websocket.MessageTypeError,
+			want: func(t *testing.T, got websocket.Message, _ string) {
+				assert.Equal(t, "only the author can change this message", got.Content)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, jwtService, _ := startStack(t)
+			clients := map[string]*gorillaws.Conn{
+				"alice": dial(t, server, jwtService, "user1", "alice", "stocks"),
+				"bob":   dial(t, server, jwtService, "user2", "bob", "stocks"),
+			}
+
+			require.NoError(t, clients["alice"].WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "hello"}))
+			original := readUntil(t, clients["bob"], websocket.MessageTypeChat)
+			require.NotEmpty(t, original.ID)
+
+			require.NoError(t, clients[tt.editor].WriteJSON(tt.frame(original.ID)))
+
+			// Errors go to the sender only; room events are checked from the other client's view.
+			observer := clients["bob"]
+			if tt.wantType == websocket.MessageTypeError {
+				observer = clients[tt.editor]
+			}
+			tt.want(t, readUntil(t, observer, tt.wantType), original.ID)
+		})
+	}
+}
+
+func TestIntegration_RepliesAndReactions(t *testing.T) {
+	server, jwtService, _ := startStack(t)
+	alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+	bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+	require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "AAPL looks cheap"}))
+	root := readUntil(t, bob, websocket.MessageTypeChat)
+	readUntil(t, alice, websocket.MessageTypeChat)
+	require.NotEmpty(t, root.ID)
+
+	require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "agreed", ReplyTo: root.ID}))
+	reply := readUntil(t, alice, websocket.MessageTypeChat)
+	readUntil(t, bob, websocket.MessageTypeChat)
+	assert.Equal(t, root.ID, reply.ReplyTo)
+
+	// Answering a reply joins the root's thread instead of nesting.
+	require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "buying", ReplyTo: reply.ID}))
+	nested := readUntil(t, bob, websocket.MessageTypeChat)
+	readUntil(t, alice, websocket.MessageTypeChat)
+	assert.Equal(t, root.ID, nested.ReplyTo)
+
+	require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeReaction.ToString(), ID: root.ID, Content: "👍"}))
+	updated := readUntil(t, alice, websocket.MessageTypeReactionUpdated)
+	assert.Equal(t, root.ID, updated.ID)
+	assert.Equal(t, []messagedao.ReactionCount{{Emoji: "👍", Count: 1}}, updated.Reactions)
+
+	// Reacting again with the same emoji removes the reaction.
+	require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeReaction.ToString(), ID: root.ID, Content: "👍"}))
+	updated = readUntil(t, alice, websocket.MessageTypeReactionUpdated)
+	assert.Empty(t, updated.Reactions)
+
+	require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeReaction.ToString(), ID: root.ID, Content: "nice"}))
+	assert.Equal(t, "a reaction must be a single emoji", readUntil(t, bob, websocket.MessageTypeError).Content)
+
+	require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "lost", ReplyTo: "missing"}))
+	assert.Equal(t, "the message being replied to was not found", readUntil(t, bob, websocket.MessageTypeError).Content)
+}
+
+func TestIntegration_Mentions(t *testing.T) {
+	server, jwtService, hub := startStack(t)
+	alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+	dial(t, server, jwtService, "user2", "bob", "stocks").Close()
+	bob := dial(t, server, jwtService, "user2", "bob", "forex")
+	dial(t, server, jwtService, "user3", "carol", "stocks").Close()
+	dial(t, server, jwtService, "user4", "dave", "stocks")
+
+	require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeCommand.ToString(), Content: "/ban dave"}))
+	readUntil(t, alice, websocket.MessageTypeModeration)
+
+	require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "@bob @carol @dave @alice @nobody PETR4 is moving"}))
+
+	// bob is a member of the room connected to another room, and still gets the mention.
+	mention := readUntil(t, bob, websocket.MessageTypeMention)
+	assert.Equal(t, "stocks", mention.RoomID)
+	assert.Equal(t, "alice", mention.Username)
+	assert.NotEmpty(t, mention.ID)
+	assert.NotEmpty(t, mention.NotificationID)
+
+	// carol is offline, so the mention waits for her as an unread notification.
+	notifications := hub.Notifications.(*notificationservice.Service)
+	unread, err := notifications.List(context.Background(), "user3", true)
+	require.NoError(t, err)
+	require.Len(t, unread, 1)
+	assert.Equal(t, mention.ID, unread[0].MessageID)
+
+	// dave was banned from the room, so the message is not copied to him.
+	banned, err := notifications.List(context.Background(), "user4", true)
+	require.NoError(t, err)
+	assert.Empty(t, banned)
+
+	// Authors are not notified about mentioning themselves.
+	mine, err := notifications.List(context.Background(), "user1", true)
+	require.NoError(t, err)
+	assert.Empty(t, mine)
+}
+
+func TestIntegration_MentionsFollowEditsAndDeletes(t *testing.T) {
+	server, jwtService, hub := startStack(t)
+	alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+	bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+	notifications := hub.Notifications.(*notificationservice.Service)
+
+	require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "@bob my password is hunter2"}))
+	mention := readUntil(t, bob, websocket.MessageTypeMention)
+
+	t.Run("Given a message with a mention, When its author edits it, Then the notification shows the new content", func(t *testing.T) {
+		require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeEdit.ToString(), ID: mention.ID, Content: "@bob never mind"}))
+		readUntil(t, bob, websocket.MessageTypeMessageEdited)
+
+		got, err := notifications.List(context.Background(), "user2", false)
+		require.NoError(t, err)
+		require.Len(t, got, 1)
+		assert.Equal(t, "@bob never mind", got[0].Content)
+	})
+
+	t.Run("Given a message with a mention, When its author deletes it, Then the notification is removed", func(t *testing.T) {
+		require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeDelete.ToString(), ID: mention.ID}))
+		readUntil(t, bob, websocket.MessageTypeMessageDeleted)
+
+		got, err := notifications.List(context.Background(), "user2", false)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+}
+
+func TestIntegration_ReadReceipts(t *testing.T) {
+	server, jwtService, hub := startStack(t)
+	alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+	bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+	var sent []websocket.Message
+	for _, content := range []string{"first", "second", "third"} {
+		require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: content}))
+		sent = append(sent, readUntil(t, bob, websocket.MessageTypeChat))
+	}
+
+	require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeRead.ToString(), ID: sent[1].ID}))
+	receipt := readUntil(t, alice, websocket.MessageTypeReadReceipt)
+	assert.Equal(t, sent[1].ID, receipt.ID)
+	assert.Equal(t, "bob", receipt.Username)
+
+	// An older receipt arriving late does not move the cursor back.
+	require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeRead.ToString(), ID: sent[0].ID}))
+	readUntil(t, alice, websocket.MessageTypeReadReceipt)
+
+	unread, err := hub.Messages.(*messageservice.Service).Unread(context.Background(), "user2")
+	require.NoError(t, err)
+	assert.Equal(t, []messagedao.UnreadCount{{RoomID: "stocks", LastReadMessageID: sent[1].ID, Unread: 1}}, unread)
+
+	// carol joined but never marked anything read, so every message counts.
+	dial(t, server, jwtService, "user3", "carol", "stocks")
+	unread, err = hub.Messages.(*messageservice.Service).Unread(context.Background(), "user3")
+	require.NoError(t, err)
+	assert.Equal(t, []messagedao.UnreadCount{{RoomID: "stocks", Unread: 3}}, unread)
+}
+
+func TestIntegration_SearchRespectsMembership(t *testing.T) {
+	server, jwtService, hub := startStack(t)
+	alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+	dial(t, server, jwtService, "user2", "bob", "forex")
+
+	require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "PETR4 is up"}))
+	readUntil(t, alice, websocket.MessageTypeChat)
+
+	messages := hub.Messages.(*messageservice.Service)
+
+	hits, err := messages.Search(context.Background(), "user1", messagedto.SearchDTO{Query: "petr4"})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "<mark>PETR4</mark> is up", hits[0].Snippet)
+
+	// bob never joined stocks, so its history is not searchable for him.
+	hits, err = messages.Search(context.Background(), "user2", messagedto.SearchDTO{Query: "petr4"})
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	_, err = messages.Search(context.Background(), "user2", messagedto.SearchDTO{Query: "petr4", RoomID: "stocks"})
+	assert.Error(t, err)
+}
+
+func TestIntegration_RateLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		send    websocket.Message
+		allowed websocket.MessageType
+	}{
+		{
+			name:    "Given a client over its message limit, When it sends another chat message, Then only the sender gets a rate_limited frame",
+			send:    websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "spam"},
+			allowed: websocket.MessageTypeChat,
+		},
+		{
+			name:    "Given a client over its command limit, When it sends another command, Then only the sender gets a rate_limited frame",
+			send:    websocket.Message{Type: websocket.MessageTypeCommand.ToString(), Content: "/stock=AAPL.US"},
+			allowed: websocket.MessageTypeBot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := ratelimit.Limit{PerMinute: 1, Burst: 2}
+			server, jwtService, _ := startStack(t, websocket.WithRateLimits(limit, limit))
+
+			alice := dial(t, server, jwtService, "user1", "alice", "general")
+			bob := dial(t, server, jwtService, "user2", "bob", "general")
+
+			for range limit.Burst {
+				require.NoError(t, alice.WriteJSON(tt.send))
+				readUntil(t, alice, tt.allowed)
+				readUntil(t, bob, tt.allowed)
+			}
+
+			require.NoError(t, alice.WriteJSON(tt.send))
+			got := readUntil(t, alice, websocket.MessageTypeRateLimited)
+			assert.Equal(t, "general", got.RoomID)
+
+			require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "still here"}))
+			next := readUntil(t, bob, websocket.MessageTypeChat)
+			assert.Equal(t, "still here", next.Content, "the dropped frame must not reach the room")
+		})
+	}
+}
+
+func TestIntegration_ContentValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  string
+	}{
+		{
+			name:  "Given a chat message with only whitespace, When it is sent, Then only the sender gets an error frame",
+			frame: []byte(`{"type":"default","content":"  \n\t "}`),
+			want:  "Message rejected: content is empty.",
+		},
+		{
+			name:  "Given a chat message over the content limit, When it is sent, Then only the sender gets an error frame",
+			frame: []byte(`{"type":"default","content":"` + strings.Repeat("é", 11) + `"}`),
+			want:  "Message rejected: content is longer than 10 characters.",
+		},
+		{
+			name:  "Given a frame with invalid UTF-8, When it is sent, Then only the sender gets an error frame",
+			frame: []byte("{\"type\":\"default\",\"content\":\"bad \xff\xfe\"}"),
+			want:  "Message rejected: content is not valid UTF-8.",
+		},
+		{
+			name:  "Given an edit with empty content, When it is sent, Then only the sender gets an error frame",
+			frame: []byte(`{"type":"edit","id":"any","content":""}`),
+			want:  "Message rejected: content is empty.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, jwtService, _ := startStack(t, websocket.WithMaxContentLength(10))
+
+			alice := dial(t, server, jwtService, "user1", "alice", "general")
+			bob := dial(t, server, jwtService, "user2", "bob", "general")
+
+			require.NoError(t, alice.WriteMessage(gorillaws.TextMessage, tt.frame))
+			got := readUntil(t, alice, websocket.MessageTypeError)
+			assert.Equal(t, tt.want, got.Content)
+
+			require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "still here"}))
+			next := readUntil(t, bob, websocket.MessageTypeChat)
+			assert.Equal(t, "still here", next.Content, "the rejected frame must not reach the room")
+		})
+	}
+}
+
+func TestIntegration_ContentIsSanitized(t *testing.T) {
+	server, jwtService, _ := startStack(t)
+
+	alice := dial(t, server, jwtService, "user1", "alice", "general")
+	bob := dial(t, server, jwtService, "user2", "bob", "general")
+
+	require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: " hi\x1b[31m\x00 there\nbob\u0007 "}))
+
+	got := readUntil(t, bob, websocket.MessageTypeChat)
+	assert.Equal(t, "hi[31m there\nbob", got.Content)
+}
+
+func TestIntegration_MaxFrameSize(t *testing.T) {
+	server, jwtService, _ := startStack(t, websocket.WithMaxFrameSize(256))
+
+	alice := dial(t, server, jwtService, "user1", "alice", "general")
+
+	require.NoError(t, alice.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: strings.Repeat("a", 512)}))
+
+	alice.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var msg websocket.Message
+		err := alice.ReadJSON(&msg)
+		if err == nil {
+			continue
+		}
+		assert.True(t, gorillaws.IsCloseError(err, gorillaws.CloseMessageTooBig), "got %v", err)
+		return
+	}
+}
+
+func TestIntegration_Moderation(t *testing.T) {
+	command := func(content string) websocket.Message {
+		return websocket.Message{Type: websocket.MessageTypeCommand.ToString(), Content: content}
+	}
+	chat := websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "hello"}
+
+	t.Run("Given a muted member, When they send a message, Then only they get an error until they are unmuted", func(t *testing.T) {
+		server, jwtService, _ := startStack(t)
+		alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+		bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+		require.NoError(t, alice.WriteJSON(command("/mute @bob 10m spamming")))
+		event := readUntil(t, bob, websocket.MessageTypeModeration)
+		assert.Equal(t, "mute", event.Action)
+		assert.Equal(t, "bob", event.TargetUsername)
+		assert.Equal(t, "bob was muted by alice for 10m0s: spamming", event.Content)
+		readUntil(t, alice, websocket.MessageTypeModeration)
+
+		require.NoError(t, bob.WriteJSON(chat))
+		rejected := readUntil(t, bob, websocket.MessageTypeError)
+		assert.Contains(t, rejected.Content, "You are muted in this room until")
+
+		require.NoError(t, alice.WriteJSON(command("/unmute bob")))
+		readUntil(t, bob, websocket.MessageTypeModeration)
+		readUntil(t, alice, websocket.MessageTypeModeration)
+
+		require.NoError(t, bob.WriteJSON(chat))
+		got := readUntil(t, alice, websocket.MessageTypeChat)
+		assert.Equal(t, "bob", got.Username, "the message sent while muted never reached the room")
+	})
+
+	t.Run("Given a kicked member, When the kick is applied, Then their connection closes with the reason and they can rejoin", func(t *testing.T) {
+		server, jwtService, _ := startStack(t)
+		alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+		bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+		require.NoError(t, alice.WriteJSON(command("/kick bob cool off")))
+		assertClosed(t, bob, "bob was kicked by alice: cool off")
+
+		dial(t, server, jwtService, "user2", "bob", "stocks")
+	})
+
+	t.Run("Given a banned member, When they reconnect, Then the handshake is refused until they are unbanned", func(t *testing.T) {
+		server, jwtService, _ := startStack(t)
+		alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+		bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+		require.NoError(t, alice.WriteJSON(command("/ban bob")))
+		assertClosed(t, bob, "bob was banned by alice")
+		readUntil(t, alice, websocket.MessageTypeModeration)
+
+		token, err := jwtService.GenerateToken("user2", "bob", roles.Member, "session-user2")
+		require.NoError(t, err)
+		_, resp, err := bearerDialer(token).Dial(wsURL(server, url.Values{"room": {"stocks"}}), nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		require.NoError(t, alice.WriteJSON(command("/unban bob")))
+		readUntil(t, alice, websocket.MessageTypeModeration)
+		dial(t, server, jwtService, "user2", "bob", "stocks")
+	})
+
+	t.Run("Given a regular member, When they try to kick the owner, Then only they get an error", func(t *testing.T) {
+		server, jwtService, _ := startStack(t)
+		dial(t, server, jwtService, "user1", "alice", "stocks")
+		bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+		require.NoError(t, bob.WriteJSON(command("/kick alice")))
+		got := readUntil(t, bob, websocket.MessageTypeError)
+		assert.Equal(t, "only the room owner and moderators can moderate it", got.Content)
+	})
+
+	t.Run("Given a malformed moderation command, When it is sent, Then its usage is returned", func(t *testing.T) {
+		server, jwtService, _ := startStack(t)
+		alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+
+		require.NoError(t, alice.WriteJSON(command("/ban")))
+		got := readUntil(t, alice, websocket.MessageTypeError)
+		assert.Equal(t, "Usage: /ban <username> [duration] [reason]", got.Content)
+	})
+}
+
+// assertClosed reads from conn until the server closes it, and checks the close frame is a
+// policy violation carrying reason.
+func assertClosed(t *testing.T, conn *gorillaws.Conn, reason string) {
+	t.Helper()
+	assertClosedWith(t, conn, gorillaws.ClosePolicyViolation, reason)
+}
+
+// assertClosedWith reads from conn until the server closes it, and checks the close frame
+// carries code and reason.
+func assertClosedWith(t *testing.T, conn *gorillaws.Conn, code int, reason string) {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var closeErr *gorillaws.CloseError
+			require.ErrorAs(t, err, &closeErr)
+			assert.Equal(t, code, closeErr.Code)
+			assert.Equal(t, reason, closeErr.Text)
+			return
+		}
+	}
+}
+
+func TestIntegration_ContentFilters(t *testing.T) {
+	server, jwtService, hub := startStack(t)
+	alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+	bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+	rooms := hub.Memberships.(*roomservice.Service)
+	chat := func(content string) websocket.Message {
+		return websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: content}
+	}
+
+	// Rooms mask by default.
+	require.NoError(t, bob.WriteJSON(chat("this is shit news")))
+	got := readUntil(t, alice, websocket.MessageTypeChat)
+	assert.Equal(t, "this is **** news", got.Content)
+
+	// Repeats cannot be masked, so the third identical message is blocked.
+	for range 2 {
+		require.NoError(t, bob.WriteJSON(chat("buy PETR4")))
+		readUntil(t, bob, websocket.MessageTypeChat)
+	}
+	require.NoError(t, bob.WriteJSON(chat("buy PETR4")))
+	blocked := readUntil(t, bob, websocket.MessageTypeError)
+	assert.Equal(t, "Message blocked: it repeats a recent message.", blocked.Content)
+
+	// The owner switches the room to blocking profanity only.
+	_, err := rooms.SetFilters(context.Background(), "stocks", "user1", roles.Member, roomdto.FiltersDTO{Filters: []string{filter.NameProfanity}, Mode: filter.ModeBlock})
+	require.NoError(t, err)
+
+	require.NoError(t, bob.WriteJSON(chat("shit")))
+	blocked = readUntil(t, bob, websocket.MessageTypeError)
+	assert.Equal(t, "Message blocked: it contains a blocked word.", blocked.Content)
+
+	require.NoError(t, bob.WriteJSON(chat("buy PETR4")))
+	got = readUntil(t, alice, websocket.MessageTypeChat)
+	assert.Equal(t, "buy PETR4", got.Content, "the repeat filter is disabled in this room")
+
+	events, err := rooms.ModerationHistory(context.Background(), "stocks", "user1", roles.Member)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, roomdao.ModerationActionFilterBlock, events[0].Action)
+	assert.Equal(t, "profanity: contains a blocked word", events[0].Reason)
+	assert.Equal(t, roomdao.ModerationActionFilterBlock, events[1].Action)
+	assert.Equal(t, "repeat: repeats a recent message", events[1].Reason)
+	assert.Equal(t, roomdao.ModerationActionFilterMask, events[2].Action)
+	assert.Equal(t, "bob", events[2].TargetUsername)
+}
+
+func TestIntegration_DeleteRoom(t *testing.T) {
+	server, jwtService, hub := startStack(t)
+	alice := dial(t, server, jwtService, "user1", "alice", "stocks")
+	bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+	require.NoError(t, bob.WriteJSON(websocket.Message{Type: websocket.MessageTypeChat.ToString(), Content: "hello"}))
+	readUntil(t, alice, websocket.MessageTypeChat)
+
+	err := hub.DeleteRoom(context.Background(), "stocks", "user2", roles.Member)
+	var appErr *customerrors.AppError
+	require.ErrorAs(t, err, &appErr)
+	assert.Equal(t, http.StatusForbidden, appErr.Status)
+
+	require.NoError(t, hub.DeleteRoom(context.Background(), "stocks", "user1", roles.Member))
+	assertClosedWith(t, alice, gorillaws.CloseGoingAway, "room was deleted")
+	assertClosedWith(t, bob, gorillaws.CloseGoingAway, "room was deleted")
+
+	// The room is gone, so whoever connects next creates it afresh and owns it.
+	carol := dial(t, server, jwtService, "user3", "carol", "stocks")
+	require.NoError(t, carol.WriteJSON(websocket.Message{Type: websocket.MessageTypeCommand.ToString(), Content: "/kick alice"}))
+	got := readUntil(t, carol, websocket.MessageTypeError)
+	assert.Equal(t, "user is not a member of this room", got.Content)
+}
+
+func TestIntegration_RevokedSession(t *testing.T) {
+	server, jwtService, hub := startStack(t)
+	stocks := dial(t, server, jwtService, "user1", "alice", "stocks")
+	general := dial(t, server, jwtService, "user1", "alice", "general")
+	bob := dial(t, server, jwtService, "user2", "bob", "stocks")
+
+	hub.CloseSession("session-user1")
+	assertClosed(t, stocks, "session was revoked")
+	assertClosed(t, general, "session was revoked")
+
+	got := readUntil(t, bob, websocket.MessageTypeUserLeft)
+	assert.Equal(t, "alice", got.Username, "other sessions stay connected")
+}
+
+// testKeys returns a key set holding a single Ed25519 key, active now.
+func testKeys(t *testing.T) *jwt.KeySet {
+	t.Helper()
+
+	key, err := jwt.GenerateKey("test", jwt.AlgorithmEdDSA, time.Now())
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	keys, err := jwt.NewKeySet(key)
+	if err != nil {
+		t.Fatalf("failed to build key set: %v", err)
+	}
+	return keys
+}
+
+func TestIntegration_HandshakeAuth(t *testing.T) {
+	ticketFor := func(t *testing.T, server *httptest.Server, token string) string {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/ws/ticket", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var body websocket.TicketResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, int(websocket.TicketTTL.Seconds()), body.ExpiresIn)
+		return body.Ticket
+	}
+
+	t.Run("Given a ticket, When it is used to connect, Then the connection opens once and the ticket cannot be reused", func(t *testing.T) {
+		server, jwtService, _ := startStack(t)
+		token, err := jwtService.GenerateToken("user1", "alice", roles.Member, "session-user1")
+		require.NoError(t, err)
+		ticket := ticketFor(t, server, token)
+
+		conn, _, err := gorillaws.DefaultDialer.Dial(wsURL(server, url.Values{"ticket": {ticket}, "room": {"stocks"}}), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		got := readUntil(t, conn, websocket.MessageTypeUserJoined)
+		assert.Equal(t, "alice", got.Username)
+
+		_, resp, err := gorillaws.DefaultDialer.Dial(wsURL(server, url.Values{"ticket": {ticket}, "room": {"stocks"}}), nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Given a token in Sec-WebSocket-Protocol, When connecting, Then the chat subprotocol is selected", func(t *testing.T) {
+		server, jwtService, _ := startStack(t)
+		token, err := jwtService.GenerateToken("user1", "alice", roles.Member, "session-user1")
+		require.NoError(t, err)
+
+		conn, resp, err := bearerDialer(token).Dial(wsURL(server, url.Values{"room": {"stocks"}}), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		assert.Equal(t, websocket.Subprotocol, resp.Header.Get("Sec-WebSocket-Protocol"))
+		assert.Equal(t, websocket.Subprotocol, conn.Subprotocol())
+	})
+
+	t.Run("Given a token in the query string, When query token auth is disabled, Then the handshake is refused", func(t *testing.T) {
+		server, jwtService, _ := startStack(t)
+		token, err := jwtService.GenerateToken("user1", "alice", roles.Member, "session-user1")
+		require.NoError(t, err)
+
+		_, resp, err := gorillaws.DefaultDialer.Dial(wsURL(server, url.Values{"token": {token}, "room": {"stocks"}}), nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Given a token in the query string, When query token auth is enabled, Then the connection opens", func(t *testing.T) {
+		server, jwtService, _ := startStack(t, websocket.WithQueryTokenAuth(true))
+		token, err := jwtService.GenerateToken("user1", "alice", roles.Member, "session-user1")
+		require.NoError(t, err)
+
+		conn, _, err := gorillaws.DefaultDialer.Dial(wsURL(server, url.Values{"token": {token}, "room": {"stocks"}}), nil)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		readUntil(t, conn, websocket.MessageTypeUserJoined)
+	})
+
+	t.Run("Given an unknown ticket, When connecting, Then the handshake is refused", func(t *testing.T) {
+		server, _, _ := startStack(t)
+
+		_, resp, err := gorillaws.DefaultDialer.Dial(wsURL(server, url.Values{"ticket": {"forged"}, "room": {"stocks"}}), nil)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
@@ -0,0 +1,146 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	roomdao "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dao"
+	roomdto "github.com/Lucas-Onofre/financial-chat/chat-service/internal/room/dto"
+)
+
+// moderationUsage maps each moderation command to its usage, sent back when it is malformed.
+var moderationUsage = map[string]string{
+	roomdao.ModerationActionMute:   "/mute <username> [duration] [reason]",
+	roomdao.ModerationActionUnmute: "/unmute <username>",
+	roomdao.ModerationActionKick:   "/kick <username> [reason]",
+	roomdao.ModerationActionBan:    "/ban <username> [duration] [reason]",
+	roomdao.ModerationActionUnban:  "/unban <username>",
+}
+
+var errModerationUsage = errors.New("invalid moderation command")
+
+// isModerationCommand reports whether content is one of the commands the hub handles itself
+// instead of forwarding them to bot-service.
+func isModerationCommand(content string) bool {
+	fields := strings.Fields(content)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return false
+	}
+	_, ok := moderationUsage[strings.ToLower(fields[0][1:])]
+	return ok
+}
+
+// parseModeration reads "/<action> <username> [duration] [reason]". Only mutes and bans take a
+// duration, written as a Go duration such as "30m" or as whole days such as "7d".
+func parseModeration(content string) (roomdto.ModerationDTO, error) {
+	fields := strings.Fields(content)
+	action := roomdto.ModerationDTO{Action: strings.ToLower(strings.TrimPrefix(fields[0], "/"))}
+	if len(fields) < 2 {
+		return action, errModerationUsage
+	}
+	action.Username = strings.TrimPrefix(fields[1], "@")
+	rest := fields[2:]
+
+	switch action.Action {
+	case roomdao.ModerationActionMute, roomdao.ModerationActionBan:
+		if len(rest) > 0 {
+			if duration, ok := parseDuration(rest[0]); ok {
+				action.Duration = duration
+				rest = rest[1:]
+			}
+		}
+	case roomdao.ModerationActionUnmute, roomdao.ModerationActionUnban:
+		if len(rest) > 0 {
+			return action, errModerationUsage
+		}
+	}
+
+	action.Reason = strings.Join(rest, " ")
+	return action, nil
+}
+
+func parseDuration(raw string) (time.Duration, bool) {
+	if days, found := strings.CutSuffix(raw, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, false
+		}
+		return time.Duration(n) * 24 * time.Hour, true
+	}
+
+	duration, err := time.ParseDuration(raw)
+	if err != nil || duration <= 0 {
+		return 0, false
+	}
+	return duration, true
+}
+
+// handleModeration applies a /mute, /unmute, /kick, /ban or /unban command from the client,
+// announces it to the room and enforces it on the target's open connections.
+func (c *Client) handleModeration(message Message) {
+	action, err := parseModeration(message.Content)
+	if err != nil {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, "Usage: "+moderationUsage[action.Action]))
+		return
+	}
+
+	event, err := c.Hub.Memberships.Moderate(context.Background(), c.RoomID, c.UserID, c.Role, action)
+	if err != nil {
+		c.Hub.sendToClient(c, NewErrorMessage(c.RoomID, errorText(err, "Failed to apply moderation. Please try again later.")))
+		return
+	}
+
+	description := describeModeration(*event)
+	c.Hub.broadcastToRoom(c.RoomID, Message{
+		ID:             event.ID,
+		Type:           MessageTypeModeration.ToString(),
+		UserID:         c.UserID,
+		Username:       c.Username,
+		RoomID:         c.RoomID,
+		Content:        description,
+		Action:         event.Action,
+		TargetUsername: event.TargetUsername,
+		Timestamp:      event.CreatedAt.Unix(),
+	})
+
+	switch event.Action {
+	case roomdao.ModerationActionMute:
+		c.Hub.mute(c.RoomID, event.TargetID, event.ExpiresAt)
+	case roomdao.ModerationActionUnmute:
+		c.Hub.unmute(c.RoomID, event.TargetID)
+	case roomdao.ModerationActionKick, roomdao.ModerationActionBan:
+		c.Hub.disconnect(c.RoomID, event.TargetID, description)
+	}
+}
+
+// describeModeration phrases event for the room, e.g. "bob was muted by alice for 10m0s: spamming".
+func describeModeration(event roomdao.ModerationEvent) string {
+	verbs := map[string]string{
+		roomdao.ModerationActionMute:   "muted",
+		roomdao.ModerationActionUnmute: "unmuted",
+		roomdao.ModerationActionKick:   "kicked",
+		roomdao.ModerationActionBan:    "banned",
+		roomdao.ModerationActionUnban:  "unbanned",
+	}
+
+	description := fmt.Sprintf("%s was %s by %s", event.TargetUsername, verbs[event.Action], event.ModeratorUsername)
+	if event.ExpiresAt != nil {
+		description += " for " + event.ExpiresAt.Sub(event.CreatedAt).Round(time.Second).String()
+	}
+	if event.Reason != "" {
+		description += ": " + event.Reason
+	}
+	return description
+}
+
+// mutedText tells a muted client why its message was dropped.
+func mutedText(until time.Time) string {
+	if until.IsZero() {
+		return "You are muted in this room."
+	}
+	return "You are muted in this room until " + until.UTC().Format(time.RFC3339) + "."
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/filter"
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/ratelimit"
+)
+
+// DefaultMessageLimit applies to chat messages, edits, deletions and reactions when none is configured.
+var DefaultMessageLimit = ratelimit.Limit{PerMinute: 30, Burst: 10}
+
+// DefaultCommandLimit applies to bot commands, which cost a broker round trip, when none is configured.
+var DefaultCommandLimit = ratelimit.Limit{PerMinute: 6, Burst: 2}
+
+const (
+	// DefaultMaxFrameSize is the largest WebSocket frame, in bytes, a client may send when none is configured.
+	DefaultMaxFrameSize int64 = 32 * 1024
+	// DefaultMaxContentLength is the longest message content, in runes, accepted when none is configured.
+	DefaultMaxContentLength = 4000
+)
+
+type Option func(*options)
+
+type options struct {
+	messageLimit     ratelimit.Limit
+	commandLimit     ratelimit.Limit
+	maxFrameSize     int64
+	maxContentLength int
+	filters          *filter.Chain
+	queryTokenAuth   bool
+}
+
+// WithRateLimits sets the per-user token buckets for chat messages and for commands.
+// A limit with PerMinute <= 0 disables that bucket.
+func WithRateLimits(messages, commands ratelimit.Limit) Option {
+	return func(o *options) {
+		o.messageLimit = messages
+		o.commandLimit = commands
+	}
+}
+
+// WithMaxFrameSize sets the largest frame, in bytes, read from a client. Larger frames close the connection.
+func WithMaxFrameSize(size int64) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.maxFrameSize = size
+		}
+	}
+}
+
+// WithMaxContentLength sets the longest content, in runes, accepted in chat messages, edits and commands.
+func WithMaxContentLength(length int) Option {
+	return func(o *options) {
+		if length > 0 {
+			o.maxContentLength = length
+		}
+	}
+}
+
+// WithFilters sets the content filter chain run on chat messages and edits. Each room chooses
+// which of the chain's filters apply to it.
+func WithFilters(chain *filter.Chain) Option {
+	return func(o *options) {
+		if chain != nil {
+			o.filters = chain
+		}
+	}
+}
+
+// WithQueryTokenAuth lets clients still pass their access token as ?token= in the handshake URL.
+// It is deprecated, since URLs end up in proxy and access logs; clients should use a ticket
+// from POST /ws/ticket or the Sec-WebSocket-Protocol header instead.
+func WithQueryTokenAuth(allowed bool) Option {
+	return func(o *options) {
+		o.queryTokenAuth = allowed
+	}
+}
+
+// DefaultFilters returns a chain with every filter of the filter package and their default settings.
+func DefaultFilters() *filter.Chain {
+	return filter.NewChain(
+		filter.NewProfanity(filter.DefaultBlockedWords),
+		filter.NewRepeat(filter.DefaultRepeatWindow, filter.DefaultMaxRepeats),
+		filter.NewLinks(filter.DefaultMaxLinks),
+	)
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		messageLimit:     DefaultMessageLimit,
+		commandLimit:     DefaultCommandLimit,
+		maxFrameSize:     DefaultMaxFrameSize,
+		maxContentLength: DefaultMaxContentLength,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.filters == nil {
+		o.filters = DefaultFilters()
+	}
+	return o
+}
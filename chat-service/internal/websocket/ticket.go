@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Lucas-Onofre/financial-chat/chat-service/internal/auth/jwt/utils"
+)
+
+// TicketTTL is how long a WebSocket ticket can be redeemed for after it is issued.
+const TicketTTL = 30 * time.Second
+
+// ticketStore holds single-use tickets that stand in for an access token in the WebSocket
+// handshake URL, so the token itself never reaches proxy and access logs. Tickets are kept by
+// hash and map to the access token they were issued for, which is validated again on redeem.
+type ticketStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	tickets map[string]ticket
+}
+
+type ticket struct {
+	accessToken string
+	expiresAt   time.Time
+}
+
+func newTicketStore(ttl time.Duration) *ticketStore {
+	return &ticketStore{
+		ttl:     ttl,
+		tickets: make(map[string]ticket),
+	}
+}
+
+// issue creates a ticket for accessToken.
+func (s *ticketStore) issue(accessToken string) (string, error) {
+	value, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	// Unredeemed tickets are dropped here, so the map only holds the last TTL's worth.
+	for key, t := range s.tickets {
+		if !now.Before(t.expiresAt) {
+			delete(s.tickets, key)
+		}
+	}
+	s.tickets[hash] = ticket{accessToken: accessToken, expiresAt: now.Add(s.ttl)}
+
+	return value, nil
+}
+
+// redeem consumes value, returning the access token it was issued for. A ticket works once,
+// and not at all after it expires.
+func (s *ticketStore) redeem(value string) (string, bool) {
+	hash := utils.HashToken(value)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tickets[hash]
+	if !ok {
+		return "", false
+	}
+	delete(s.tickets, hash)
+
+	if !time.Now().Before(t.expiresAt) {
+		return "", false
+	}
+	return t.accessToken, true
+}